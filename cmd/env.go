@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/go-libs/v3/otlp/otlptraces"
+	"github.com/formancehq/terraform-provider-cloud/internal/server"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// EnvPrefix prefixes the environment variables of the flags of the provider binary.
+	EnvPrefix = "FORMANCE_CLOUD_PROVIDER_"
+
+	flagPrefix = "formance-cloud-"
+)
+
+// secretFlags are redacted when logging the configuration.
+var secretFlags = []string{
+	server.FormanceCloudClientSecretKey,
+	server.FormanceCloudPrivateKeyKey,
+	server.FormanceCloudClientAssertionKey,
+	server.FormanceCloudTokenCacheEncryptionKeyKey,
+	otlptraces.OtelTracesExporterJaegerPasswordFlag,
+}
+
+// EnvVar returns the environment variable of a flag, FORMANCE_CLOUD_PROVIDER_CLIENT_ID for formance-cloud-client-id
+// and FORMANCE_CLOUD_PROVIDER_DEBUG for debug.
+func EnvVar(flag string) string {
+	return EnvPrefix + legacyEnvVar(strings.TrimPrefix(flag, flagPrefix))
+}
+
+// legacyEnvVar returns the environment variable bound before the prefixed ones,
+// such as FORMANCE_CLOUD_CLIENT_ID or OTEL_TRACES_EXPORTER.
+func legacyEnvVar(flag string) string {
+	return strings.ReplaceAll(strings.ToUpper(flag), "-", "_")
+}
+
+// bindEnv sets the flags which are not set on the command line from the environment.
+// The prefixed variable takes precedence over the legacy one, and both over the default value of the flag.
+// It returns the source of each flag, to log the effective configuration.
+func bindEnv(flags *pflag.FlagSet) (map[string]string, error) {
+	sources := map[string]string{}
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Name == "help" {
+			return
+		}
+		if flag.Changed {
+			sources[flag.Name] = "command line"
+			return
+		}
+
+		for _, envVar := range []string{EnvVar(flag.Name), legacyEnvVar(flag.Name)} {
+			value, ok := os.LookupEnv(envVar)
+			if !ok || strings.TrimSpace(value) == "" {
+				continue
+			}
+
+			value = strings.TrimSpace(value)
+			if flag.Value.Type() == "stringSlice" {
+				value = strings.ReplaceAll(value, " ", ",")
+			}
+			if e := flags.Set(flag.Name, value); e != nil {
+				err = fmt.Errorf("invalid value of %s for flag --%s: %w", envVar, flag.Name, e)
+				return
+			}
+			sources[flag.Name] = envVar
+			return
+		}
+		sources[flag.Name] = "default"
+	})
+	return sources, err
+}
+
+// logConfiguration logs the value and source of each flag, redacting secrets.
+func logConfiguration(logger logging.Logger, flags *pflag.FlagSet, sources map[string]string) {
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "help" {
+			return
+		}
+		value := flag.Value.String()
+		if value != "" && slices.Contains(secretFlags, flag.Name) {
+			value = "<redacted>"
+		}
+		logger.Debugf("Flag --%s=%s (from %s)", flag.Name, value, sources[flag.Name])
+	})
+}
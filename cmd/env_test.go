@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindEnv(t *testing.T) {
+	t.Setenv("FORMANCE_CLOUD_PROVIDER_CLIENT_ID", "prefixed")
+	t.Setenv("FORMANCE_CLOUD_CLIENT_ID", "legacy")
+	t.Setenv("FORMANCE_CLOUD_CLIENT_SECRET", "legacy")
+	t.Setenv("FORMANCE_CLOUD_PROVIDER_OUTPUT", "stderr")
+	t.Setenv("FORMANCE_CLOUD_PROVIDER_OTEL_RESOURCE_ATTRIBUTES", "a=b c=d")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("formance-cloud-client-id", "", "")
+	flags.String("formance-cloud-client-secret", "", "")
+	flags.String("formance-cloud-api-endpoint", "default", "")
+	flags.String("output", "file", "")
+	flags.StringSlice("otel-resource-attributes", nil, "")
+	require.NoError(t, flags.Parse([]string{"--output=file"}))
+
+	sources, err := bindEnv(flags)
+	require.NoError(t, err)
+
+	for flag, expected := range map[string]struct {
+		value  string
+		source string
+	}{
+		"formance-cloud-client-id":     {"prefixed", "FORMANCE_CLOUD_PROVIDER_CLIENT_ID"},
+		"formance-cloud-client-secret": {"legacy", "FORMANCE_CLOUD_CLIENT_SECRET"},
+		"formance-cloud-api-endpoint":  {"default", "default"},
+		"output":                       {"file", "command line"},
+		"otel-resource-attributes":     {"[a=b,c=d]", "FORMANCE_CLOUD_PROVIDER_OTEL_RESOURCE_ATTRIBUTES"},
+	} {
+		require.Equal(t, expected.value, flags.Lookup(flag).Value.String(), flag)
+		require.Equal(t, expected.source, sources[flag], flag)
+	}
+}
+
+func TestBindEnvInvalidValue(t *testing.T) {
+	t.Setenv("FORMANCE_CLOUD_PROVIDER_DEBUG", "maybe")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Bool("debug", false, "")
+
+	_, err := bindEnv(flags)
+	require.ErrorContains(t, err, "FORMANCE_CLOUD_PROVIDER_DEBUG")
+}
@@ -21,9 +21,13 @@ func init() {
 	cobra.EnableTraverseRunHooks = true
 }
 
+// Execute runs the provider binary. Unlike service.Execute, the environment is bound once the command line
+// is parsed, so that flags set on the command line take precedence over the environment.
 func Execute() {
 	app := &App{}
-	service.Execute(app.CobraCommand())
+	if err := app.CobraCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
 }
 
 type App struct{}
@@ -84,6 +88,11 @@ const (
 )
 
 func (app *App) preRunE(cmd *cobra.Command, args []string) error {
+	sources, err := bindEnv(cmd.Flags())
+	if err != nil {
+		return err
+	}
+
 	writerOutput, _ := cmd.Flags().GetString(OutputFlag)
 	var writer io.Writer
 	switch writerOutput {
@@ -101,6 +110,7 @@ func (app *App) preRunE(cmd *cobra.Command, args []string) error {
 	otelTraces, _ := cmd.Flags().GetString(otlptraces.OtelTracesExporterFlag)
 	logger := logging.NewDefaultLogger(writer, service.IsDebug(cmd), json, otelTraces != "")
 	cmd.SetContext(logging.ContextWithLogger(cmd.Context(), logger.WithField("service", internal.ServiceName)))
+	logConfiguration(logging.FromContext(cmd.Context()), cmd.Flags(), sources)
 
 	options := fx.Options(
 		fxOptsFromContext(cmd.Context()),
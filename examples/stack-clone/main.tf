@@ -0,0 +1,23 @@
+terraform {
+  required_providers {
+    cloud = {
+      source = "formancehq/cloud"
+    }
+  }
+}
+
+provider "cloud" {}
+
+variable "source_stack_id" {
+  type = string
+}
+
+resource "cloud_stack_clone" "incident" {
+  source_stack_id = var.source_stack_id
+  name            = "incident-reproduction"
+  force_destroy   = true
+}
+
+output "clone_uri" {
+  value = cloud_stack_clone.incident.uri
+}
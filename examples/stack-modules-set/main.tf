@@ -0,0 +1,27 @@
+terraform {
+  required_providers {
+    cloud = {
+      source = "formancehq/cloud"
+    }
+  }
+}
+
+provider "cloud" {}
+
+variable "region_id" {
+  type = string
+}
+
+resource "cloud_stack" "default" {
+  name      = "test-stack"
+  region_id = var.region_id
+  modules = [
+    "ledger",
+    "payments",
+    "webhooks",
+  ]
+}
+
+output "module_status" {
+  value = cloud_stack.default.module_status
+}
@@ -0,0 +1,33 @@
+terraform {
+  required_providers {
+    cloud = {
+      source = "formancehq/cloud"
+    }
+  }
+}
+
+provider "cloud" {}
+
+variable "canary_stack_ids" {
+  type = list(string)
+}
+
+variable "production_stack_ids" {
+  type = list(string)
+}
+
+variable "target_version" {
+  type = string
+}
+
+resource "cloud_stack_rollout" "default" {
+  version = var.target_version
+  batches = [
+    var.canary_stack_ids,
+    var.production_stack_ids,
+  ]
+}
+
+output "rollout_status" {
+  value = cloud_stack_rollout.default.status
+}
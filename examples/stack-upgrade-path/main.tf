@@ -0,0 +1,26 @@
+terraform {
+  required_providers {
+    cloud = {
+      source = "formancehq/cloud"
+    }
+  }
+}
+
+provider "cloud" {}
+
+variable "stack_id" {
+  type = string
+}
+
+variable "target_version" {
+  type = string
+}
+
+data "cloud_stack_upgrade_path" "default" {
+  stack_id   = var.stack_id
+  to_version = var.target_version
+}
+
+output "upgrade_steps" {
+  value = data.cloud_stack_upgrade_path.default.steps
+}
@@ -0,0 +1,30 @@
+terraform {
+  required_providers {
+    cloud = {
+      source = "formancehq/cloud"
+    }
+  }
+}
+
+provider "cloud" {}
+
+variable "region_datasource_name" {
+  type = string
+}
+
+data "cloud_regions" "dev" {
+  name = var.region_datasource_name
+}
+
+resource "cloud_stack" "default" {
+  name      = "version-constraint"
+  region_id = data.cloud_regions.dev.id
+
+  # Resolved at plan time against the versions available in the region
+  version_constraint = "~> v2.2"
+  auto_upgrade       = true
+}
+
+output "stack_version" {
+  value = cloud_stack.default.version
+}
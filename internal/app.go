@@ -5,6 +5,8 @@ import "fmt"
 const (
 	Repository  = "formancehq/terraform-provider-cloud"
 	ServiceName = "terraform-provider-cloud"
+
+	ProviderTypeName = "cloud"
 )
 
 type AppInfo struct {
@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"golang.org/x/sync/singleflight"
+)
+
+// ListCacheTTL is the lifetime of the cached list responses.
+// The provider invalidates the collections it mutates, the TTL bounds the staleness of changes made outside of it.
+var ListCacheTTL = 30 * time.Second
+
+type noCacheKey struct{}
+
+// ContextWithoutCache makes the list calls made with the context reach the API, for example to poll a status.
+// Their responses still refresh the cache.
+func ContextWithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+type cacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+// listCache caches list responses by collection, concurrent calls for the same collection sharing the same request.
+type listCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// generations are incremented on invalidation, so that in-flight calls do not store stale responses
+	generations map[string]uint64
+	group       singleflight.Group
+}
+
+func newListCache() *listCache {
+	return &listCache{
+		entries:     map[string]cacheEntry{},
+		generations: map[string]uint64{},
+	}
+}
+
+func (c *listCache) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.entries, key)
+		c.generations[key]++
+	}
+}
+
+// cached returns the cached response of the collection, or fetches it. Responses are cloned,
+// since callers may sort them.
+func cached[T any](ctx context.Context, c *listCache, key string, fetch func() (T, error), clone func(T) T) (T, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generations[key]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expires) && ctx.Value(noCacheKey{}) == nil {
+		return clone(entry.value.(T)), nil
+	}
+
+	value, err, coalesced := c.group.Do(fmt.Sprintf("%s@%d", key, generation), func() (any, error) {
+		value, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.generations[key] == generation {
+			c.entries[key] = cacheEntry{
+				value:   value,
+				expires: time.Now().Add(ListCacheTTL),
+			}
+		}
+		return value, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if coalesced {
+		logging.FromContext(ctx).Debugf("Shared the list of %s with concurrent calls", key)
+	}
+	return clone(value.(T)), nil
+}
+
+// cachedSDK caches the list endpoints of the SDK for the duration of a run.
+type cachedSDK struct {
+	pkg.CloudSDK
+	cache *listCache
+}
+
+func newCachedSDK(sdk pkg.CloudSDK) *cachedSDK {
+	return &cachedSDK{
+		CloudSDK: sdk,
+		cache:    newListCache(),
+	}
+}
+
+func stacksKey(organizationID string) string {
+	return "stacks/" + organizationID
+}
+
+func regionsKey(organizationID string) string {
+	return "regions/" + organizationID
+}
+
+func invitationsKey(organizationID string) string {
+	return "invitations/" + organizationID
+}
+
+func modulesKey(organizationID, stackID string) string {
+	return "modules/" + organizationID + "/" + stackID
+}
+
+func stackUsersKey(organizationID, stackID string) string {
+	return "stack-users/" + organizationID + "/" + stackID
+}
+
+func (s *cachedSDK) ListStacks(ctx context.Context, organizationID string) (*operations.ListStacksResponse, error) {
+	return cached(ctx, s.cache, stacksKey(organizationID), func() (*operations.ListStacksResponse, error) {
+		return s.CloudSDK.ListStacks(ctx, organizationID)
+	}, func(res *operations.ListStacksResponse) *operations.ListStacksResponse {
+		if res == nil {
+			return nil
+		}
+		clone := *res
+		if res.ListStacksResponse != nil {
+			clone.ListStacksResponse = &shared.ListStacksResponse{Data: slices.Clone(res.ListStacksResponse.Data)}
+		}
+		return &clone
+	})
+}
+
+func (s *cachedSDK) ListRegions(ctx context.Context, organizationID string) (*operations.ListRegionsResponse, error) {
+	return cached(ctx, s.cache, regionsKey(organizationID), func() (*operations.ListRegionsResponse, error) {
+		return s.CloudSDK.ListRegions(ctx, organizationID)
+	}, func(res *operations.ListRegionsResponse) *operations.ListRegionsResponse {
+		if res == nil {
+			return nil
+		}
+		clone := *res
+		if res.ListRegionsResponse != nil {
+			clone.ListRegionsResponse = &shared.ListRegionsResponse{Data: slices.Clone(res.ListRegionsResponse.Data)}
+		}
+		return &clone
+	})
+}
+
+func (s *cachedSDK) ListOrganizationInvitations(ctx context.Context, organizationID string) (*operations.ListInvitationsResponse, error) {
+	return cached(ctx, s.cache, invitationsKey(organizationID), func() (*operations.ListInvitationsResponse, error) {
+		return s.CloudSDK.ListOrganizationInvitations(ctx, organizationID)
+	}, func(res *operations.ListInvitationsResponse) *operations.ListInvitationsResponse {
+		if res == nil {
+			return nil
+		}
+		clone := *res
+		if res.ListInvitationsResponse != nil {
+			clone.ListInvitationsResponse = &shared.ListInvitationsResponse{Data: slices.Clone(res.ListInvitationsResponse.Data)}
+		}
+		return &clone
+	})
+}
+
+func (s *cachedSDK) ListModules(ctx context.Context, organizationID, stackID string) (*operations.ListModulesResponse, error) {
+	return cached(ctx, s.cache, modulesKey(organizationID, stackID), func() (*operations.ListModulesResponse, error) {
+		return s.CloudSDK.ListModules(ctx, organizationID, stackID)
+	}, func(res *operations.ListModulesResponse) *operations.ListModulesResponse {
+		if res == nil {
+			return nil
+		}
+		clone := *res
+		if res.ListModulesResponse != nil {
+			clone.ListModulesResponse = &shared.ListModulesResponse{Data: slices.Clone(res.ListModulesResponse.Data)}
+		}
+		return &clone
+	})
+}
+
+func (s *cachedSDK) ListStackUsersAccesses(ctx context.Context, organizationID, stackID string) (*operations.ListStackUsersAccessesResponse, error) {
+	return cached(ctx, s.cache, stackUsersKey(organizationID, stackID), func() (*operations.ListStackUsersAccessesResponse, error) {
+		return s.CloudSDK.ListStackUsersAccesses(ctx, organizationID, stackID)
+	}, func(res *operations.ListStackUsersAccessesResponse) *operations.ListStackUsersAccessesResponse {
+		if res == nil {
+			return nil
+		}
+		clone := *res
+		if res.StackUserAccessResponse != nil {
+			clone.StackUserAccessResponse = &shared.StackUserAccessResponse{Data: slices.Clone(res.StackUserAccessResponse.Data)}
+		}
+		return &clone
+	})
+}
+
+func (s *cachedSDK) CreateStack(ctx context.Context, organizationID string, body *shared.CreateStackRequest) (*operations.CreateStackResponse, error) {
+	defer s.cache.invalidate(stacksKey(organizationID))
+	return s.CloudSDK.CreateStack(ctx, organizationID, body)
+}
+
+func (s *cachedSDK) UpdateStack(ctx context.Context, organizationID, stackID string, body *shared.StackData) (*operations.UpdateStackResponse, error) {
+	defer s.cache.invalidate(stacksKey(organizationID))
+	return s.CloudSDK.UpdateStack(ctx, organizationID, stackID, body)
+}
+
+func (s *cachedSDK) DeleteStack(ctx context.Context, organizationID, stackID string, force bool) (*operations.DeleteStackResponse, error) {
+	defer s.cache.invalidate(stacksKey(organizationID), modulesKey(organizationID, stackID), stackUsersKey(organizationID, stackID))
+	return s.CloudSDK.DeleteStack(ctx, organizationID, stackID, force)
+}
+
+func (s *cachedSDK) UpgradeStack(ctx context.Context, organizationID, stackID, version string) (*operations.UpgradeStackResponse, error) {
+	defer s.cache.invalidate(stacksKey(organizationID), modulesKey(organizationID, stackID))
+	return s.CloudSDK.UpgradeStack(ctx, organizationID, stackID, version)
+}
+
+func (s *cachedSDK) EnableModule(ctx context.Context, organizationID, stackID, moduleName string) (*operations.EnableModuleResponse, error) {
+	defer s.cache.invalidate(modulesKey(organizationID, stackID))
+	return s.CloudSDK.EnableModule(ctx, organizationID, stackID, moduleName)
+}
+
+func (s *cachedSDK) DisableModule(ctx context.Context, organizationID, stackID, moduleName string) (*operations.DisableModuleResponse, error) {
+	defer s.cache.invalidate(modulesKey(organizationID, stackID))
+	return s.CloudSDK.DisableModule(ctx, organizationID, stackID, moduleName)
+}
+
+func (s *cachedSDK) UpsertStackUserAccess(ctx context.Context, organizationID, stackID, userID string, body *shared.UpdateStackUserRequest) (*operations.UpsertStackUserAccessResponse, error) {
+	defer s.cache.invalidate(stackUsersKey(organizationID, stackID))
+	return s.CloudSDK.UpsertStackUserAccess(ctx, organizationID, stackID, userID, body)
+}
+
+func (s *cachedSDK) DeleteStackUserAccess(ctx context.Context, organizationID, stackID, userID string) (*operations.DeleteStackUserAccessResponse, error) {
+	defer s.cache.invalidate(stackUsersKey(organizationID, stackID))
+	return s.CloudSDK.DeleteStackUserAccess(ctx, organizationID, stackID, userID)
+}
+
+func (s *cachedSDK) CreateInvitation(ctx context.Context, organizationID, email string) (*operations.CreateInvitationResponse, error) {
+	defer s.cache.invalidate(invitationsKey(organizationID))
+	return s.CloudSDK.CreateInvitation(ctx, organizationID, email)
+}
+
+func (s *cachedSDK) DeleteInvitation(ctx context.Context, organizationID, invitationID string) (*operations.DeleteInvitationResponse, error) {
+	defer s.cache.invalidate(invitationsKey(organizationID))
+	return s.CloudSDK.DeleteInvitation(ctx, organizationID, invitationID)
+}
+
+// Invitations are accepted when users join the organization, and may be removed with them
+func (s *cachedSDK) UpsertUserOfOrganization(ctx context.Context, organizationID, userID string, body *shared.UpdateOrganizationUserRequest) (*operations.UpsertOrganizationUserResponse, error) {
+	defer s.cache.invalidate(invitationsKey(organizationID))
+	return s.CloudSDK.UpsertUserOfOrganization(ctx, organizationID, userID, body)
+}
+
+func (s *cachedSDK) DeleteUserOfOrganization(ctx context.Context, organizationID, userID string) (*operations.DeleteUserFromOrganizationResponse, error) {
+	defer s.cache.invalidate(invitationsKey(organizationID))
+	return s.CloudSDK.DeleteUserOfOrganization(ctx, organizationID, userID)
+}
+
+var _ pkg.CloudSDK = &cachedSDK{}
@@ -0,0 +1,84 @@
+package internal_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStoreListCache(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	sdk := pkg.NewMockCloudSDK(ctrl)
+	store := internal.NewStore(sdk, pkg.NewMockTokenProviderImpl(ctrl))
+	ctx := logging.TestingContext()
+
+	modules := func(names ...string) *operations.ListModulesResponse {
+		res := &operations.ListModulesResponse{ListModulesResponse: &shared.ListModulesResponse{}}
+		for _, name := range names {
+			res.ListModulesResponse.Data = append(res.ListModulesResponse.Data, shared.Module{Name: name})
+		}
+		return res
+	}
+
+	// Concurrent reads share a single call
+	release := make(chan struct{})
+	sdk.EXPECT().ListModules(gomock.Any(), "org", "stack").DoAndReturn(func(context.Context, string, string) (*operations.ListModulesResponse, error) {
+		<-release
+		return modules("ledger"), nil
+	}).Times(1)
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := store.GetSDK().ListModules(ctx, "org", "stack")
+			require.NoError(t, err)
+			require.Len(t, res.ListModulesResponse.Data, 1)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Responses are cloned, so that callers can modify them
+	res, err := store.GetSDK().ListModules(ctx, "org", "stack")
+	require.NoError(t, err)
+	res.ListModulesResponse.Data[0].Name = "modified"
+
+	res, err = store.GetSDK().ListModules(ctx, "org", "stack")
+	require.NoError(t, err)
+	require.Equal(t, "ledger", res.ListModulesResponse.Data[0].Name)
+
+	// Mutations of the collection invalidate it
+	sdk.EXPECT().EnableModule(gomock.Any(), "org", "stack", "payments").Return(&operations.EnableModuleResponse{}, nil)
+	sdk.EXPECT().ListModules(gomock.Any(), "org", "stack").Return(modules("ledger", "payments"), nil).Times(1)
+
+	_, err = store.GetSDK().EnableModule(ctx, "org", "stack", "payments")
+	require.NoError(t, err)
+	res, err = store.GetSDK().ListModules(ctx, "org", "stack")
+	require.NoError(t, err)
+	require.Len(t, res.ListModulesResponse.Data, 2)
+
+	// Polling bypasses the cache
+	sdk.EXPECT().ListModules(gomock.Any(), "org", "stack").Return(modules("ledger"), nil).Times(1)
+	res, err = store.GetSDK().ListModules(internal.ContextWithoutCache(ctx), "org", "stack")
+	require.NoError(t, err)
+	require.Len(t, res.ListModulesResponse.Data, 1)
+
+	// Other collections are cached independently
+	sdk.EXPECT().ListModules(gomock.Any(), "org", "other").Return(modules(), nil).Times(1)
+	_, err = store.GetSDK().ListModules(ctx, "org", "other")
+	require.NoError(t, err)
+}
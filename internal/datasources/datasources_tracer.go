@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/terraform-provider-cloud/internal"
 	"github.com/formancehq/terraform-provider-cloud/pkg/tracing"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"go.opentelemetry.io/otel/attribute"
@@ -24,6 +25,7 @@ type DatasourcesTracer struct {
 	tracer          trace.Tracer
 	logger          logging.Logger
 	underlyingValue any
+	store           *internal.Store
 }
 
 func injectTraceContext(ctx context.Context, res any, funcName string) context.Context {
@@ -62,6 +64,9 @@ var (
 func (d *DatasourcesTracer) Configure(ctx context.Context, req datasource.ConfigureRequest, res *datasource.ConfigureResponse) {
 	ctx = logging.ContextWithLogger(ctx, d.logger)
 	operation := "Configure"
+	if store, ok := req.ProviderData.(*internal.Store); ok {
+		d.store = store
+	}
 	if v, ok := d.underlyingValue.(datasource.DataSourceWithConfigure); ok {
 		_ = tracing.TraceError(ctx, d.tracer, operation, func(ctx context.Context) error {
 			ctx = injectTraceContext(ctx, d.underlyingValue, operation)
@@ -112,6 +117,14 @@ func (d *DatasourcesTracer) Read(ctx context.Context, req datasource.ReadRequest
 	ctx = logging.ContextWithLogger(ctx, d.logger)
 	operation := "Read"
 	if v, ok := d.underlyingValue.(datasource.DataSource); ok {
+		if d.store != nil {
+			metadata := datasource.MetadataResponse{}
+			v.Metadata(ctx, datasource.MetadataRequest{ProviderTypeName: internal.ProviderTypeName}, &metadata)
+			d.store.CheckScopes(ctx, metadata.TypeName, false, &res.Diagnostics)
+			if res.Diagnostics.HasError() {
+				return
+			}
+		}
 		_ = tracing.TraceError(ctx, d.tracer, operation, func(ctx context.Context) error {
 			ctx = injectTraceContext(ctx, d.underlyingValue, operation)
 			logging.FromContext(ctx).Debug("call")
@@ -2,6 +2,7 @@ package datasources
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"slices"
 	"sort"
@@ -11,6 +12,7 @@ import (
 	"github.com/formancehq/terraform-provider-cloud/pkg"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
@@ -129,6 +131,14 @@ func (r *RegionVersions) Read(ctx context.Context, req datasource.ReadRequest, r
 
 	operation, err := r.store.GetSDK().GetRegionVersions(ctx, organizationId, regionID)
 	if err != nil {
+		if errors.Is(err, pkg.ErrNotFound) {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("id"),
+				"Region not found",
+				fmt.Sprintf("No region found with ID '%s' in organization '%s'", regionID, organizationId),
+			)
+			return
+		}
 		pkg.HandleSDKError(ctx, err, &resp.Diagnostics)
 		return
 	}
@@ -2,6 +2,7 @@ package datasources
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/formancehq/go-libs/v3/collectionutils"
@@ -106,6 +107,14 @@ func (r *Region) Read(ctx context.Context, req datasource.ReadRequest, resp *dat
 	case !data.ID.IsNull():
 		operation, err := r.store.GetSDK().GetRegion(ctx, organizationId, data.ID.ValueString())
 		if err != nil {
+			if errors.Is(err, pkg.ErrNotFound) {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("id"),
+					"Region not found",
+					fmt.Sprintf("No region found with ID '%s' in organization '%s'", data.ID.ValueString(), organizationId),
+				)
+				return
+			}
 			pkg.HandleSDKError(ctx, err, &resp.Diagnostics)
 			return
 		}
@@ -0,0 +1,171 @@
+package datasources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/internal/resources"
+	"github.com/formancehq/terraform-provider-cloud/internal/versions"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/hashicorp/terraform-plugin-framework-validators/datasourcevalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ datasource.DataSource                     = &StackUpgradePath{}
+	_ datasource.DataSourceWithConfigure        = &StackUpgradePath{}
+	_ datasource.DataSourceWithConfigValidators = &StackUpgradePath{}
+)
+
+type StackUpgradePath struct {
+	store *internal.Store
+}
+
+var SchemaStackUpgradePath = schema.Schema{
+	Description: "Computes the ordered list of versions to go through to upgrade a stack to a target version. Every minor release line between both versions is visited once, using its newest non deprecated patch.",
+	Attributes: map[string]schema.Attribute{
+		"stack_id": schema.StringAttribute{
+			Description: "The ID of the stack to upgrade. Its region and current version are used as starting point.",
+			Optional:    true,
+		},
+		"region_id": schema.StringAttribute{
+			Description: "The region to read the available versions from. Required when stack_id is not set.",
+			Optional:    true,
+			Computed:    true,
+		},
+		"from_version": schema.StringAttribute{
+			Description: "The version to upgrade from. Required when stack_id is not set.",
+			Optional:    true,
+			Computed:    true,
+		},
+		"to_version": schema.StringAttribute{
+			Description: "The version to upgrade to.",
+			Required:    true,
+		},
+		"steps": schema.ListAttribute{
+			Description: "The ordered versions to upgrade through, the last one being to_version.",
+			Computed:    true,
+			ElementType: types.StringType,
+		},
+	},
+}
+
+type StackUpgradePathModel struct {
+	StackID     types.String `tfsdk:"stack_id"`
+	RegionID    types.String `tfsdk:"region_id"`
+	FromVersion types.String `tfsdk:"from_version"`
+	ToVersion   types.String `tfsdk:"to_version"`
+	Steps       types.List   `tfsdk:"steps"`
+}
+
+func NewStackUpgradePath() func() datasource.DataSource {
+	return func() datasource.DataSource {
+		return &StackUpgradePath{}
+	}
+}
+
+// ConfigValidators implements datasource.DataSourceWithConfigValidators.
+func (s *StackUpgradePath) ConfigValidators(context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		datasourcevalidator.ExactlyOneOf(
+			path.MatchRoot("stack_id"),
+			path.MatchRoot("region_id"),
+		),
+		datasourcevalidator.RequiredTogether(
+			path.MatchRoot("region_id"),
+			path.MatchRoot("from_version"),
+		),
+	}
+}
+
+// Configure implements datasource.DataSourceWithConfigure.
+func (s *StackUpgradePath) Configure(ctx context.Context, req datasource.ConfigureRequest, res *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	store, ok := req.ProviderData.(*internal.Store)
+	if !ok {
+		res.Diagnostics.AddError(
+			resources.ErrProviderDataNotSet.Error(),
+			fmt.Sprintf("Expected *internal.Store, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	s.store = store
+}
+
+func (s *StackUpgradePath) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_stack_upgrade_path"
+}
+
+func (s *StackUpgradePath) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = SchemaStackUpgradePath
+}
+
+func (s *StackUpgradePath) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data StackUpgradePathModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	if !data.StackID.IsNull() {
+		operation, err := s.store.GetSDK().ReadStack(ctx, organizationId, data.StackID.ValueString())
+		if err != nil {
+			if errors.Is(err, pkg.ErrNotFound) {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("stack_id"),
+					"Stack not found",
+					fmt.Sprintf("No stack found with ID '%s' in organization '%s'", data.StackID.ValueString(), organizationId),
+				)
+				return
+			}
+			pkg.HandleSDKError(ctx, err, &resp.Diagnostics)
+			return
+		}
+
+		stack := operation.CreateStackResponse.Data
+		data.RegionID = types.StringValue(stack.RegionID)
+		data.FromVersion = types.StringValue("")
+		if stack.Version != nil {
+			data.FromVersion = types.StringValue(*stack.Version)
+		}
+	}
+
+	operation, err := s.store.GetSDK().GetRegionVersions(ctx, organizationId, data.RegionID.ValueString())
+	if err != nil {
+		pkg.HandleSDKError(ctx, err, &resp.Diagnostics)
+		return
+	}
+
+	steps, err := versions.UpgradePath(data.FromVersion.ValueString(), data.ToVersion.ValueString(), operation.GetRegionVersionsResponse.Data)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("to_version"),
+			"Unable to compute upgrade path",
+			err.Error(),
+		)
+		return
+	}
+
+	list, diags := types.ListValueFrom(ctx, types.StringType, steps)
+	resp.Diagnostics.Append(diags...)
+	data.Steps = list
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,123 @@
+package datasources_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/go-libs/v3/pointer"
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/internal/datasources"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStackUpgradePathMetadata(t *testing.T) {
+	ctx := logging.TestingContext()
+	og := datasources.NewStackUpgradePath()().(datasource.DataSourceWithConfigure)
+
+	res := datasource.MetadataResponse{}
+
+	og.Metadata(ctx, datasource.MetadataRequest{
+		ProviderTypeName: "test",
+	}, &res)
+
+	require.Contains(t, res.TypeName, "_stack_upgrade_path")
+}
+
+func TestStackUpgradePathRead(t *testing.T) {
+	type testCase struct {
+		name     string
+		stackID  *string
+		regionID *string
+		from     *string
+	}
+
+	for _, tc := range []testCase{
+		{
+			name:     "from region",
+			regionID: pointer.For("staging"),
+			from:     pointer.For("v1.10.0"),
+		},
+		{
+			name:    "from stack",
+			stackID: pointer.For(uuid.NewString()),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := logging.TestingContext()
+			organizationId := uuid.NewString()
+			ctrl := gomock.NewController(t)
+			tp := pkg.NewMockTokenProviderImpl(ctrl)
+			apiMock := pkg.NewMockCloudSDK(ctrl)
+			tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+
+			d := datasources.NewStackUpgradePath()().(datasource.DataSourceWithConfigure)
+			configureRes := datasource.ConfigureResponse{}
+			d.Configure(ctx, datasource.ConfigureRequest{
+				ProviderData: internal.NewStore(apiMock, tp),
+			}, &configureRes)
+			require.Empty(t, configureRes.Diagnostics)
+
+			if tc.stackID != nil {
+				apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, *tc.stackID).Return(&operations.GetStackResponse{
+					StatusCode: http.StatusOK,
+					CreateStackResponse: &shared.CreateStackResponse{
+						Data: &shared.Stack{
+							ID:       *tc.stackID,
+							RegionID: "staging",
+							Version:  pointer.For("v1.10.0"),
+						},
+					},
+				}, nil)
+			}
+			apiMock.EXPECT().GetRegionVersions(gomock.Any(), organizationId, "staging").Return(&operations.GetRegionVersionsResponse{
+				StatusCode: http.StatusOK,
+				GetRegionVersionsResponse: &shared.GetRegionVersionsResponse{
+					Data: []shared.Version{
+						{Name: "v1.10.0"},
+						{Name: "v2.0.0"},
+						{Name: "v2.0.3"},
+						{Name: "v2.1.0"},
+					},
+				},
+			}, nil)
+
+			res := datasource.ReadResponse{
+				State: tfsdk.State{
+					Schema: datasources.SchemaStackUpgradePath,
+				},
+			}
+			d.Read(ctx, datasource.ReadRequest{
+				Config: tfsdk.Config{
+					Raw: tftypes.NewValue(tftypes.Object{
+						AttributeTypes: getSchemaTypes(datasources.SchemaStackUpgradePath),
+					}, map[string]tftypes.Value{
+						"stack_id":     tftypes.NewValue(tftypes.String, tc.stackID),
+						"region_id":    tftypes.NewValue(tftypes.String, tc.regionID),
+						"from_version": tftypes.NewValue(tftypes.String, tc.from),
+						"to_version":   tftypes.NewValue(tftypes.String, "v2.1.0"),
+						"steps":        tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil),
+					}),
+					Schema: datasources.SchemaStackUpgradePath,
+				},
+			}, &res)
+			require.Empty(t, res.Diagnostics)
+
+			model := datasources.StackUpgradePathModel{}
+			res.State.Get(ctx, &model)
+			steps := []string{}
+			model.Steps.ElementsAs(ctx, &steps, false)
+			require.Equal(t, []string{"v2.0.3", "v2.1.0"}, steps)
+			require.Equal(t, "staging", model.RegionID.ValueString())
+		})
+	}
+}
@@ -2,6 +2,7 @@ package datasources
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -122,6 +123,14 @@ func (s *Stack) Read(ctx context.Context, req datasource.ReadRequest, resp *data
 	case data.ID.ValueString() != "":
 		operation, err := s.store.GetSDK().ReadStack(ctx, organizationId, data.ID.ValueString())
 		if err != nil {
+			if errors.Is(err, pkg.ErrNotFound) {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("id"),
+					"Stack not found",
+					fmt.Sprintf("No stack found with ID '%s' in organization '%s'", data.ID.ValueString(), organizationId),
+				)
+				return
+			}
 			pkg.HandleSDKError(ctx, err, &resp.Diagnostics)
 			return
 		}
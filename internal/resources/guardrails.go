@@ -0,0 +1,176 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const policyViolation = "Policy Violation"
+
+// checkModuleGuardrails reports the modules the guardrails of the provider do not allow.
+func checkModuleGuardrails(guardrails internal.Guardrails, names map[string]path.Path, diags *diag.Diagnostics) {
+	if len(guardrails.AllowedModules) == 0 {
+		return
+	}
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		if !slices.Contains(guardrails.AllowedModules, name) {
+			diags.AddAttributeError(
+				names[name],
+				policyViolation,
+				fmt.Sprintf("Module '%s' is not allowed by the guardrails of the provider. Allowed modules: %s.", name, strings.Join(guardrails.AllowedModules, ", ")),
+			)
+		}
+	}
+}
+
+// checkRegionGuardrails reports the region if the guardrails of the provider do not allow it.
+func checkRegionGuardrails(guardrails internal.Guardrails, regionID string, diags *diag.Diagnostics) {
+	if len(guardrails.AllowedRegionIDs) > 0 && regionID != "" && !slices.Contains(guardrails.AllowedRegionIDs, regionID) {
+		diags.AddAttributeError(
+			path.Root("region_id"),
+			policyViolation,
+			fmt.Sprintf("Region '%s' is not allowed by the guardrails of the provider. Allowed regions: %s.", regionID, strings.Join(guardrails.AllowedRegionIDs, ", ")),
+		)
+	}
+}
+
+// checkNameGuardrails reports the name of the stack if it does not match the pattern of the guardrails of the provider.
+func checkNameGuardrails(guardrails internal.Guardrails, name types.String, diags *diag.Diagnostics) {
+	if guardrails.StackNamePattern != nil && !name.IsUnknown() && name.ValueString() != "" &&
+		!guardrails.StackNamePattern.MatchString(name.ValueString()) {
+		diags.AddAttributeError(
+			path.Root("name"),
+			policyViolation,
+			fmt.Sprintf("Stack name '%s' does not match the pattern '%s' of the guardrails of the provider.", name.ValueString(), guardrails.StackNamePattern),
+		)
+	}
+}
+
+// checkMetadataGuardrails reports the metadata keys required by the guardrails of the provider which the metadata does not set.
+func checkMetadataGuardrails(guardrails internal.Guardrails, metadata map[string]string, attribute path.Path, diags *diag.Diagnostics) {
+	var missing []string
+	for _, key := range guardrails.RequiredMetadataKeys {
+		if _, ok := metadata[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		diags.AddAttributeError(
+			attribute,
+			policyViolation,
+			fmt.Sprintf("The metadata of the stack must set the keys required by the guardrails of the provider, missing: %s.", strings.Join(missing, ", ")),
+		)
+	}
+}
+
+// validateGuardrails checks the configuration of the stack against the guardrails of the provider,
+// falling back to the defaults of the provider for the region and the metadata.
+func (s *Stack) validateGuardrails(config StackModel, diags *diag.Diagnostics) {
+	guardrails := s.store.GetGuardrails()
+	defaults := s.store.GetStackDefaults()
+
+	if config.RegionID.IsNull() {
+		checkRegionGuardrails(guardrails, defaults.RegionID, diags)
+	} else if !config.RegionID.IsUnknown() {
+		checkRegionGuardrails(guardrails, config.RegionID.ValueString(), diags)
+	}
+
+	checkNameGuardrails(guardrails, config.Name, diags)
+
+	if !config.Metadata.IsUnknown() {
+		metadata := maps.Clone(defaults.Metadata)
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		for key := range config.Metadata.Elements() {
+			metadata[key] = ""
+		}
+		checkMetadataGuardrails(guardrails, metadata, path.Root("metadata"), diags)
+	}
+
+	if !config.Modules.IsUnknown() {
+		names := map[string]path.Path{}
+		for _, element := range config.Modules.Elements() {
+			if name, ok := element.(types.String); ok && !name.IsUnknown() {
+				names[name.ValueString()] = path.Root("modules").AtSetValue(name)
+			}
+		}
+		checkModuleGuardrails(guardrails, names, diags)
+	}
+}
+
+// planMaxStacks counts the creation of the stack against the max_stacks guardrail of the provider.
+func (s *Stack) planMaxStacks(ctx context.Context, plan StackModel, diags *diag.Diagnostics) {
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		diags.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+	checkMaxStacks(ctx, s.store, organizationId, plannedStackKey(organizationId, plan), plan.GetName(), diags)
+}
+
+// plannedStackKey returns the key counting the creation of the stack against max_stacks: the idempotency key
+// the creation uses, which identifies the stack it may adopt, or an empty key when it is not known yet.
+func plannedStackKey(organizationID string, plan StackModel) string {
+	if key := plan.IdempotencyKey.ValueString(); !plan.IdempotencyKey.IsUnknown() && key != "" {
+		return key
+	}
+	if plan.Name.IsUnknown() || (plan.GetName() == "" && plan.Metadata.IsUnknown()) {
+		return ""
+	}
+	return defaultIdempotencyKey(organizationID, plan)
+}
+
+// checkMaxStacks reports an error if creating the stack, along with the other stacks planned for
+// creation during the run, exceeds the maximum number of stacks of the organization. The key identifies
+// the stack the creation may adopt, an empty key never adopts any stack.
+func checkMaxStacks(ctx context.Context, store *internal.Store, organizationID, key, name string, diags *diag.Diagnostics) {
+	maxStacks := store.GetGuardrails().MaxStacks
+	planned := store.PlanStack(key)
+
+	operation, err := store.GetSDK().ListStacks(ctx, organizationID)
+	if err != nil {
+		pkg.HandleSDKError(ctx, err, diags)
+		return
+	}
+
+	// Planned creations adopting a stack left by a previous apply do not add a stack
+	existing := 0
+	adoptable := map[string]int{}
+	for _, stack := range operation.ListStacksResponse.Data {
+		if isStackDeleted(stack) {
+			continue
+		}
+		existing++
+		if key := stack.Metadata[MetadataIdempotencyKey]; key != "" && !store.IsStackClaimed(stack.ID) &&
+			stack.Metadata[MetadataProtectedKey] == stackMarker(store.GetWorkspaceID()) {
+			adoptable[key]++
+		}
+	}
+	created := 0
+	for key, count := range planned {
+		if key != "" {
+			count -= adoptable[key]
+		}
+		created += max(count, 0)
+	}
+
+	if existing+created > maxStacks {
+		diags.AddError(
+			policyViolation,
+			fmt.Sprintf("Creating stack '%s' brings the organization to %d stacks, %d existing and %d planned, the guardrails of the provider allow at most %d.", name, existing+created, existing, created, maxStacks),
+		)
+	}
+}
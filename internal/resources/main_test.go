@@ -2,11 +2,18 @@ package resources_test
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/go-libs/v3/pointer"
+	"github.com/formancehq/terraform-provider-cloud/internal/resources"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"go.uber.org/mock/gomock"
 )
 
 func test(t *testing.T, fn func(ctx context.Context)) {
@@ -27,3 +34,171 @@ func getSchemaTypes(schema schema.Schema) map[string]tftypes.Type {
 
 	return attributeTypes
 }
+
+func stackValue(values map[string]tftypes.Value) tftypes.Value {
+	attributes := map[string]tftypes.Value{
+		"id":                  tftypes.NewValue(tftypes.String, nil),
+		"name":                tftypes.NewValue(tftypes.String, "test"),
+		"region_id":           tftypes.NewValue(tftypes.String, "staging"),
+		"version":             tftypes.NewValue(tftypes.String, nil),
+		"version_constraint":  tftypes.NewValue(tftypes.String, nil),
+		"auto_upgrade":        tftypes.NewValue(tftypes.Bool, nil),
+		"upgrade_mode":        tftypes.NewValue(tftypes.String, nil),
+		"modules":             tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, nil),
+		"module_status":       tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+		"force_destroy":       tftypes.NewValue(tftypes.Bool, nil),
+		"adopt":               tftypes.NewValue(tftypes.Bool, nil),
+		"deletion_protection": tftypes.NewValue(tftypes.Bool, true),
+		"idempotency_key":     tftypes.NewValue(tftypes.String, nil),
+		"metadata_all":        tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+		"uri":                 tftypes.NewValue(tftypes.String, nil),
+		"metadata": tftypes.NewValue(tftypes.Map{
+			ElementType: tftypes.String,
+		}, nil),
+	}
+	for k, v := range values {
+		attributes[k] = v
+	}
+	return tftypes.NewValue(tftypes.Object{
+		AttributeTypes: getSchemaTypes(resources.SchemaStack),
+	}, attributes)
+}
+
+// expectManagedStack expects the stack to be read before being mutated, and returns it marked as managed by Terraform.
+func expectManagedStack(apiMock *pkg.MockCloudSDK, organizationId, stackID string) {
+	apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, stackID).Return(&operations.GetStackResponse{
+		StatusCode: http.StatusOK,
+		CreateStackResponse: &shared.CreateStackResponse{
+			Data: &shared.Stack{
+				ID:     stackID,
+				Status: shared.StackStatusReady,
+				Metadata: map[string]string{
+					resources.MetadataProtectedKey:          "true",
+					resources.MetadataDeletionProtectionKey: "true",
+				},
+			},
+		},
+	}, nil)
+}
+
+func stackCloneValue(values map[string]tftypes.Value) tftypes.Value {
+	attributes := map[string]tftypes.Value{
+		"id":              tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+		"source_stack_id": tftypes.NewValue(tftypes.String, "source"),
+		"name":            tftypes.NewValue(tftypes.String, "clone"),
+		"region_id":       tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+		"force_destroy":   tftypes.NewValue(tftypes.Bool, nil),
+		"uri":             tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+		"version":         tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+		"modules":         tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, tftypes.UnknownValue),
+		"user_accesses":   tftypes.NewValue(tftypes.Map{ElementType: tftypes.Number}, tftypes.UnknownValue),
+		"metadata":        tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, tftypes.UnknownValue),
+	}
+	for k, v := range values {
+		attributes[k] = v
+	}
+	return tftypes.NewValue(tftypes.Object{
+		AttributeTypes: getSchemaTypes(resources.SchemaStackClone),
+	}, attributes)
+}
+
+func expectStackSnapshot(apiMock *pkg.MockCloudSDK, organizationId, stackID string, modules []string, accesses map[string]int64) {
+	apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, stackID).Return(&operations.GetStackResponse{
+		StatusCode: http.StatusOK,
+		CreateStackResponse: &shared.CreateStackResponse{
+			Data: &shared.Stack{
+				ID:       stackID,
+				RegionID: "staging",
+				Version:  pointer.For("v2.1.0"),
+				Metadata: map[string]string{
+					"team":                                  "payments",
+					resources.MetadataDeletionProtectionKey: "true",
+				},
+			},
+		},
+	}, nil)
+
+	list := make([]shared.Module, 0, len(modules))
+	for _, name := range modules {
+		list = append(list, shared.Module{Name: name, State: shared.ModuleStateEnabled, Status: shared.ModuleStatusReady})
+	}
+	apiMock.EXPECT().ListModules(gomock.Any(), organizationId, stackID).Return(&operations.ListModulesResponse{
+		StatusCode: http.StatusOK,
+		ListModulesResponse: &shared.ListModulesResponse{
+			Data: list,
+		},
+	}, nil)
+
+	data := make([]shared.StackUserAccessResponseData, 0, len(accesses))
+	for userID, policyID := range accesses {
+		data = append(data, shared.StackUserAccessResponseData{StackID: stackID, UserID: userID, PolicyID: policyID})
+	}
+	apiMock.EXPECT().ListStackUsersAccesses(gomock.Any(), organizationId, stackID).Return(&operations.ListStackUsersAccessesResponse{
+		StatusCode: http.StatusOK,
+		StackUserAccessResponse: &shared.StackUserAccessResponse{
+			Data: data,
+		},
+	}, nil)
+}
+
+func stackRolloutValue(version string, batches [][]string, completed *int64, status *string) tftypes.Value {
+	batchType := tftypes.List{ElementType: tftypes.String}
+	list := make([]tftypes.Value, 0, len(batches))
+	for _, batch := range batches {
+		ids := make([]tftypes.Value, 0, len(batch))
+		for _, id := range batch {
+			ids = append(ids, tftypes.NewValue(tftypes.String, id))
+		}
+		list = append(list, tftypes.NewValue(batchType, ids))
+	}
+
+	values := map[string]tftypes.Value{
+		"id":                tftypes.NewValue(tftypes.String, "rollout"),
+		"version":           tftypes.NewValue(tftypes.String, version),
+		"batches":           tftypes.NewValue(tftypes.List{ElementType: batchType}, list),
+		"completed_batches": tftypes.NewValue(tftypes.Number, tftypes.UnknownValue),
+		"status":            tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+	}
+	if completed != nil {
+		values["completed_batches"] = tftypes.NewValue(tftypes.Number, *completed)
+	}
+	if status != nil {
+		values["status"] = tftypes.NewValue(tftypes.String, *status)
+	}
+
+	return tftypes.NewValue(tftypes.Object{
+		AttributeTypes: getSchemaTypes(resources.SchemaStackRollout),
+	}, values)
+}
+
+func expectStackUpgrade(apiMock *pkg.MockCloudSDK, organizationId, stackID, version string, upgradeErr error) {
+	apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, stackID).Return(&operations.GetStackResponse{
+		StatusCode: http.StatusOK,
+		CreateStackResponse: &shared.CreateStackResponse{
+			Data: &shared.Stack{
+				ID:       stackID,
+				Version:  pointer.For("v2.0.0"),
+				Status:   shared.StackStatusReady,
+				Metadata: map[string]string{resources.MetadataProtectedKey: "true"},
+			},
+		},
+	}, nil)
+	if upgradeErr != nil {
+		apiMock.EXPECT().UpgradeStack(gomock.Any(), organizationId, stackID, version).Return(nil, upgradeErr)
+		return
+	}
+	apiMock.EXPECT().UpgradeStack(gomock.Any(), organizationId, stackID, version).Return(&operations.UpgradeStackResponse{
+		StatusCode: http.StatusAccepted,
+	}, nil)
+	apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, stackID).Return(&operations.GetStackResponse{
+		StatusCode: http.StatusOK,
+		CreateStackResponse: &shared.CreateStackResponse{
+			Data: &shared.Stack{
+				ID:        stackID,
+				Version:   pointer.For(version),
+				Status:    shared.StackStatusReady,
+				Reachable: true,
+			},
+		},
+	}, nil)
+}
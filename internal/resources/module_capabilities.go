@@ -0,0 +1,86 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+// checkModuleNames validates module names against the capabilities advertised by the region.
+// Unknown modules are reported as errors with the closest match as suggestion, enterprise
+// modules as warnings. Regions not advertising their module list are not checked.
+func checkModuleNames(ctx context.Context, sdk pkg.CloudSDK, organizationID, regionID string, names map[string]path.Path, diags *diag.Diagnostics) {
+	operation, err := sdk.GetRegion(ctx, organizationID, regionID)
+	if err != nil {
+		pkg.HandleSDKError(ctx, err, diags)
+		return
+	}
+
+	capabilities := operation.GetRegionResponse.Data.Capabilities
+	available := append(slices.Clone(capabilities.ModuleList), capabilities.Ee...)
+	if len(available) == 0 {
+		return
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		p := names[name]
+		if !slices.Contains(available, name) {
+			detail := fmt.Sprintf("Module '%s' is not available in region '%s'.", name, regionID)
+			if suggestion := closestModule(name, available); suggestion != "" {
+				detail += fmt.Sprintf(" Did you mean '%s'?", suggestion)
+			}
+			sorted := slices.Clone(available)
+			slices.Sort(sorted)
+			detail += fmt.Sprintf(" Available modules: %s.", strings.Join(slices.Compact(sorted), ", "))
+			diags.AddAttributeError(p, "Unknown Module", detail)
+			continue
+		}
+
+		if slices.Contains(capabilities.Ee, name) {
+			diags.AddAttributeWarning(
+				p,
+				"Enterprise Module",
+				fmt.Sprintf("Module '%s' is an enterprise module. Enabling it requires a Formance Enterprise license.", name),
+			)
+		}
+	}
+}
+
+// closestModule returns the candidate closest to name, or an empty string if none is close enough.
+func closestModule(name string, candidates []string) string {
+	best := ""
+	bestDistance := max(2, len(name)/3) + 1
+	for _, candidate := range candidates {
+		if d := levenshtein(strings.ToLower(name), strings.ToLower(candidate)); d < bestDistance {
+			best = candidate
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
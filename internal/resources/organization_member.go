@@ -2,8 +2,8 @@ package resources
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/formancehq/terraform-provider-cloud/internal"
@@ -173,9 +173,9 @@ func (s *OrganizationMember) Delete(ctx context.Context, req resource.DeleteRequ
 
 	switch invitation.Status {
 	case shared.InvitationStatusPending:
-		operation, err := s.store.GetSDK().DeleteInvitation(ctx, organizationId, state.GetID())
+		_, err := s.store.GetSDK().DeleteInvitation(ctx, organizationId, state.GetID())
 		if err != nil {
-			if operation.StatusCode == http.StatusNotFound {
+			if errors.Is(err, pkg.ErrNotFound) {
 				res.Diagnostics.AddWarning(
 					"Invitation not found",
 					"The invitation was not found. It may have already been deleted outside of Terraform.",
@@ -186,9 +186,9 @@ func (s *OrganizationMember) Delete(ctx context.Context, req resource.DeleteRequ
 			return
 		}
 	case shared.InvitationStatusAccepted:
-		operation, err := s.store.GetSDK().DeleteUserOfOrganization(ctx, organizationId, state.UserId.ValueString())
+		_, err := s.store.GetSDK().DeleteUserOfOrganization(ctx, organizationId, state.UserId.ValueString())
 		if err != nil {
-			if operation.StatusCode == http.StatusNotFound {
+			if errors.Is(err, pkg.ErrNotFound) {
 				res.Diagnostics.AddWarning(
 					"User not found",
 					"The user was not found. They may have already been removed outside of Terraform.",
@@ -262,7 +262,7 @@ func (s *OrganizationMember) Read(ctx context.Context, req resource.ReadRequest,
 	case shared.InvitationStatusAccepted:
 		operation, err := s.store.GetSDK().ReadUserOfOrganization(ctx, organizationId, state.UserId.ValueString())
 		if err != nil {
-			if operation.StatusCode == http.StatusNotFound {
+			if errors.Is(err, pkg.ErrNotFound) {
 				res.State.RemoveResource(ctx)
 				return
 			}
@@ -348,8 +348,8 @@ func (s *OrganizationMember) Update(ctx context.Context, req resource.UpdateRequ
 		if time.Now().Before(*invitation.ExpiresAt) {
 			return
 		}
-		deleteoperation, err := s.store.GetSDK().DeleteInvitation(ctx, organizationId, state.GetID())
-		if err != nil && deleteoperation.StatusCode != http.StatusNotFound {
+		_, err := s.store.GetSDK().DeleteInvitation(ctx, organizationId, state.GetID())
+		if err != nil && !errors.Is(err, pkg.ErrNotFound) {
 			pkg.HandleSDKError(ctx, err, &res.Diagnostics)
 			return
 
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/terraform-provider-cloud/internal"
 	"github.com/formancehq/terraform-provider-cloud/pkg/tracing"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"go.opentelemetry.io/otel/attribute"
@@ -20,6 +21,7 @@ var (
 	_ resource.ResourceWithImportState      = &ResourceTracer{}
 	_ resource.ResourceWithValidateConfig   = &ResourceTracer{}
 	_ resource.ResourceWithConfigValidators = &ResourceTracer{}
+	_ resource.ResourceWithModifyPlan       = &ResourceTracer{}
 )
 var (
 	ErrValidateConfig = fmt.Errorf("error during ValidateConfig")
@@ -30,6 +32,7 @@ var (
 	ErrUpdate         = fmt.Errorf("error during Update")
 	ErrDelete         = fmt.Errorf("error during Delete")
 	ErrImportState    = fmt.Errorf("error during ImportState")
+	ErrModifyPlan     = fmt.Errorf("error during ModifyPlan")
 )
 
 func injectTraceContext(ctx context.Context, res any, funcName string) context.Context {
@@ -60,6 +63,21 @@ type ResourceTracer struct {
 	tracer          trace.Tracer
 	logger          logging.Logger
 	underlyingValue any
+	store           *internal.Store
+}
+
+// checkScopes reports the scopes the plan needs which are not granted to the token.
+// Plans without changes only need the read scopes.
+func (r *ResourceTracer) checkScopes(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	v, ok := r.underlyingValue.(resource.Resource)
+	if !ok || r.store == nil {
+		return
+	}
+	metadata := resource.MetadataResponse{}
+	v.Metadata(ctx, resource.MetadataRequest{ProviderTypeName: internal.ProviderTypeName}, &metadata)
+
+	write := req.Plan.Raw.IsNull() || !req.Plan.Raw.Equal(req.State.Raw)
+	r.store.CheckScopes(ctx, metadata.TypeName, write, &resp.Diagnostics)
 }
 
 func NewResourceTracer(tracer trace.Tracer, logger logging.Logger, res func() resource.Resource) func() resource.Resource {
@@ -126,10 +144,35 @@ func (r *ResourceTracer) ImportState(ctx context.Context, req resource.ImportSta
 	}
 }
 
+// ModifyPlan implements resource.ResourceWithModifyPlan.
+func (r *ResourceTracer) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	operation := "ModifyPlan"
+	ctx = logging.ContextWithLogger(ctx, r.logger)
+	r.checkScopes(ctx, req, resp)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if v, ok := r.underlyingValue.(resource.ResourceWithModifyPlan); ok {
+		_ = tracing.TraceError(ctx, r.tracer, operation, func(ctx context.Context) error {
+			ctx = injectTraceContext(ctx, v, operation)
+			logging.FromContext(ctx).Debug("call")
+			defer logging.FromContext(ctx).Debug("completed")
+			v.ModifyPlan(ctx, req, resp)
+			if resp.Diagnostics.HasError() {
+				return ErrModifyPlan
+			}
+			return nil
+		})
+	}
+}
+
 // Configure implements resource.ResourceWithConfigure.
 func (r *ResourceTracer) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
 	ctx = logging.ContextWithLogger(ctx, r.logger)
 	operation := "Configure"
+	if store, ok := req.ProviderData.(*internal.Store); ok {
+		r.store = store
+	}
 	if v, ok := r.underlyingValue.(resource.ResourceWithConfigure); ok {
 		_ = tracing.TraceError(ctx, r.tracer, operation, func(ctx context.Context) error {
 			ctx = injectTraceContext(ctx, v, operation)
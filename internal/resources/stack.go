@@ -2,28 +2,58 @@ package resources
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"maps"
+	"strconv"
 
+	"github.com/formancehq/go-libs/v3/logging"
 	"github.com/formancehq/go-libs/v3/pointer"
 	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/internal/versions"
 	"github.com/formancehq/terraform-provider-cloud/pkg"
 	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/hashicorp/terraform-plugin-framework-validators/boolvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/resourcevalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
-	"golang.org/x/mod/semver"
 )
 
 var (
-	_ resource.Resource                   = &Stack{}
-	_ resource.ResourceWithConfigure      = &Stack{}
-	_ resource.ResourceWithValidateConfig = &Stack{}
-	_ resource.ResourceWithImportState    = &Stack{}
+	_ resource.Resource                     = &Stack{}
+	_ resource.ResourceWithConfigure        = &Stack{}
+	_ resource.ResourceWithValidateConfig   = &Stack{}
+	_ resource.ResourceWithImportState      = &Stack{}
+	_ resource.ResourceWithModifyPlan       = &Stack{}
+	_ resource.ResourceWithConfigValidators = &Stack{}
+)
+
+const (
+	// UpgradeModeDirect upgrades the stack to the target version in a single call.
+	UpgradeModeDirect = "direct"
+	// UpgradeModeStepwise upgrades the stack through every intermediate release line.
+	UpgradeModeStepwise = "stepwise"
+)
+
+const (
+	// MetadataProtectedKey marks stacks managed by this provider, with the workspace owning them
+	// or true when the provider sets no workspace_id.
+	MetadataProtectedKey = "github.com/formancehq/terraform-provider-cloud/protected"
+	// MetadataDeletionProtectionKey mirrors the deletion_protection attribute so other tools can honor it.
+	MetadataDeletionProtectionKey = "github.com/formancehq/terraform-provider-cloud/deletion_protection"
+	// MetadataIdempotencyKey marks the stack created for a resource, to adopt it when the creation response is lost.
+	MetadataIdempotencyKey = "github.com/formancehq/terraform-provider-cloud/idempotency_key"
 )
 
 var SchemaStack = schema.Schema{
@@ -39,24 +69,80 @@ var SchemaStack = schema.Schema{
 			Computed:    true,
 		},
 		"region_id": schema.StringAttribute{
-			Description: "The region ID where the stack will be deployed.",
-			Required:    true,
+			Description: "The region ID where the stack will be deployed. Defaults to the region_id of the defaults of the provider.",
+			Optional:    true,
+			Computed:    true,
+			PlanModifiers: []planmodifier.String{
+				stringplanmodifier.UseStateForUnknown(),
+			},
 		},
 		"version": schema.StringAttribute{
-			Description: "The version of Formance to deploy. If not specified, the latest version will be used.",
+			Description: "The version of Formance to deploy. If not specified, the stack is created with the version of the defaults of the provider, or the latest version. When version_constraint is set, holds the resolved version. Stacks are upgraded when it changes, and cannot be downgraded.",
 			Optional:    true,
 			Computed:    true,
+			PlanModifiers: []planmodifier.String{
+				stringplanmodifier.UseStateForUnknown(),
+			},
+		},
+		"version_constraint": schema.StringAttribute{
+			Description: "A version constraint (e.g. `~> v2.2` or `>= v2.0, < v3.0`) resolved at plan time against the versions available in the region. Conflicts with version.",
+			Optional:    true,
+		},
+		"auto_upgrade": schema.BoolAttribute{
+			Description: "When set to true, the stack is upgraded to the newest non deprecated version matching version_constraint as soon as it is available in the region. Requires version_constraint.",
+			Optional:    true,
+			Validators: []validator.Bool{
+				boolvalidator.AlsoRequires(path.MatchRoot("version_constraint")),
+			},
+		},
+		"upgrade_mode": schema.StringAttribute{
+			Description: "How version upgrades are applied. `direct` (default) upgrades to the target version in a single call. `stepwise` walks the path returned by the cloud_stack_upgrade_path data source and waits for the stack to be READY between each hop.",
+			Optional:    true,
+			Validators: []validator.String{
+				stringvalidator.OneOf(UpgradeModeDirect, UpgradeModeStepwise),
+			},
+		},
+		"adopt": schema.BoolAttribute{
+			Description: "When set to true, the provider manages a stack it did not create, such as an imported stack, and marks it as managed by Terraform. Plans of stacks without the marker fail until they are adopted.",
+			Optional:    true,
 		},
 		"force_destroy": schema.BoolAttribute{
 			Description: "When set to true, the stack will be forcefully deleted even if it contains data. Use with caution.",
 			Optional:    true,
 		},
+		"deletion_protection": schema.BoolAttribute{
+			Description: "When set to true, the stack cannot be destroyed. It must be set to false in a prior apply before the stack can be deleted. Defaults to true.",
+			Optional:    true,
+			Computed:    true,
+			Default:     booldefault.StaticBool(true),
+		},
 		"uri": schema.StringAttribute{
 			Description: "The URI of the deployed stack.",
 			Computed:    true,
 		},
+		"modules": schema.SetAttribute{
+			Description: "The modules enabled on the stack. When set, the list is authoritative: modules missing from it are disabled, including modules enabled outside of Terraform. Must not be combined with cloud_stack_module resources targeting the same stack.",
+			Optional:    true,
+			ElementType: types.StringType,
+		},
+		"module_status": schema.MapAttribute{
+			Description: "The status of each module enabled on the stack, keyed by module name. Only set when modules is set.",
+			Computed:    true,
+			ElementType: types.StringType,
+			PlanModifiers: []planmodifier.Map{
+				mapplanmodifier.UseStateForUnknown(),
+			},
+		},
+		"idempotency_key": schema.StringAttribute{
+			Description: "A marker stored in the stack metadata. Before creating the stack, the provider adopts the stack carrying the same marker, created by a previous apply whose response was lost. Set it to a value unique within the organization, such as the resource address. Stacks being deleted and stacks managed by other resources, such as the stack the resource replaces, are never adopted. Defaults to a key derived from the organization, region and name of the stack, or from its organization, region and metadata when name is not set. Stacks without name sharing the same region and metadata share the key, each of their resources adopts a distinct stack.",
+			Optional:    true,
+			Computed:    true,
+			PlanModifiers: []planmodifier.String{
+				stringplanmodifier.UseStateForUnknown(),
+			},
+		},
 		"metadata": schema.MapAttribute{
-			Description: "A map of metadata key-value pairs to associate with the stack.",
+			Description: "A map of metadata key-value pairs to associate with the stack. Merged with the default_metadata of the provider, taking precedence over it.",
 			Optional:    true,
 			Computed:    true,
 			ElementType: types.StringType,
@@ -64,6 +150,11 @@ var SchemaStack = schema.Schema{
 				mapplanmodifier.UseStateForUnknown(),
 			},
 		},
+		"metadata_all": schema.MapAttribute{
+			Description: "The metadata of the stack, including the default_metadata of the provider.",
+			Computed:    true,
+			ElementType: types.StringType,
+		},
 	},
 }
 
@@ -71,13 +162,23 @@ type StackModel struct {
 	ID   types.String `tfsdk:"id"`
 	Name types.String `tfsdk:"name"`
 
-	RegionID types.String `tfsdk:"region_id"`
-	Version  types.String `tfsdk:"version"`
-	URI      types.String `tfsdk:"uri"`
+	RegionID          types.String `tfsdk:"region_id"`
+	Version           types.String `tfsdk:"version"`
+	VersionConstraint types.String `tfsdk:"version_constraint"`
+	AutoUpgrade       types.Bool   `tfsdk:"auto_upgrade"`
+	UpgradeMode       types.String `tfsdk:"upgrade_mode"`
+	URI               types.String `tfsdk:"uri"`
+
+	Metadata       types.Map    `tfsdk:"metadata"`
+	MetadataAll    types.Map    `tfsdk:"metadata_all"`
+	IdempotencyKey types.String `tfsdk:"idempotency_key"`
 
-	Metadata types.Map `tfsdk:"metadata"`
+	Modules      types.Set `tfsdk:"modules"`
+	ModuleStatus types.Map `tfsdk:"module_status"`
 
-	ForceDestroy types.Bool `tfsdk:"force_destroy"`
+	Adopt              types.Bool `tfsdk:"adopt"`
+	ForceDestroy       types.Bool `tfsdk:"force_destroy"`
+	DeletionProtection types.Bool `tfsdk:"deletion_protection"`
 }
 
 func (m *StackModel) GetID() string {
@@ -91,6 +192,77 @@ func (m *StackModel) GetRegionID() string {
 	return m.RegionID.ValueString()
 }
 
+// requestMetadata returns the metadata to send to the API, merged with the default metadata and including
+// the keys owned by the provider.
+func (m *StackModel) requestMetadata(ctx context.Context, workspaceID string, defaults map[string]string) map[string]string {
+	metadata := map[string]string{}
+	if !m.Metadata.IsNull() && !m.Metadata.IsUnknown() {
+		m.Metadata.ElementsAs(ctx, &metadata, false)
+	}
+	for k, v := range defaults {
+		if _, ok := metadata[k]; !ok {
+			metadata[k] = v
+		}
+	}
+	metadata[MetadataProtectedKey] = stackMarker(workspaceID)
+	metadata[MetadataDeletionProtectionKey] = strconv.FormatBool(m.DeletionProtection.ValueBool())
+	if key := m.IdempotencyKey.ValueString(); key != "" {
+		metadata[MetadataIdempotencyKey] = key
+	}
+
+	return metadata
+}
+
+// setMetadata fills the model from the stack metadata, hiding the keys owned by the provider.
+// Keys holding their default value are hidden from metadata too, unless the model already sets them,
+// so that default metadata does not show as a difference with the configuration.
+func (m *StackModel) setMetadata(metadata map[string]string, defaults map[string]string) {
+	configured := map[string]attr.Value{}
+	if !m.Metadata.IsNull() && !m.Metadata.IsUnknown() {
+		configured = m.Metadata.Elements()
+	}
+
+	m.DeletionProtection = types.BoolValue(metadata[MetadataDeletionProtectionKey] == "true")
+	m.IdempotencyKey = types.StringNull()
+	if key, ok := metadata[MetadataIdempotencyKey]; ok {
+		m.IdempotencyKey = types.StringValue(key)
+	}
+	m.Metadata = types.MapNull(types.StringType)
+	m.MetadataAll = types.MapNull(types.StringType)
+	if len(metadata) == 0 {
+		return
+	}
+
+	m.MetadataAll = userMetadata(metadata)
+	own := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if def, ok := defaults[k]; ok && def == v {
+			if _, ok := configured[k]; !ok {
+				continue
+			}
+		}
+		own[k] = v
+	}
+	m.Metadata = userMetadata(own)
+}
+
+// userMetadata converts the stack metadata to a map value without the keys owned by the provider.
+func userMetadata(metadata map[string]string) types.Map {
+	md := make(map[string]attr.Value, len(metadata))
+	for k, v := range metadata {
+		if isProviderMetadataKey(k) {
+			continue
+		}
+		md[k] = types.StringValue(v)
+	}
+	return types.MapValueMust(types.StringType, md)
+}
+
+// isProviderMetadataKey reports whether the metadata key is owned by the provider.
+func isProviderMetadataKey(key string) bool {
+	return key == MetadataProtectedKey || key == MetadataDeletionProtectionKey || key == MetadataIdempotencyKey
+}
+
 type Stack struct {
 	store *internal.Store
 }
@@ -102,14 +274,19 @@ func NewStack() func() resource.Resource {
 }
 
 // ImportState implements resource.ResourceWithImportState.
+// Stacks not created by Terraform are read-only until adopt is set.
 func (s *Stack) ImportState(ctx context.Context, req resource.ImportStateRequest, res *resource.ImportStateResponse) {
-	var (
-		id             string
-		organizationId string
-	)
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, res)
+}
 
-	res.Diagnostics.Append(req.Identity.GetAttribute(ctx, path.Root("id"), &id)...)
-	res.Diagnostics.Append(req.Identity.GetAttribute(ctx, path.Root("organization_id"), &organizationId)...)
+// ConfigValidators implements resource.ResourceWithConfigValidators.
+func (s *Stack) ConfigValidators(context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		resourcevalidator.Conflicting(
+			path.MatchRoot("version"),
+			path.MatchRoot("version_constraint"),
+		),
+	}
 }
 
 // ValidateConfig implements resource.ResourceWithValidateConfig.
@@ -121,9 +298,275 @@ func (s *Stack) ValidateConfig(ctx context.Context, req resource.ValidateConfigR
 		return
 	}
 
-	if config.RegionID.IsNull() {
+	// The defaults of the provider are only known once it is configured, they are checked when planning otherwise
+	if config.RegionID.IsNull() && s.store != nil && s.store.GetStackDefaults().RegionID == "" {
 		res.Diagnostics.AddError("Invalid Region ID", "Region ID cannot be null")
 	}
+
+	if !config.VersionConstraint.IsNull() && !config.VersionConstraint.IsUnknown() {
+		if _, err := versions.ParseConstraint(config.VersionConstraint.ValueString()); err != nil {
+			res.Diagnostics.AddAttributeError(
+				path.Root("version_constraint"),
+				"Invalid Version Constraint",
+				err.Error(),
+			)
+		}
+	}
+
+	// The guardrails are only known once the provider is configured, Terraform validates the configuration again when planning
+	if s.store != nil {
+		s.validateGuardrails(config, &res.Diagnostics)
+	}
+}
+
+// ModifyPlan implements resource.ResourceWithModifyPlan.
+// It applies the defaults and enforces the max_stacks guardrail of the provider, resolves version_constraint
+// against the versions available in the region and validates the modules.
+func (s *Stack) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, res *resource.ModifyPlanResponse) {
+	if s.store == nil {
+		return
+	}
+	// Replaced and destroyed stacks must not be adopted by the resources created during the run
+	if !req.State.Raw.IsNull() {
+		var id types.String
+		res.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("id"), &id)...)
+		s.store.ClaimStack(id.ValueString())
+	}
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan StackModel
+	res.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	var state *StackModel
+	if !req.State.Raw.IsNull() {
+		state = &StackModel{}
+		res.Diagnostics.Append(req.State.Get(ctx, state)...)
+		if res.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	if state != nil {
+		// Rollouts targeting the stack warn that the next plan would move it back to the pinned version
+		var version, constraint types.String
+		res.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("version"), &version)...)
+		res.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("version_constraint"), &constraint)...)
+		if constraint.IsNull() && !version.IsNull() && !version.IsUnknown() {
+			s.store.PinStackVersion(state.GetID(), version.ValueString())
+		}
+	}
+	if state != nil && !plan.Adopt.IsUnknown() {
+		s.planOwnership(ctx, state.GetID(), plan.Adopt.ValueBool(), &res.Diagnostics)
+		if res.Diagnostics.HasError() {
+			return
+		}
+	}
+	s.planDefaults(ctx, req.Config, &plan, state, res)
+	if res.Diagnostics.HasError() {
+		return
+	}
+	// The other guardrails only depend on the configuration, and are checked when validating it
+	if state == nil && s.store.GetGuardrails().MaxStacks > 0 {
+		s.planMaxStacks(ctx, plan, &res.Diagnostics)
+		if res.Diagnostics.HasError() {
+			return
+		}
+	}
+	s.planModules(ctx, plan, state, res)
+	if res.Diagnostics.HasError() {
+		return
+	}
+	s.planVersion(ctx, plan, state, res)
+}
+
+// planDefaults applies the defaults of the provider to the attributes the configuration does not set,
+// and computes the metadata merged with the default metadata.
+func (s *Stack) planDefaults(ctx context.Context, config tfsdk.Config, plan *StackModel, state *StackModel, res *resource.ModifyPlanResponse) {
+	var stackConfig StackModel
+	res.Diagnostics.Append(config.Get(ctx, &stackConfig)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+	defaults := s.store.GetStackDefaults()
+
+	if state == nil && stackConfig.RegionID.IsNull() {
+		if defaults.RegionID == "" {
+			res.Diagnostics.AddAttributeError(
+				path.Root("region_id"),
+				"Invalid Region ID",
+				"Region ID cannot be null, set region_id on the stack or in the defaults of the provider.",
+			)
+			return
+		}
+		plan.RegionID = types.StringValue(defaults.RegionID)
+		res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("region_id"), plan.RegionID)...)
+	}
+
+	// Raising the default version must not upgrade every existing stack of the organization
+	if state == nil && stackConfig.Version.IsNull() && stackConfig.VersionConstraint.IsNull() && defaults.Version != "" {
+		plan.Version = types.StringValue(defaults.Version)
+		res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("version"), plan.Version)...)
+	}
+
+	// Without metadata in the configuration, the keys of the state equal to an outdated default are dropped
+	if stackConfig.Metadata.IsNull() && state != nil && !plan.Metadata.IsNull() && !plan.Metadata.IsUnknown() {
+		elements := maps.Clone(plan.Metadata.Elements())
+		for k := range defaults.Metadata {
+			delete(elements, k)
+		}
+		plan.Metadata = types.MapValueMust(types.StringType, elements)
+		res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("metadata"), plan.Metadata)...)
+	}
+
+	if plan.Metadata.IsUnknown() && !stackConfig.Metadata.IsNull() {
+		res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("metadata_all"), types.MapUnknown(types.StringType))...)
+		return
+	}
+	merged := map[string]string{}
+	if !plan.Metadata.IsNull() && !plan.Metadata.IsUnknown() {
+		res.Diagnostics.Append(plan.Metadata.ElementsAs(ctx, &merged, false)...)
+	}
+	for k, v := range defaults.Metadata {
+		if _, ok := merged[k]; !ok {
+			merged[k] = v
+		}
+	}
+	res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("metadata_all"), userMetadata(merged))...)
+}
+
+// planModules validates the modules against the region capabilities and flags the module status as
+// unknown when modules are going to change.
+func (s *Stack) planModules(ctx context.Context, plan StackModel, state *StackModel, res *resource.ModifyPlanResponse) {
+	if plan.Modules.IsNull() {
+		res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("module_status"), types.MapNull(types.StringType))...)
+		return
+	}
+	if state == nil || !plan.Modules.Equal(state.Modules) {
+		res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("module_status"), types.MapUnknown(types.StringType))...)
+	}
+	if state != nil && s.store.ClaimAuthoritativeModules(state.GetID()) {
+		res.Diagnostics.AddAttributeWarning(
+			path.Root("modules"),
+			"Conflicting Module Management",
+			fmt.Sprintf("Stack '%s' sets modules and is also targeted by cloud_stack_module resources. The modules attribute is authoritative and will disable modules it does not list; remove the cloud_stack_module resources.", state.GetID()),
+		)
+	}
+	if plan.Modules.IsUnknown() || plan.RegionID.IsUnknown() {
+		return
+	}
+
+	var names []string
+	res.Diagnostics.Append(plan.Modules.ElementsAs(ctx, &names, false)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+	paths := make(map[string]path.Path, len(names))
+	for _, name := range names {
+		paths[name] = path.Root("modules").AtSetValue(types.StringValue(name))
+	}
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		res.Diagnostics.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	checkModuleNames(ctx, s.store.GetSDK(), organizationId, plan.GetRegionID(), paths, &res.Diagnostics)
+}
+
+// planVersion resolves version_constraint against the versions available in the region,
+// and refuses to downgrade stacks.
+func (s *Stack) planVersion(ctx context.Context, plan StackModel, state *StackModel, res *resource.ModifyPlanResponse) {
+	current := ""
+	if state != nil {
+		current = state.Version.ValueString()
+	}
+
+	if plan.VersionConstraint.IsNull() {
+		if !plan.Version.IsUnknown() && isDowngrade(current, plan.Version.ValueString()) {
+			res.Diagnostics.AddAttributeError(
+				path.Root("version"),
+				"Stack Downgrade Not Supported",
+				fmt.Sprintf("The stack runs version '%s', the configured version is '%s'. Stacks cannot be downgraded.", current, plan.Version.ValueString()),
+			)
+		}
+		return
+	}
+	if plan.VersionConstraint.IsUnknown() || plan.RegionID.IsUnknown() {
+		return
+	}
+
+	constraint, err := versions.ParseConstraint(plan.VersionConstraint.ValueString())
+	if err != nil {
+		res.Diagnostics.AddAttributeError(
+			path.Root("version_constraint"),
+			"Invalid Version Constraint",
+			err.Error(),
+		)
+		return
+	}
+
+	// Keep the deployed version as long as it matches, unless the user opted in for upgrades
+	if current != "" && constraint.Check(current) && !plan.AutoUpgrade.ValueBool() {
+		res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("version"), types.StringValue(current))...)
+		return
+	}
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		res.Diagnostics.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	operation, err := s.store.GetSDK().GetRegionVersions(ctx, organizationId, plan.GetRegionID())
+	if err != nil {
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		return
+	}
+
+	resolved, ok := versions.Latest(constraint, operation.GetRegionVersionsResponse.Data)
+	if !ok {
+		res.Diagnostics.AddAttributeError(
+			path.Root("version_constraint"),
+			"No Matching Version",
+			fmt.Sprintf("No non deprecated version matching '%s' is available in region '%s'.", constraint, plan.GetRegionID()),
+		)
+		return
+	}
+
+	if current != "" && constraint.Check(current) && versions.Compare(resolved, current) <= 0 {
+		resolved = current
+	}
+	if current != "" && versions.Compare(resolved, current) < 0 {
+		res.Diagnostics.AddAttributeError(
+			path.Root("version_constraint"),
+			"Stack Downgrade Not Supported",
+			fmt.Sprintf("The stack runs version '%s', the newest version matching '%s' is '%s'. Stacks cannot be downgraded.", current, constraint, resolved),
+		)
+		return
+	}
+
+	res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("version"), types.StringValue(resolved))...)
+}
+
+// isDowngrade reports whether moving from the current version to the target one is a downgrade.
+// Versions which are not semantic versions, such as "default", are resolved by the API and never compared.
+func isDowngrade(current, target string) bool {
+	if versions.Canonical(current) == "" || versions.Canonical(target) == "" {
+		return false
+	}
+	return versions.Compare(target, current) < 0
 }
 
 // Configure implements resource.ResourceWithConfigure.
@@ -162,42 +605,61 @@ func (s *Stack) Create(ctx context.Context, req resource.CreateRequest, resp *re
 		return
 	}
 
-	createStackRequest := &shared.CreateStackRequest{
-		Metadata: map[string]string{},
-		RegionID: plan.GetRegionID(),
-		Name:     plan.GetName(),
-		Version:  pointer.For(plan.Version.ValueString()),
-	}
-	if !plan.Metadata.IsNull() {
-		plan.Metadata.ElementsAs(ctx, &createStackRequest.Metadata, false)
+	plannedKey := plannedStackKey(organizationId, plan)
+	if plan.IdempotencyKey.IsUnknown() || plan.IdempotencyKey.ValueString() == "" {
+		plan.IdempotencyKey = types.StringValue(defaultIdempotencyKey(organizationId, plan))
 	}
-	createStackRequest.Metadata["github.com/formancehq/terraform-provider-cloud/protected"] = "true"
 
-	operation, err := s.store.GetSDK().CreateStack(ctx, organizationId, createStackRequest)
+	stack, err := s.findStackByIdempotencyKey(ctx, organizationId, plan.IdempotencyKey.ValueString(), &resp.Diagnostics)
 	if err != nil {
 		pkg.HandleSDKError(ctx, err, &resp.Diagnostics)
 		return
 	}
 
-	plan.ID = types.StringValue(operation.CreateStackResponse.Data.ID)
-	plan.Name = types.StringValue(operation.CreateStackResponse.Data.Name)
-	plan.RegionID = types.StringValue(operation.CreateStackResponse.Data.RegionID)
-	plan.URI = types.StringValue(operation.CreateStackResponse.Data.URI)
-	plan.Version = types.StringNull()
-	if operation.CreateStackResponse.Data.Version != nil {
-		plan.Version = types.StringValue(*operation.CreateStackResponse.Data.Version)
-	}
-	plan.Metadata = types.MapNull(types.StringType)
-	if len(operation.CreateStackResponse.Data.Metadata) > 0 {
-		md := make(map[string]attr.Value, len(operation.CreateStackResponse.Data.Metadata))
-		for k, v := range operation.CreateStackResponse.Data.Metadata {
-			md[k] = types.StringValue(v)
+	if stack == nil {
+		createStackRequest := &shared.CreateStackRequest{
+			Metadata: plan.requestMetadata(ctx, s.store.GetWorkspaceID(), s.store.GetStackDefaults().Metadata),
+			RegionID: plan.GetRegionID(),
+			Name:     plan.GetName(),
+			Version:  pointer.For(plan.Version.ValueString()),
+		}
+
+		operation, err := s.store.GetSDK().CreateStack(ctx, organizationId, createStackRequest)
+		if err != nil && isCreationOutcomeUnknown(err) {
+			logging.FromContext(ctx).Debugf("Looking for a stack created despite the error: %s", err)
+			if stack, _ = s.findStackByIdempotencyKey(ctx, organizationId, plan.IdempotencyKey.ValueString(), &resp.Diagnostics); stack != nil {
+				err = nil
+			}
+		}
+		if err != nil {
+			pkg.HandleSDKError(ctx, err, &resp.Diagnostics,
+				pkg.WithField("name", path.Root("name")),
+				pkg.WithField("regionID", path.Root("region_id")),
+				pkg.WithField("version", path.Root("version")),
+				pkg.WithField("metadata", path.Root("metadata")),
+			)
+			return
+		}
+		if stack == nil {
+			stack = operation.CreateStackResponse.Data
+			s.store.ClaimStack(stack.ID)
 		}
-		//FixMe(hack): Remove the protected metadata to match the config
-		delete(md, "github.com/formancehq/terraform-provider-cloud/protected")
-		plan.Metadata = types.MapValueMust(types.StringType, md)
 	}
+	// The stack is now listed, and no longer counted as planned against max_stacks
+	s.store.UnplanStack(plannedKey)
+
+	plan.ID = types.StringValue(stack.ID)
+	plan.Name = types.StringValue(stack.Name)
+	plan.RegionID = types.StringValue(stack.RegionID)
+	plan.URI = types.StringValue(stack.URI)
+	if stack.Version != nil {
+		plan.Version = types.StringValue(*stack.Version)
+	} else if plan.Version.IsUnknown() {
+		plan.Version = types.StringNull()
+	}
+	plan.setMetadata(stack.Metadata, s.store.GetStackDefaults().Metadata)
 
+	s.applyModules(ctx, organizationId, &plan, &resp.Diagnostics)
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
@@ -209,6 +671,14 @@ func (s *Stack) Delete(ctx context.Context, req resource.DeleteRequest, resp *re
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	if plan.DeletionProtection.ValueBool() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("deletion_protection"),
+			"Stack is protected against deletion",
+			fmt.Sprintf("Stack '%s' has deletion_protection enabled. Set deletion_protection to false and apply before destroying it.", plan.GetID()),
+		)
+		return
+	}
 	organizationId, err := s.store.GetOrganizationID(ctx)
 	if err != nil {
 		resp.Diagnostics.AddError(
@@ -217,9 +687,15 @@ func (s *Stack) Delete(ctx context.Context, req resource.DeleteRequest, resp *re
 		)
 		return
 	}
-	operation, err := s.store.GetSDK().DeleteStack(ctx, organizationId, plan.GetID(), plan.ForceDestroy.ValueBool())
+	_, err = s.readOwnedStack(ctx, organizationId, plan.GetID(), plan.Adopt.ValueBool(), &resp.Diagnostics)
+	if err == nil {
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		_, err = s.store.GetSDK().DeleteStack(ctx, organizationId, plan.GetID(), plan.ForceDestroy.ValueBool())
+	}
 	if err != nil {
-		if operation.StatusCode == http.StatusNotFound {
+		if errors.Is(err, pkg.ErrNotFound) {
 			resp.Diagnostics.AddWarning(
 				"Stack not found",
 				"The stack was not found. It may have already been deleted outside of Terraform.",
@@ -256,11 +732,17 @@ func (s *Stack) Read(ctx context.Context, req resource.ReadRequest, resp *resour
 	}
 	op, err := s.store.GetSDK().ReadStack(ctx, organizationId, plan.GetID())
 	if err != nil {
+		if errors.Is(err, pkg.ErrNotFound) {
+			resp.State.RemoveResource(ctx)
+			return
+		}
 		pkg.HandleSDKError(ctx, err, &resp.Diagnostics)
 		return
 	}
 
 	res := op.CreateStackResponse
+	s.store.ClaimStack(res.Data.ID)
+	s.store.SetStackMetadata(res.Data.ID, res.Data.Metadata)
 	plan.ID = types.StringValue(res.Data.ID)
 	plan.Name = types.StringValue(res.Data.Name)
 	plan.Version = types.StringNull()
@@ -269,15 +751,15 @@ func (s *Stack) Read(ctx context.Context, req resource.ReadRequest, resp *resour
 	}
 	plan.RegionID = types.StringValue(res.Data.RegionID)
 	plan.URI = types.StringValue(res.Data.URI)
-	plan.Metadata = types.MapNull(types.StringType)
-	if len(res.Data.Metadata) > 0 {
-		md := make(map[string]attr.Value, len(res.Data.Metadata))
-		for k, v := range res.Data.Metadata {
-			md[k] = types.StringValue(v)
+	plan.setMetadata(res.Data.Metadata, s.store.GetStackDefaults().Metadata)
+
+	if !plan.Modules.IsNull() {
+		modules, err := s.store.GetSDK().ListModules(ctx, organizationId, plan.GetID())
+		if err != nil {
+			pkg.HandleSDKError(ctx, err, &resp.Diagnostics)
+			return
 		}
-		//FixMe(hack): Remove the protected metadata to match the config
-		delete(md, "github.com/formancehq/terraform-provider-cloud/protected")
-		plan.Metadata = types.MapValueMust(types.StringType, md)
+		plan.setModules(modules.ListModulesResponse.Data)
 	}
 	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
@@ -306,48 +788,142 @@ func (s *Stack) Update(ctx context.Context, req resource.UpdateRequest, res *res
 		)
 		return
 	}
-	if plan.Name.ValueString() != state.Name.ValueString() {
+	stack, err := s.readOwnedStack(ctx, organizationId, plan.GetID(), plan.Adopt.ValueBool(), &res.Diagnostics)
+	if err != nil {
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		return
+	}
+	if res.Diagnostics.HasError() {
+		return
+	}
+	// Downgrades are refused when planning, unless the version was only known when applying
+	if isDowngrade(state.Version.ValueString(), plan.Version.ValueString()) {
+		res.Diagnostics.AddAttributeError(
+			path.Root("version"),
+			"Stack Downgrade Not Supported",
+			fmt.Sprintf("The stack runs version '%s', the configured version is '%s'. Stacks cannot be downgraded.", state.Version.ValueString(), plan.Version.ValueString()),
+		)
+		return
+	}
+
+	if plan.IdempotencyKey.IsUnknown() {
+		plan.IdempotencyKey = state.IdempotencyKey
+	}
+	// Adopted stacks, stacks marked before the workspace was configured and stacks missing
+	// default metadata are updated too
+	metadata := plan.requestMetadata(ctx, s.store.GetWorkspaceID(), s.store.GetStackDefaults().Metadata)
+	if plan.Name.ValueString() != state.Name.ValueString() || !maps.Equal(metadata, stack.Metadata) {
 		updateRequest := &shared.StackData{
 			Name:     plan.Name.ValueString(),
-			Metadata: map[string]string{},
-		}
-
-		if !plan.Metadata.IsNull() {
-			plan.Metadata.ElementsAs(ctx, &updateRequest.Metadata, false)
+			Metadata: metadata,
 		}
-		updateRequest.Metadata["github.com/formancehq/terraform-provider-cloud/protected"] = "true"
 
 		operation, err := s.store.GetSDK().UpdateStack(ctx, organizationId, plan.GetID(), updateRequest)
 		if err != nil {
-			pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+			pkg.HandleSDKError(ctx, err, &res.Diagnostics,
+				pkg.WithField("name", path.Root("name")),
+				pkg.WithField("metadata", path.Root("metadata")),
+			)
 			return
 		}
 		plan.Name = types.StringValue(operation.CreateStackResponse.Data.Name)
 		plan.URI = types.StringValue(operation.CreateStackResponse.Data.URI)
-		plan.Metadata = types.MapNull(types.StringType)
-		if len(operation.CreateStackResponse.Data.Metadata) > 0 {
-			md := make(map[string]attr.Value, len(operation.CreateStackResponse.Data.Metadata))
-			for k, v := range operation.CreateStackResponse.Data.Metadata {
-				md[k] = types.StringValue(v)
-			}
-			//FixMe(hack): Remove the protected metadata to match the config
-			delete(md, "github.com/formancehq/terraform-provider-cloud/protected")
-			plan.Metadata = types.MapValueMust(types.StringType, md)
-		}
+		plan.setMetadata(operation.CreateStackResponse.Data.Metadata, s.store.GetStackDefaults().Metadata)
 	}
 
-	if state.Version.ValueString() != plan.Version.ValueString() {
-		if !semver.IsValid(plan.Version.ValueString()) ||
-			(semver.IsValid(plan.Version.ValueString()) && semver.Compare(state.Version.ValueString(), plan.Version.ValueString()) >= 0) {
+	if !versions.Equal(state.Version.ValueString(), plan.Version.ValueString()) {
+		if plan.UpgradeMode.ValueString() == UpgradeModeStepwise {
+			reached := s.upgradeStepwise(ctx, organizationId, &plan, state.Version.ValueString(), &res.Diagnostics)
+			if res.Diagnostics.HasError() {
+				// Record the last version the stack successfully reached, modules were not applied yet
+				plan.Version = types.StringValue(reached)
+				plan.Modules = state.Modules
+				plan.ModuleStatus = state.ModuleStatus
+				res.Diagnostics.Append(res.State.Set(ctx, &plan)...)
+				return
+			}
+		} else {
 			_, err := s.store.GetSDK().UpgradeStack(ctx, organizationId, plan.GetID(), plan.Version.ValueString())
 			if err != nil {
-				pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+				pkg.HandleSDKError(ctx, err, &res.Diagnostics, pkg.WithAttribute(path.Root("version")))
 				return
 			}
-
-			plan.Version = types.StringValue(plan.Version.ValueString())
 		}
+
+		plan.Version = types.StringValue(plan.Version.ValueString())
 	}
 
+	s.applyModules(ctx, organizationId, &plan, &res.Diagnostics)
 	res.Diagnostics.Append(res.State.Set(ctx, &plan)...)
 }
+
+// applyModules makes the modules of the stack match the modules attribute, if set.
+// The modules attribute keeps the configured value, as Terraform requires, while module_status reflects
+// the modules actually enabled, even on failure. The next refresh reports the modules left to apply.
+func (s *Stack) applyModules(ctx context.Context, organizationId string, plan *StackModel, diags *diag.Diagnostics) {
+	if plan.Modules.IsNull() {
+		plan.ModuleStatus = types.MapNull(types.StringType)
+		return
+	}
+
+	var desired []string
+	diags.Append(plan.Modules.ElementsAs(ctx, &desired, false)...)
+	if diags.HasError() {
+		return
+	}
+
+	modules, err := syncStackModules(ctx, s.store.GetSDK(), organizationId, plan.GetID(), desired)
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("modules"),
+			"Failed to apply stack modules",
+			fmt.Sprintf("Unable to apply the modules of stack '%s': %s", plan.GetID(), err),
+		)
+		if operation, err := s.store.GetSDK().ListModules(ctx, organizationId, plan.GetID()); err == nil {
+			plan.setModuleStatus(operation.ListModulesResponse.Data)
+		} else {
+			plan.ModuleStatus = types.MapNull(types.StringType)
+		}
+		return
+	}
+	plan.setModuleStatus(modules)
+}
+
+// upgradeStepwise upgrades the stack hop by hop, waiting for the stack to be READY between each of them.
+// It returns the last version the stack successfully reached.
+func (s *Stack) upgradeStepwise(ctx context.Context, organizationId string, plan *StackModel, current string, diags *diag.Diagnostics) string {
+	operation, err := s.store.GetSDK().GetRegionVersions(ctx, organizationId, plan.GetRegionID())
+	if err != nil {
+		pkg.HandleSDKError(ctx, err, diags)
+		return current
+	}
+
+	steps, err := versions.UpgradePath(current, plan.Version.ValueString(), operation.GetRegionVersionsResponse.Data)
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("version"),
+			"Unable to compute upgrade path",
+			err.Error(),
+		)
+		return current
+	}
+
+	for i, step := range steps {
+		logging.FromContext(ctx).Debugf("Upgrading stack %s to %s (%d/%d)", plan.GetID(), step, i+1, len(steps))
+		_, err := s.store.GetSDK().UpgradeStack(ctx, organizationId, plan.GetID(), step)
+		if err == nil {
+			_, err = waitStackReady(ctx, s.store.GetSDK(), organizationId, plan.GetID())
+		}
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("version"),
+				"Stack upgrade failed",
+				fmt.Sprintf("Upgrade hop %d/%d from '%s' to '%s' failed: %s. The stack remains at version '%s'.", i+1, len(steps), current, step, err, current),
+			)
+			return current
+		}
+		current = step
+	}
+
+	return current
+}
@@ -0,0 +1,176 @@
+package resources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// defaultIdempotencyKey derives the marker of a stack from its organization, region and name, so that
+// the next apply finds a stack whose creation response was lost. Stacks named by the API are told apart
+// by their metadata instead: stacks without name sharing region and metadata share the key, and a lost
+// stack may be adopted by any of their resources, as long as each of them adopts a distinct stack.
+func defaultIdempotencyKey(organizationID string, plan StackModel) string {
+	parts := []string{organizationID, plan.GetRegionID(), plan.GetName()}
+	if plan.GetName() == "" {
+		var metadata []string
+		for key, value := range plan.Metadata.Elements() {
+			if value, ok := value.(types.String); ok {
+				metadata = append(metadata, key+"="+value.ValueString())
+			}
+		}
+		sort.Strings(metadata)
+		parts = append(parts, metadata...)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "/")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// isCreationOutcomeUnknown reports whether a failed creation may have created the stack anyway:
+// the response was lost or the API failed after accepting the request, or a previous attempt
+// already created a stack with the same name.
+func isCreationOutcomeUnknown(err error) bool {
+	apiErr := &pkg.APIError{}
+	if !errors.As(err, &apiErr) {
+		return !errors.Is(err, context.Canceled)
+	}
+	return errors.Is(err, pkg.ErrServerError) || errors.Is(err, pkg.ErrConflict)
+}
+
+// isStackDeleted reports whether the stack is deleted or being deleted.
+func isStackDeleted(stack shared.Stack) bool {
+	return stack.State == shared.StackStateDeleted || stack.Status == shared.StackStatusDeleted ||
+		stack.ExpectedStatus == shared.ExpectedStatusDeleted || stack.DeletedAt != nil
+}
+
+// findStackByIdempotencyKey returns the oldest live stack of the workspace carrying the marker, reporting the others as duplicates.
+// Stacks managed by other resources of the run, such as the stack replaced by the resource, are never adopted.
+func (s *Stack) findStackByIdempotencyKey(ctx context.Context, organizationID, key string, diags *diag.Diagnostics) (*shared.Stack, error) {
+	operation, err := s.store.GetSDK().ListStacks(internal.ContextWithoutCache(ctx), organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []shared.Stack
+	for _, stack := range operation.ListStacksResponse.Data {
+		if stack.Metadata[MetadataIdempotencyKey] != key || isStackDeleted(stack) ||
+			stack.Metadata[MetadataProtectedKey] != stackMarker(s.store.GetWorkspaceID()) || s.store.IsStackClaimed(stack.ID) {
+			continue
+		}
+		matches = append(matches, stack)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].CreatedAt == nil || matches[j].CreatedAt == nil {
+			return matches[j].CreatedAt == nil && matches[i].CreatedAt != nil
+		}
+		return matches[i].CreatedAt.Before(*matches[j].CreatedAt)
+	})
+	// Resources applied concurrently with the same key adopt distinct stacks
+	for len(matches) > 0 && !s.store.ClaimStack(matches[0].ID) {
+		matches = matches[1:]
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	logging.FromContext(ctx).Infof("Adopting stack %s carrying idempotency key %s", matches[0].ID, key)
+	diags.AddWarning(
+		"Adopted existing stack",
+		fmt.Sprintf("Stack '%s' was created by a previous apply whose response was lost, it is adopted instead of creating a new stack.", matches[0].ID),
+	)
+	for _, duplicate := range matches[1:] {
+		diags.AddWarning(
+			"Duplicate stack",
+			fmt.Sprintf("Stack '%s' carries the same idempotency key as the adopted stack '%s' and is not managed by Terraform. Delete it if it is not used.", duplicate.ID, matches[0].ID),
+		)
+	}
+
+	return &matches[0], nil
+}
+
+// stackMarker returns the value of the marker of the stacks managed by the workspace.
+func stackMarker(workspaceID string) string {
+	if workspaceID == "" {
+		return "true"
+	}
+	return workspaceID
+}
+
+// readOwnedStack reads the stack and reports an error unless the workspace may mutate it.
+func (s *Stack) readOwnedStack(ctx context.Context, organizationID, stackID string, adopt bool, diags *diag.Diagnostics) (*shared.Stack, error) {
+	operation, err := s.store.GetSDK().ReadStack(ctx, organizationID, stackID)
+	if err != nil {
+		return nil, err
+	}
+	stack := operation.CreateStackResponse.Data
+	s.checkOwnership(stackID, stack.Metadata, adopt, diags)
+
+	return stack, nil
+}
+
+// checkOwnership reports an error unless the workspace may mutate the stack: stacks without marker
+// must be adopted, and stacks marked by another workspace are left to it. Stacks marked before
+// workspaces were configured are managed by any workspace.
+func (s *Stack) checkOwnership(stackID string, metadata map[string]string, adopt bool, diags *diag.Diagnostics) {
+	workspaceID := s.store.GetWorkspaceID()
+	if _, ok := metadata[MetadataProtectedKey]; !ok && !adopt {
+		diags.AddAttributeError(
+			path.Root("adopt"),
+			"Stack not managed by Terraform",
+			fmt.Sprintf("Stack '%s' was not created by Terraform. Set adopt to true to manage it.", stackID),
+		)
+		return
+	}
+	if owner, ok := otherWorkspace(workspaceID, metadata); ok {
+		diags.AddError(
+			"Stack managed by another workspace",
+			fmt.Sprintf("Stack '%s' is managed by the Terraform workspace '%s', the provider is configured with the workspace '%s'. Remove the stack from the state of one of the workspaces.", stackID, owner, workspaceID),
+		)
+	}
+}
+
+// otherWorkspace returns the workspace marking the stack when it is not the given one.
+// Stacks marked without a workspace belong to every workspace.
+func otherWorkspace(workspaceID string, metadata map[string]string) (string, bool) {
+	marker, ok := metadata[MetadataProtectedKey]
+	if !ok || marker == "true" || marker == workspaceID {
+		return "", false
+	}
+	return marker, true
+}
+
+// planOwnership reports the stacks the workspace may not mutate when planning, rather than when applying,
+// so that imported stacks require adopt before any change. The metadata refreshed during the run is used
+// when available.
+func (s *Stack) planOwnership(ctx context.Context, stackID string, adopt bool, diags *diag.Diagnostics) {
+	if metadata, ok := s.store.GetStackMetadata(stackID); ok {
+		s.checkOwnership(stackID, metadata, adopt, diags)
+		return
+	}
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		diags.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+	// Stacks deleted outside of Terraform are recreated
+	if _, err := s.readOwnedStack(ctx, organizationId, stackID, adopt, diags); err != nil && !errors.Is(err, pkg.ErrNotFound) {
+		pkg.HandleSDKError(ctx, err, diags)
+	}
+}
@@ -0,0 +1,598 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+
+	"github.com/formancehq/go-libs/v3/pointer"
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/internal/versions"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ resource.Resource                   = &StackClone{}
+	_ resource.ResourceWithConfigure      = &StackClone{}
+	_ resource.ResourceWithModifyPlan     = &StackClone{}
+	_ resource.ResourceWithValidateConfig = &StackClone{}
+)
+
+var SchemaStackClone = schema.Schema{
+	Description: "Creates a new stack replicating the configuration of an existing one: version, enabled modules, user accesses and metadata. Changes made to the source stack afterwards show up as a planned update of the clone.",
+	Attributes: map[string]schema.Attribute{
+		"id": schema.StringAttribute{
+			Description: "The unique identifier of the cloned stack.",
+			Computed:    true,
+			PlanModifiers: []planmodifier.String{
+				stringplanmodifier.UseStateForUnknown(),
+			},
+		},
+		"source_stack_id": schema.StringAttribute{
+			Description: "The ID of the stack to clone.",
+			Required:    true,
+			PlanModifiers: []planmodifier.String{
+				stringplanmodifier.RequiresReplace(),
+			},
+		},
+		"name": schema.StringAttribute{
+			Description: "The name of the cloned stack. Must be unique within the organization.",
+			Required:    true,
+			PlanModifiers: []planmodifier.String{
+				stringplanmodifier.RequiresReplace(),
+			},
+		},
+		"region_id": schema.StringAttribute{
+			Description: "The region ID where the cloned stack will be deployed. Defaults to the region of the source stack.",
+			Optional:    true,
+			Computed:    true,
+			PlanModifiers: []planmodifier.String{
+				stringplanmodifier.RequiresReplaceIfConfigured(),
+				stringplanmodifier.UseStateForUnknown(),
+			},
+		},
+		"force_destroy": schema.BoolAttribute{
+			Description: "When set to true, the cloned stack will be forcefully deleted even if it contains data.",
+			Optional:    true,
+		},
+		"uri": schema.StringAttribute{
+			Description: "The URI of the cloned stack.",
+			Computed:    true,
+			PlanModifiers: []planmodifier.String{
+				stringplanmodifier.UseStateForUnknown(),
+			},
+		},
+		"version": schema.StringAttribute{
+			Description: "The version cloned from the source stack.",
+			Computed:    true,
+		},
+		"modules": schema.SetAttribute{
+			Description: "The modules cloned from the source stack.",
+			Computed:    true,
+			ElementType: types.StringType,
+		},
+		"user_accesses": schema.MapAttribute{
+			Description: "The user accesses cloned from the source stack, as policy IDs keyed by user ID.",
+			Computed:    true,
+			ElementType: types.Int64Type,
+		},
+		"metadata": schema.MapAttribute{
+			Description: "The metadata cloned from the source stack.",
+			Computed:    true,
+			ElementType: types.StringType,
+		},
+	},
+}
+
+type StackCloneModel struct {
+	ID            types.String `tfsdk:"id"`
+	SourceStackID types.String `tfsdk:"source_stack_id"`
+	Name          types.String `tfsdk:"name"`
+	RegionID      types.String `tfsdk:"region_id"`
+	ForceDestroy  types.Bool   `tfsdk:"force_destroy"`
+	URI           types.String `tfsdk:"uri"`
+
+	Version      types.String `tfsdk:"version"`
+	Modules      types.Set    `tfsdk:"modules"`
+	UserAccesses types.Map    `tfsdk:"user_accesses"`
+	Metadata     types.Map    `tfsdk:"metadata"`
+}
+
+type StackClone struct {
+	store *internal.Store
+}
+
+func NewStackClone() func() resource.Resource {
+	return func() resource.Resource {
+		return &StackClone{}
+	}
+}
+
+// stackSnapshot is the replicated part of a stack configuration.
+type stackSnapshot struct {
+	regionID     string
+	version      string
+	modules      []string
+	userAccesses map[string]int64
+	metadata     map[string]string
+}
+
+// readSnapshot reads the replicated configuration of a stack.
+func readSnapshot(ctx context.Context, sdk pkg.CloudSDK, organizationID, stackID string) (*stackSnapshot, error) {
+	stack, err := sdk.ReadStack(ctx, organizationID, stackID)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := sdk.ListModules(ctx, organizationID, stackID)
+	if err != nil {
+		return nil, err
+	}
+	accesses, err := sdk.ListStackUsersAccesses(ctx, organizationID, stackID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &stackSnapshot{
+		regionID:     stack.CreateStackResponse.Data.RegionID,
+		modules:      enabledModules(modules.ListModulesResponse.Data),
+		userAccesses: map[string]int64{},
+		metadata:     map[string]string{},
+	}
+	if stack.CreateStackResponse.Data.Version != nil {
+		snapshot.version = *stack.CreateStackResponse.Data.Version
+	}
+	for _, access := range accesses.StackUserAccessResponse.Data {
+		snapshot.userAccesses[access.UserID] = access.PolicyID
+	}
+	for k, v := range stack.CreateStackResponse.Data.Metadata {
+		if isProviderMetadataKey(k) {
+			continue
+		}
+		snapshot.metadata[k] = v
+	}
+
+	return snapshot, nil
+}
+
+// set fills the replicated attributes of the model from the snapshot.
+func (m *StackCloneModel) set(snapshot *stackSnapshot) {
+	m.Version = types.StringValue(snapshot.version)
+
+	m.Modules = modulesValue(snapshot.modules)
+
+	accesses := make(map[string]attr.Value, len(snapshot.userAccesses))
+	for userID, policyID := range snapshot.userAccesses {
+		accesses[userID] = types.Int64Value(policyID)
+	}
+	m.UserAccesses = types.MapValueMust(types.Int64Type, accesses)
+
+	metadata := make(map[string]attr.Value, len(snapshot.metadata))
+	for k, v := range snapshot.metadata {
+		metadata[k] = types.StringValue(v)
+	}
+	m.Metadata = types.MapValueMust(types.StringType, metadata)
+}
+
+// snapshot returns the replicated attributes of the model.
+func (m *StackCloneModel) snapshot(ctx context.Context) (*stackSnapshot, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	snapshot := &stackSnapshot{
+		regionID:     m.RegionID.ValueString(),
+		version:      m.Version.ValueString(),
+		userAccesses: map[string]int64{},
+		metadata:     map[string]string{},
+	}
+	diags.Append(m.Modules.ElementsAs(ctx, &snapshot.modules, false)...)
+	diags.Append(m.UserAccesses.ElementsAs(ctx, &snapshot.userAccesses, false)...)
+	diags.Append(m.Metadata.ElementsAs(ctx, &snapshot.metadata, false)...)
+	return snapshot, diags
+}
+
+// Schema implements resource.Resource.
+func (s *StackClone) Schema(ctx context.Context, req resource.SchemaRequest, res *resource.SchemaResponse) {
+	res.Schema = SchemaStackClone
+}
+
+// Metadata implements resource.Resource.
+func (s *StackClone) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_stack_clone"
+}
+
+// Configure implements resource.ResourceWithConfigure.
+func (s *StackClone) Configure(ctx context.Context, req resource.ConfigureRequest, res *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	store, ok := req.ProviderData.(*internal.Store)
+	if !ok {
+		res.Diagnostics.AddError(
+			ErrProviderDataNotSet.Error(),
+			fmt.Sprintf("Expected *internal.Store, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	s.store = store
+}
+
+// ValidateConfig implements resource.ResourceWithValidateConfig.
+func (s *StackClone) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, res *resource.ValidateConfigResponse) {
+	var config StackCloneModel
+	res.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	// The guardrails are only known once the provider is configured, Terraform validates the configuration again when planning
+	if s.store == nil {
+		return
+	}
+	guardrails := s.store.GetGuardrails()
+	checkNameGuardrails(guardrails, config.Name, &res.Diagnostics)
+	if !config.RegionID.IsNull() && !config.RegionID.IsUnknown() {
+		checkRegionGuardrails(guardrails, config.RegionID.ValueString(), &res.Diagnostics)
+	}
+}
+
+// ModifyPlan implements resource.ResourceWithModifyPlan.
+// It checks new clones against the guardrails of the provider, and plans the configuration of the source stack
+// so that changes made to it show up as drift of the clone.
+func (s *StackClone) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, res *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || s.store == nil {
+		return
+	}
+
+	var plan StackCloneModel
+	res.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+	if req.State.Raw.IsNull() {
+		s.planGuardrails(ctx, plan, &res.Diagnostics)
+		return
+	}
+
+	var state StackCloneModel
+	res.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+	if plan.SourceStackID.IsUnknown() || !plan.SourceStackID.Equal(state.SourceStackID) {
+		return
+	}
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		res.Diagnostics.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	source, err := readSnapshot(ctx, s.store.GetSDK(), organizationId, plan.SourceStackID.ValueString())
+	if err != nil {
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		return
+	}
+
+	// Stacks cannot be downgraded, keep the version of the clone if the source is behind
+	if versions.Canonical(source.version) != "" && versions.Compare(source.version, state.Version.ValueString()) < 0 {
+		source.version = state.Version.ValueString()
+	}
+
+	plan.set(source)
+	res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("version"), plan.Version)...)
+	res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("modules"), plan.Modules)...)
+	res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("user_accesses"), plan.UserAccesses)...)
+	res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("metadata"), plan.Metadata)...)
+}
+
+// planGuardrails checks the clone against the guardrails of the provider which depend on the source stack,
+// and counts it against max_stacks. A source stack created by the same apply is only known when applying,
+// its region, modules and metadata are not checked in that case.
+func (s *StackClone) planGuardrails(ctx context.Context, plan StackCloneModel, diags *diag.Diagnostics) {
+	guardrails := s.store.GetGuardrails()
+	checkSource := !plan.SourceStackID.IsUnknown() &&
+		(len(guardrails.AllowedRegionIDs) > 0 || len(guardrails.AllowedModules) > 0 || len(guardrails.RequiredMetadataKeys) > 0)
+	if !checkSource && guardrails.MaxStacks == 0 {
+		return
+	}
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		diags.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	if checkSource {
+		source, err := readSnapshot(ctx, s.store.GetSDK(), organizationId, plan.SourceStackID.ValueString())
+		if err != nil {
+			pkg.HandleSDKError(ctx, err, diags)
+			return
+		}
+		// A configured region is checked when validating the configuration
+		if plan.RegionID.IsUnknown() || plan.RegionID.IsNull() {
+			checkRegionGuardrails(guardrails, source.regionID, diags)
+		}
+		modules := map[string]path.Path{}
+		for _, name := range source.modules {
+			modules[name] = path.Root("source_stack_id")
+		}
+		checkModuleGuardrails(guardrails, modules, diags)
+		checkMetadataGuardrails(guardrails, source.metadata, path.Root("source_stack_id"), diags)
+		if diags.HasError() {
+			return
+		}
+	}
+
+	if guardrails.MaxStacks > 0 {
+		// Clones carry no idempotency key, and never adopt a stack
+		checkMaxStacks(ctx, s.store, organizationId, "", plan.Name.ValueString(), diags)
+	}
+}
+
+// Create implements resource.Resource.
+func (s *StackClone) Create(ctx context.Context, req resource.CreateRequest, res *resource.CreateResponse) {
+	var plan StackCloneModel
+	res.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		res.Diagnostics.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	source, err := readSnapshot(ctx, s.store.GetSDK(), organizationId, plan.SourceStackID.ValueString())
+	if err != nil {
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		return
+	}
+	if plan.RegionID.IsUnknown() || plan.RegionID.IsNull() {
+		plan.RegionID = types.StringValue(source.regionID)
+	}
+
+	operation, err := s.store.GetSDK().CreateStack(ctx, organizationId, &shared.CreateStackRequest{
+		Name:     plan.Name.ValueString(),
+		RegionID: plan.RegionID.ValueString(),
+		Version:  pointer.For(source.version),
+		Metadata: cloneMetadata(source.metadata, s.store.GetWorkspaceID()),
+	})
+	if err != nil {
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		return
+	}
+
+	// The stack is now listed, and no longer counted as planned against max_stacks
+	s.store.UnplanStack("")
+
+	stack := operation.CreateStackResponse.Data
+	plan.ID = types.StringValue(stack.ID)
+	plan.URI = types.StringValue(stack.URI)
+
+	// From now on the stack exists, record it even if the replication fails
+	created := &stackSnapshot{
+		version:      source.version,
+		userAccesses: map[string]int64{},
+		metadata:     source.metadata,
+	}
+	if stack.Version != nil {
+		created.version = *stack.Version
+	}
+	plan.set(created)
+	res.Diagnostics.Append(s.replicate(ctx, organizationId, &plan, source)...)
+
+	res.Diagnostics.Append(res.State.Set(ctx, &plan)...)
+}
+
+// Update implements resource.Resource.
+func (s *StackClone) Update(ctx context.Context, req resource.UpdateRequest, res *resource.UpdateResponse) {
+	var plan StackCloneModel
+	var state StackCloneModel
+	res.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	res.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		res.Diagnostics.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	source, diags := plan.snapshot(ctx)
+	res.Diagnostics.Append(diags...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	if !plan.Version.Equal(state.Version) {
+		if _, err := s.store.GetSDK().UpgradeStack(ctx, organizationId, state.ID.ValueString(), source.version); err != nil {
+			pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+			return
+		}
+	}
+	if !plan.Metadata.Equal(state.Metadata) {
+		if _, err := s.store.GetSDK().UpdateStack(ctx, organizationId, state.ID.ValueString(), &shared.StackData{
+			Name:     state.Name.ValueString(),
+			Metadata: cloneMetadata(source.metadata, s.store.GetWorkspaceID()),
+		}); err != nil {
+			pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+			return
+		}
+	}
+
+	// Replicate from the state of the clone so that only the differences are applied
+	plan.Modules = state.Modules
+	plan.UserAccesses = state.UserAccesses
+	res.Diagnostics.Append(s.replicate(ctx, organizationId, &plan, source)...)
+
+	res.Diagnostics.Append(res.State.Set(ctx, &plan)...)
+}
+
+// replicate applies the modules and user accesses of the source to the clone, starting from the ones recorded
+// in the model. The model is updated along the way so that it reflects what was actually replicated.
+func (s *StackClone) replicate(ctx context.Context, organizationId string, model *StackCloneModel, source *stackSnapshot) diag.Diagnostics {
+	var diags diag.Diagnostics
+	stackID := model.ID.ValueString()
+
+	current, d := model.snapshot(ctx)
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
+
+	if !model.Modules.Equal(modulesValue(source.modules)) {
+		modules, err := syncStackModules(ctx, s.store.GetSDK(), organizationId, stackID, source.modules)
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("modules"),
+				"Failed to clone stack modules",
+				fmt.Sprintf("Unable to enable the modules of stack '%s' on stack '%s': %s", model.SourceStackID.ValueString(), stackID, err),
+			)
+			return diags
+		}
+		model.Modules = modulesValue(enabledModules(modules))
+	}
+
+	accesses := maps.Clone(current.userAccesses)
+	defer func() {
+		values := make(map[string]attr.Value, len(accesses))
+		for userID, policyID := range accesses {
+			values[userID] = types.Int64Value(policyID)
+		}
+		model.UserAccesses = types.MapValueMust(types.Int64Type, values)
+	}()
+
+	for userID := range current.userAccesses {
+		if _, ok := source.userAccesses[userID]; ok {
+			continue
+		}
+		if _, err := s.store.GetSDK().DeleteStackUserAccess(ctx, organizationId, stackID, userID); err != nil {
+			pkg.HandleSDKError(ctx, err, &diags)
+			return diags
+		}
+		delete(accesses, userID)
+	}
+	for userID, policyID := range source.userAccesses {
+		if existing, ok := current.userAccesses[userID]; ok && existing == policyID {
+			continue
+		}
+		if _, err := s.store.GetSDK().UpsertStackUserAccess(ctx, organizationId, stackID, userID, &shared.UpdateStackUserRequest{
+			PolicyID: policyID,
+		}); err != nil {
+			pkg.HandleSDKError(ctx, err, &diags)
+			return diags
+		}
+		accesses[userID] = policyID
+	}
+
+	return diags
+}
+
+// Read implements resource.Resource.
+func (s *StackClone) Read(ctx context.Context, req resource.ReadRequest, res *resource.ReadResponse) {
+	var state StackCloneModel
+	res.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		res.Diagnostics.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	clone, err := readSnapshot(ctx, s.store.GetSDK(), organizationId, state.ID.ValueString())
+	if err != nil {
+		if errors.Is(err, pkg.ErrNotFound) {
+			res.State.RemoveResource(ctx)
+			return
+		}
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		return
+	}
+
+	// Only the cloned user accesses are tracked, the ones granted on the clone afterwards are left alone
+	tracked := map[string]int64{}
+	res.Diagnostics.Append(state.UserAccesses.ElementsAs(ctx, &tracked, false)...)
+	for userID := range clone.userAccesses {
+		if _, ok := tracked[userID]; !ok {
+			delete(clone.userAccesses, userID)
+		}
+	}
+
+	state.RegionID = types.StringValue(clone.regionID)
+	state.set(clone)
+	res.Diagnostics.Append(res.State.Set(ctx, &state)...)
+}
+
+// Delete implements resource.Resource.
+func (s *StackClone) Delete(ctx context.Context, req resource.DeleteRequest, res *resource.DeleteResponse) {
+	var state StackCloneModel
+	res.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		res.Diagnostics.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	if _, err := s.store.GetSDK().DeleteStack(ctx, organizationId, state.ID.ValueString(), state.ForceDestroy.ValueBool()); err != nil {
+		if errors.Is(err, pkg.ErrNotFound) {
+			res.Diagnostics.AddWarning(
+				"Stack not found",
+				"The stack was not found. It may have already been deleted outside of Terraform.",
+			)
+			return
+		}
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		return
+	}
+}
+
+// cloneMetadata returns the metadata to send to the API for a clone. Clones are never protected against deletion.
+func cloneMetadata(metadata map[string]string, workspaceID string) map[string]string {
+	md := maps.Clone(metadata)
+	if md == nil {
+		md = map[string]string{}
+	}
+	md[MetadataProtectedKey] = stackMarker(workspaceID)
+	md[MetadataDeletionProtectionKey] = strconv.FormatBool(false)
+	return md
+}
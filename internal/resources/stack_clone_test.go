@@ -0,0 +1,305 @@
+package resources_test
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/formancehq/go-libs/v3/pointer"
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/internal/resources"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/sdkerrors"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStackCloneCreate(t *testing.T) {
+	test(t, func(ctx context.Context) {
+		r := resources.NewStackClone()().(resource.ResourceWithConfigure)
+		organizationId := uuid.NewString()
+		ctrl := gomock.NewController(t)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		store := internal.NewStore(apiMock, tp)
+
+		tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{
+			ProviderData: store,
+		}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+
+		expectStackSnapshot(apiMock, organizationId, "source", []string{"ledger"}, map[string]int64{"user": 2})
+		apiMock.EXPECT().CreateStack(gomock.Any(), organizationId, &shared.CreateStackRequest{
+			Name:     "clone",
+			RegionID: "staging",
+			Version:  pointer.For("v2.1.0"),
+			Metadata: map[string]string{
+				"team":                                  "payments",
+				resources.MetadataProtectedKey:          "true",
+				resources.MetadataDeletionProtectionKey: "false",
+			},
+		}).Return(&operations.CreateStackResponse{
+			StatusCode: http.StatusCreated,
+			CreateStackResponse: &shared.CreateStackResponse{
+				Data: &shared.Stack{
+					ID:       "clone",
+					RegionID: "staging",
+					Version:  pointer.For("v2.1.0"),
+				},
+			},
+		}, nil)
+		apiMock.EXPECT().ListModules(gomock.Any(), organizationId, "clone").Return(&operations.ListModulesResponse{
+			StatusCode:          http.StatusOK,
+			ListModulesResponse: &shared.ListModulesResponse{},
+		}, nil)
+		apiMock.EXPECT().EnableModule(gomock.Any(), organizationId, "clone", "ledger").Return(&operations.EnableModuleResponse{
+			StatusCode: http.StatusNoContent,
+		}, nil)
+		apiMock.EXPECT().ListModules(gomock.Any(), organizationId, "clone").Return(&operations.ListModulesResponse{
+			StatusCode: http.StatusOK,
+			ListModulesResponse: &shared.ListModulesResponse{
+				Data: []shared.Module{
+					{Name: "ledger", State: shared.ModuleStateEnabled, Status: shared.ModuleStatusReady},
+				},
+			},
+		}, nil)
+		apiMock.EXPECT().UpsertStackUserAccess(gomock.Any(), organizationId, "clone", "user", &shared.UpdateStackUserRequest{
+			PolicyID: 2,
+		}).Return(&operations.UpsertStackUserAccessResponse{
+			StatusCode: http.StatusNoContent,
+		}, nil)
+
+		res := resource.CreateResponse{
+			State: tfsdk.State{
+				Schema: resources.SchemaStackClone,
+			},
+		}
+		r.Create(ctx, resource.CreateRequest{
+			Plan: tfsdk.Plan{
+				Raw:    stackCloneValue(nil),
+				Schema: resources.SchemaStackClone,
+			},
+		}, &res)
+		require.Empty(t, res.Diagnostics)
+
+		model := &resources.StackCloneModel{}
+		res.State.Get(ctx, model)
+		require.Equal(t, "clone", model.ID.ValueString())
+		require.Equal(t, "staging", model.RegionID.ValueString())
+		require.Equal(t, "v2.1.0", model.Version.ValueString())
+
+		var modules []string
+		model.Modules.ElementsAs(ctx, &modules, false)
+		require.Equal(t, []string{"ledger"}, modules)
+
+		accesses := map[string]int64{}
+		model.UserAccesses.ElementsAs(ctx, &accesses, false)
+		require.Equal(t, map[string]int64{"user": 2}, accesses)
+
+		metadata := map[string]string{}
+		model.Metadata.ElementsAs(ctx, &metadata, false)
+		require.Equal(t, map[string]string{"team": "payments"}, metadata)
+	})
+}
+
+func TestStackCloneModifyPlan(t *testing.T) {
+	test(t, func(ctx context.Context) {
+		r := resources.NewStackClone()().(resource.ResourceWithConfigure)
+		organizationId := uuid.NewString()
+		ctrl := gomock.NewController(t)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		store := internal.NewStore(apiMock, tp)
+
+		tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{
+			ProviderData: store,
+		}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+
+		// A module was enabled on the source since it was cloned
+		expectStackSnapshot(apiMock, organizationId, "source", []string{"ledger", "payments"}, map[string]int64{"user": 2})
+
+		state := stackCloneValue(map[string]tftypes.Value{
+			"id":        tftypes.NewValue(tftypes.String, "clone"),
+			"region_id": tftypes.NewValue(tftypes.String, "staging"),
+			"uri":       tftypes.NewValue(tftypes.String, "https://clone"),
+			"version":   tftypes.NewValue(tftypes.String, "v2.1.0"),
+			"modules": tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, []tftypes.Value{
+				tftypes.NewValue(tftypes.String, "ledger"),
+			}),
+			"user_accesses": tftypes.NewValue(tftypes.Map{ElementType: tftypes.Number}, map[string]tftypes.Value{
+				"user": tftypes.NewValue(tftypes.Number, 2),
+			}),
+			"metadata": tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{
+				"team": tftypes.NewValue(tftypes.String, "payments"),
+			}),
+		})
+		plan := stackCloneValue(map[string]tftypes.Value{
+			"id":        tftypes.NewValue(tftypes.String, "clone"),
+			"region_id": tftypes.NewValue(tftypes.String, "staging"),
+			"uri":       tftypes.NewValue(tftypes.String, "https://clone"),
+		})
+
+		res := resource.ModifyPlanResponse{
+			Plan: tfsdk.Plan{
+				Raw:    plan,
+				Schema: resources.SchemaStackClone,
+			},
+		}
+		r.(resource.ResourceWithModifyPlan).ModifyPlan(ctx, resource.ModifyPlanRequest{
+			State: tfsdk.State{
+				Raw:    state,
+				Schema: resources.SchemaStackClone,
+			},
+			Plan: tfsdk.Plan{
+				Raw:    plan,
+				Schema: resources.SchemaStackClone,
+			},
+		}, &res)
+		require.Empty(t, res.Diagnostics)
+
+		model := &resources.StackCloneModel{}
+		res.Plan.Get(ctx, model)
+		require.Equal(t, "v2.1.0", model.Version.ValueString())
+
+		var modules []string
+		model.Modules.ElementsAs(ctx, &modules, false)
+		require.ElementsMatch(t, []string{"ledger", "payments"}, modules)
+	})
+}
+
+func TestStackCloneGuardrails(t *testing.T) {
+	setup := func(t *testing.T, ctx context.Context, guardrails internal.Guardrails) (resource.Resource, *pkg.MockCloudSDK, string) {
+		organizationId := uuid.NewString()
+		ctrl := gomock.NewController(t)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+		store := internal.NewStore(apiMock, tp)
+		store.SetGuardrails(guardrails)
+
+		r := resources.NewStackClone()().(resource.ResourceWithConfigure)
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{ProviderData: store}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+		return r, apiMock, organizationId
+	}
+	modifyPlan := func(ctx context.Context, r resource.Resource) resource.ModifyPlanResponse {
+		plan := tfsdk.Plan{Raw: stackCloneValue(nil), Schema: resources.SchemaStackClone}
+		res := resource.ModifyPlanResponse{Plan: plan}
+		r.(resource.ResourceWithModifyPlan).ModifyPlan(ctx, resource.ModifyPlanRequest{
+			Plan: plan,
+			State: tfsdk.State{
+				Raw:    tftypes.NewValue(tftypes.Object{AttributeTypes: getSchemaTypes(resources.SchemaStackClone)}, nil),
+				Schema: resources.SchemaStackClone,
+			},
+		}, &res)
+		return res
+	}
+
+	t.Run("name and region are validated", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, _, _ := setup(t, ctx, internal.Guardrails{
+				AllowedRegionIDs: []string{"staging"},
+				StackNamePattern: regexp.MustCompile(`^prod-[a-z]+$`),
+			})
+
+			res := resource.ValidateConfigResponse{}
+			r.(resource.ResourceWithValidateConfig).ValidateConfig(ctx, resource.ValidateConfigRequest{
+				Config: tfsdk.Config{
+					Raw: stackCloneValue(map[string]tftypes.Value{
+						"region_id": tftypes.NewValue(tftypes.String, "eu-west"),
+					}),
+					Schema: resources.SchemaStackClone,
+				},
+			}, &res)
+
+			require.Len(t, res.Diagnostics, 2)
+			for _, d := range res.Diagnostics {
+				require.Equal(t, "Policy Violation", d.Summary())
+			}
+		})
+	})
+
+	t.Run("source stack is checked on creation", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, apiMock, organizationId := setup(t, ctx, internal.Guardrails{
+				AllowedRegionIDs:     []string{"eu-west"},
+				AllowedModules:       []string{"payments"},
+				RequiredMetadataKeys: []string{"cost_center"},
+			})
+			expectStackSnapshot(apiMock, organizationId, "source", []string{"ledger"}, nil)
+
+			res := modifyPlan(ctx, r)
+			require.Len(t, res.Diagnostics, 3)
+			for _, d := range res.Diagnostics {
+				require.Equal(t, "Policy Violation", d.Summary())
+			}
+		})
+	})
+
+	t.Run("clones are counted against max_stacks", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, apiMock, organizationId := setup(t, ctx, internal.Guardrails{MaxStacks: 1})
+			apiMock.EXPECT().ListStacks(gomock.Any(), organizationId).Return(&operations.ListStacksResponse{
+				ListStacksResponse: &shared.ListStacksResponse{Data: []shared.Stack{
+					{ID: "source", Name: "source", State: shared.StackStateActive},
+				}},
+			}, nil)
+
+			res := modifyPlan(ctx, r)
+			require.Len(t, res.Diagnostics, 1)
+			require.Equal(t, "Policy Violation", res.Diagnostics[0].Summary())
+			require.Contains(t, res.Diagnostics[0].Detail(), "brings the organization to 2 stacks, 1 existing and 1 planned")
+		})
+	})
+}
+
+func TestStackCloneDeleteNotFound(t *testing.T) {
+	test(t, func(ctx context.Context) {
+		r := resources.NewStackClone()().(resource.ResourceWithConfigure)
+		organizationId := uuid.NewString()
+		ctrl := gomock.NewController(t)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		store := internal.NewStore(apiMock, tp)
+
+		tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{
+			ProviderData: store,
+		}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+
+		apiMock.EXPECT().DeleteStack(gomock.Any(), organizationId, "clone", false).Return(nil, pkg.NewAPIError(&sdkerrors.SDKError{
+			StatusCode: http.StatusNotFound,
+		}))
+
+		res := resource.DeleteResponse{}
+		r.Delete(ctx, resource.DeleteRequest{
+			State: tfsdk.State{
+				Raw: stackCloneValue(map[string]tftypes.Value{
+					"id":        tftypes.NewValue(tftypes.String, "clone"),
+					"region_id": tftypes.NewValue(tftypes.String, "staging"),
+					"uri":       tftypes.NewValue(tftypes.String, "https://clone"),
+				}),
+				Schema: resources.SchemaStackClone,
+			},
+		}, &res)
+
+		require.Len(t, res.Diagnostics, 1)
+		require.False(t, res.Diagnostics.HasError())
+		require.Equal(t, "Stack not found", res.Diagnostics[0].Summary())
+	})
+}
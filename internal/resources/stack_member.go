@@ -2,11 +2,13 @@ package resources
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/formancehq/terraform-provider-cloud/internal"
 	"github.com/formancehq/terraform-provider-cloud/pkg"
 	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/types"
@@ -90,7 +92,7 @@ func (s *StackMember) Create(ctx context.Context, req resource.CreateRequest, re
 	}
 	_, err = s.store.GetSDK().UpsertStackUserAccess(ctx, organizationId, plan.StackId.ValueString(), plan.UserId.ValueString(), body)
 	if err != nil {
-		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics, pkg.WithField("policyId", path.Root("policy_id")))
 		return
 	}
 
@@ -114,6 +116,13 @@ func (s *StackMember) Delete(ctx context.Context, req resource.DeleteRequest, re
 	}
 	_, err = s.store.GetSDK().DeleteStackUserAccess(ctx, organizationId, state.StackId.ValueString(), state.UserId.ValueString())
 	if err != nil {
+		if errors.Is(err, pkg.ErrNotFound) {
+			res.Diagnostics.AddWarning(
+				"Stack not found",
+				"The stack was not found. It may have already been deleted outside of Terraform.",
+			)
+			return
+		}
 		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
 		return
 	}
@@ -142,7 +151,7 @@ func (s *StackMember) Update(ctx context.Context, req resource.UpdateRequest, re
 
 	_, err = s.store.GetSDK().UpsertStackUserAccess(ctx, organizationId, plan.StackId.ValueString(), plan.UserId.ValueString(), body)
 	if err != nil {
-		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics, pkg.WithField("policyId", path.Root("policy_id")))
 		return
 	}
 
@@ -171,6 +180,10 @@ func (s *StackMember) Read(ctx context.Context, req resource.ReadRequest, res *r
 	}
 	userAccess, err := s.store.GetSDK().ReadStackUserAccess(ctx, organizationId, state.StackId.ValueString(), state.UserId.ValueString())
 	if err != nil {
+		if errors.Is(err, pkg.ErrNotFound) {
+			res.State.RemoveResource(ctx)
+			return
+		}
 		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
 		return
 	}
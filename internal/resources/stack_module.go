@@ -2,6 +2,7 @@ package resources
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/formancehq/go-libs/v3/collectionutils"
@@ -18,6 +19,7 @@ var (
 	_ resource.Resource                   = &StackModule{}
 	_ resource.ResourceWithConfigure      = &StackModule{}
 	_ resource.ResourceWithValidateConfig = &StackModule{}
+	_ resource.ResourceWithModifyPlan     = &StackModule{}
 )
 
 type StackModule struct {
@@ -28,7 +30,7 @@ var SchemaStackModule = schema.Schema{
 	Description: "Manages modules within a Formance Cloud stack. Modules are individual services that can be enabled or disabled on a stack.",
 	Attributes: map[string]schema.Attribute{
 		"name": schema.StringAttribute{
-			Description: "The name of the module to enable. Valid module names include: ledger, payments, webhooks, wallets, search, reconciliation, orchestration, auth, stargate.",
+			Description: "The name of the module to enable. It is validated at plan time against the modules available in the region of the stack, unless the stack is created by the same apply.",
 			Required:    true,
 		},
 		"stack_id": schema.StringAttribute{
@@ -72,6 +74,69 @@ func (s *StackModule) ValidateConfig(ctx context.Context, req resource.ValidateC
 			"The stack_id attribute must not be null.",
 		)
 	}
+
+	// The guardrails are only known once the provider is configured, Terraform validates the configuration again when planning
+	if s.store != nil && !config.Name.IsNull() && !config.Name.IsUnknown() {
+		checkModuleGuardrails(s.store.GetGuardrails(), map[string]path.Path{
+			config.Name.ValueString(): path.Root("name"),
+		}, &res.Diagnostics)
+	}
+}
+
+// ModifyPlan implements resource.ResourceWithModifyPlan.
+// It checks new and renamed modules against the modules available in the region of the stack.
+func (s *StackModule) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, res *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || s.store == nil {
+		return
+	}
+
+	var plan StackModuleModel
+	res.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	if !plan.StackId.IsUnknown() && s.store.ClaimStandaloneModule(plan.StackId.ValueString()) {
+		res.Diagnostics.AddAttributeWarning(
+			path.Root("stack_id"),
+			"Conflicting Module Management",
+			fmt.Sprintf("Stack '%s' sets the modules attribute, which is authoritative and will disable module '%s' unless it lists it. Manage the modules of a stack either with cloud_stack.modules or with cloud_stack_module resources.", plan.StackId.ValueString(), plan.Name.ValueString()),
+		)
+	}
+
+	// Existing modules are only checked when renamed, so that a module dropped from the catalog of
+	// its region does not break the plans which leave it unchanged
+	if !req.State.Raw.IsNull() {
+		var state StackModuleModel
+		res.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		if res.Diagnostics.HasError() || state.Name.Equal(plan.Name) {
+			return
+		}
+	}
+
+	// The region of a stack created by the same apply is unknown, the API rejects the module during apply in that case
+	if plan.StackId.IsUnknown() || plan.Name.IsUnknown() {
+		return
+	}
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		res.Diagnostics.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	operation, err := s.store.GetSDK().ReadStack(ctx, organizationId, plan.StackId.ValueString())
+	if err != nil {
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		return
+	}
+
+	checkModuleNames(ctx, s.store.GetSDK(), organizationId, operation.CreateStackResponse.Data.RegionID, map[string]path.Path{
+		plan.Name.ValueString(): path.Root("name"),
+	}, &res.Diagnostics)
 }
 
 // Configure implements resource.ResourceWithConfigure.
@@ -109,7 +174,7 @@ func (s *StackModule) Create(ctx context.Context, req resource.CreateRequest, re
 	}
 	_, err = s.store.GetSDK().EnableModule(ctx, organizationId, plan.StackId.ValueString(), plan.Name.ValueString())
 	if err != nil {
-		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
+		pkg.HandleSDKError(ctx, err, &res.Diagnostics, pkg.WithAttribute(path.Root("name")))
 		return
 	}
 
@@ -133,6 +198,13 @@ func (s *StackModule) Delete(ctx context.Context, req resource.DeleteRequest, re
 	}
 	_, err = s.store.GetSDK().DisableModule(ctx, organizationId, state.StackId.ValueString(), state.Name.ValueString())
 	if err != nil {
+		if errors.Is(err, pkg.ErrNotFound) {
+			res.Diagnostics.AddWarning(
+				"Stack not found",
+				"The stack was not found. It may have already been deleted outside of Terraform.",
+			)
+			return
+		}
 		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
 		return
 	}
@@ -160,6 +232,10 @@ func (s *StackModule) Read(ctx context.Context, req resource.ReadRequest, res *r
 	}
 	modules, err := s.store.GetSDK().ListModules(ctx, organizationId, state.StackId.ValueString())
 	if err != nil {
+		if errors.Is(err, pkg.ErrNotFound) {
+			res.State.RemoveResource(ctx)
+			return
+		}
 		pkg.HandleSDKError(ctx, err, &res.Diagnostics)
 		return
 	}
@@ -167,8 +243,9 @@ func (s *StackModule) Read(ctx context.Context, req resource.ReadRequest, res *r
 	obj := collectionutils.First(modules.ListModulesResponse.Data, func(m shared.Module) bool {
 		return m.Name == state.Name.ValueString()
 	})
-	if obj.Name == "" {
-		res.Diagnostics.AddError("Module not found", fmt.Sprintf("Module with name '%s' not found in stack '%s'", state.Name.ValueString(), state.StackId.ValueString()))
+	if obj.Name == "" || obj.State == shared.ModuleStateDisabled {
+		// Module disabled outside of Terraform, plan to enable it again
+		res.State.RemoveResource(ctx)
 		return
 	}
 
@@ -2,12 +2,16 @@ package resources_test
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/formancehq/go-libs/v3/pointer"
 	"github.com/formancehq/terraform-provider-cloud/internal"
 	"github.com/formancehq/terraform-provider-cloud/internal/resources"
 	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/sdkerrors"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
 	"github.com/google/uuid"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -133,3 +137,331 @@ func TestStackModuleValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestStackModuleModifyPlan(t *testing.T) {
+	type testCase struct {
+		name            string
+		module          string
+		expectedSummary string
+		expectedDetail  string
+		expectedError   bool
+	}
+
+	for _, tc := range []testCase{
+		{
+			name:   "available module",
+			module: "payments",
+		},
+		{
+			name:            "typo",
+			module:          "payment",
+			expectedSummary: "Unknown Module",
+			expectedDetail:  "Did you mean 'payments'?",
+			expectedError:   true,
+		},
+		{
+			name:            "enterprise module",
+			module:          "orchestration",
+			expectedSummary: "Enterprise Module",
+			expectedDetail:  "Formance Enterprise license",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStackModule()().(resource.ResourceWithConfigure)
+				organizationId := uuid.NewString()
+				stackID := uuid.NewString()
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+				configureRes := resource.ConfigureResponse{}
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, stackID).Return(&operations.GetStackResponse{
+					StatusCode: http.StatusOK,
+					CreateStackResponse: &shared.CreateStackResponse{
+						Data: &shared.Stack{
+							ID:       stackID,
+							RegionID: "staging",
+						},
+					},
+				}, nil)
+				apiMock.EXPECT().GetRegion(gomock.Any(), organizationId, "staging").Return(&operations.GetRegionResponse{
+					StatusCode: http.StatusOK,
+					GetRegionResponse: &shared.GetRegionResponse{
+						Data: shared.AnyRegion{
+							ID: "staging",
+							Capabilities: shared.RegionCapability{
+								ModuleList: []string{"ledger", "payments", "webhooks"},
+								Ee:         []string{"orchestration"},
+							},
+						},
+					},
+				}, nil)
+
+				raw := tftypes.NewValue(tftypes.Object{
+					AttributeTypes: getSchemaTypes(resources.SchemaStackModule),
+				}, map[string]tftypes.Value{
+					"name":     tftypes.NewValue(tftypes.String, tc.module),
+					"stack_id": tftypes.NewValue(tftypes.String, stackID),
+				})
+				res := resource.ModifyPlanResponse{
+					Plan: tfsdk.Plan{
+						Raw:    raw,
+						Schema: resources.SchemaStackModule,
+					},
+				}
+				r.(resource.ResourceWithModifyPlan).ModifyPlan(ctx, resource.ModifyPlanRequest{
+					Plan: tfsdk.Plan{
+						Raw:    raw,
+						Schema: resources.SchemaStackModule,
+					},
+				}, &res)
+
+				if tc.expectedSummary == "" {
+					require.Empty(t, res.Diagnostics)
+					return
+				}
+				require.Len(t, res.Diagnostics, 1)
+				require.Equal(t, tc.expectedSummary, res.Diagnostics[0].Summary())
+				require.Contains(t, res.Diagnostics[0].Detail(), tc.expectedDetail)
+				require.Equal(t, tc.expectedError, res.Diagnostics.HasError())
+			})
+		})
+	}
+}
+
+func TestStackModuleModifyPlanSkipsChecks(t *testing.T) {
+	value := func(name, stackID any) tftypes.Value {
+		return tftypes.NewValue(tftypes.Object{
+			AttributeTypes: getSchemaTypes(resources.SchemaStackModule),
+		}, map[string]tftypes.Value{
+			"name":     tftypes.NewValue(tftypes.String, name),
+			"stack_id": tftypes.NewValue(tftypes.String, stackID),
+		})
+	}
+
+	for _, tc := range []struct {
+		name  string
+		plan  tftypes.Value
+		state tftypes.Value
+	}{
+		{
+			name:  "unchanged module",
+			plan:  value("payments", "stack-id"),
+			state: value("payments", "stack-id"),
+		},
+		{
+			name:  "stack created by the same apply",
+			plan:  value("payments", tftypes.UnknownValue),
+			state: tftypes.NewValue(tftypes.Object{AttributeTypes: getSchemaTypes(resources.SchemaStackModule)}, nil),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStackModule()().(resource.ResourceWithConfigure)
+				ctrl := gomock.NewController(t)
+				// No call is expected to the API
+				store := internal.NewStore(pkg.NewMockCloudSDK(ctrl), pkg.NewMockTokenProviderImpl(ctrl))
+
+				configureRes := resource.ConfigureResponse{}
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				plan := tfsdk.Plan{Raw: tc.plan, Schema: resources.SchemaStackModule}
+				res := resource.ModifyPlanResponse{Plan: plan}
+				r.(resource.ResourceWithModifyPlan).ModifyPlan(ctx, resource.ModifyPlanRequest{
+					Plan:  plan,
+					State: tfsdk.State{Raw: tc.state, Schema: resources.SchemaStackModule},
+				}, &res)
+				require.Empty(t, res.Diagnostics)
+			})
+		})
+	}
+}
+
+func TestStackModuleGuardrails(t *testing.T) {
+	test(t, func(ctx context.Context) {
+		r := resources.NewStackModule()().(resource.ResourceWithConfigure)
+		ctrl := gomock.NewController(t)
+		store := internal.NewStore(pkg.NewMockCloudSDK(ctrl), pkg.NewMockTokenProviderImpl(ctrl))
+		store.SetGuardrails(internal.Guardrails{AllowedModules: []string{"ledger", "payments"}})
+
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{
+			ProviderData: store,
+		}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+
+		raw := tftypes.NewValue(tftypes.Object{
+			AttributeTypes: getSchemaTypes(resources.SchemaStackModule),
+		}, map[string]tftypes.Value{
+			"name":     tftypes.NewValue(tftypes.String, "webhooks"),
+			"stack_id": tftypes.NewValue(tftypes.String, uuid.NewString()),
+		})
+		res := resource.ValidateConfigResponse{}
+		r.(resource.ResourceWithValidateConfig).ValidateConfig(ctx, resource.ValidateConfigRequest{
+			Config: tfsdk.Config{Raw: raw, Schema: resources.SchemaStackModule},
+		}, &res)
+
+		require.Len(t, res.Diagnostics, 1)
+		require.Equal(t, "Policy Violation", res.Diagnostics[0].Summary())
+		require.Contains(t, res.Diagnostics[0].Detail(), "Allowed modules: ledger, payments.")
+	})
+}
+
+func TestStackModuleRead(t *testing.T) {
+	type testCase struct {
+		name       string
+		modules    []shared.Module
+		err        error
+		expectGone bool
+	}
+
+	for _, tc := range []testCase{
+		{
+			name: "enabled",
+			modules: []shared.Module{
+				{Name: "ledger", State: shared.ModuleStateEnabled, Status: shared.ModuleStatusReady},
+			},
+		},
+		{
+			name: "disabled outside of terraform",
+			modules: []shared.Module{
+				{Name: "ledger", State: shared.ModuleStateDisabled, Status: shared.ModuleStatusDeleted},
+			},
+			expectGone: true,
+		},
+		{
+			name:       "stack deleted",
+			err:        pkg.NewAPIError(&sdkerrors.SDKError{StatusCode: http.StatusNotFound}),
+			expectGone: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStackModule()().(resource.ResourceWithConfigure)
+				organizationId := uuid.NewString()
+				stackID := uuid.NewString()
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+				configureRes := resource.ConfigureResponse{}
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				if tc.err != nil {
+					apiMock.EXPECT().ListModules(gomock.Any(), organizationId, stackID).Return(nil, tc.err)
+				} else {
+					apiMock.EXPECT().ListModules(gomock.Any(), organizationId, stackID).Return(&operations.ListModulesResponse{
+						StatusCode: http.StatusOK,
+						ListModulesResponse: &shared.ListModulesResponse{
+							Data: tc.modules,
+						},
+					}, nil)
+				}
+
+				state := tftypes.NewValue(tftypes.Object{
+					AttributeTypes: getSchemaTypes(resources.SchemaStackModule),
+				}, map[string]tftypes.Value{
+					"name":     tftypes.NewValue(tftypes.String, "ledger"),
+					"stack_id": tftypes.NewValue(tftypes.String, stackID),
+				})
+				res := resource.ReadResponse{
+					State: tfsdk.State{
+						Raw:    state,
+						Schema: resources.SchemaStackModule,
+					},
+				}
+				r.Read(ctx, resource.ReadRequest{
+					State: tfsdk.State{
+						Raw:    state,
+						Schema: resources.SchemaStackModule,
+					},
+				}, &res)
+				require.Empty(t, res.Diagnostics)
+				require.Equal(t, tc.expectGone, res.State.Raw.IsNull())
+			})
+		})
+	}
+}
+
+func TestStackModuleDelete(t *testing.T) {
+	type testCase struct {
+		name            string
+		err             error
+		expectedWarning string
+	}
+
+	for _, tc := range []testCase{
+		{
+			name: "disabled",
+		},
+		{
+			name:            "stack deleted",
+			err:             pkg.NewAPIError(&sdkerrors.SDKError{StatusCode: http.StatusNotFound}),
+			expectedWarning: "Stack not found",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStackModule()().(resource.ResourceWithConfigure)
+				organizationId := uuid.NewString()
+				stackID := uuid.NewString()
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+				configureRes := resource.ConfigureResponse{}
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				if tc.err != nil {
+					apiMock.EXPECT().DisableModule(gomock.Any(), organizationId, stackID, "ledger").Return(nil, tc.err)
+				} else {
+					apiMock.EXPECT().DisableModule(gomock.Any(), organizationId, stackID, "ledger").Return(&operations.DisableModuleResponse{
+						StatusCode: http.StatusNoContent,
+					}, nil)
+				}
+
+				res := resource.DeleteResponse{}
+				r.Delete(ctx, resource.DeleteRequest{
+					State: tfsdk.State{
+						Raw: tftypes.NewValue(tftypes.Object{
+							AttributeTypes: getSchemaTypes(resources.SchemaStackModule),
+						}, map[string]tftypes.Value{
+							"name":     tftypes.NewValue(tftypes.String, "ledger"),
+							"stack_id": tftypes.NewValue(tftypes.String, stackID),
+						}),
+						Schema: resources.SchemaStackModule,
+					},
+				}, &res)
+
+				if tc.expectedWarning == "" {
+					require.Empty(t, res.Diagnostics)
+					return
+				}
+				require.Len(t, res.Diagnostics, 1)
+				require.False(t, res.Diagnostics.HasError())
+				require.Equal(t, tc.expectedWarning, res.Diagnostics[0].Summary())
+			})
+		})
+	}
+}
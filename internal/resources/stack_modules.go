@@ -0,0 +1,77 @@
+package resources
+
+import (
+	"context"
+	"slices"
+
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// syncStackModules enables and disables modules so that exactly the desired ones are enabled on the stack,
+// then waits for them to be ready.
+func syncStackModules(ctx context.Context, sdk pkg.CloudSDK, organizationID, stackID string, desired []string) ([]shared.Module, error) {
+	operation, err := sdk.ListModules(ctx, organizationID, stackID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := enabledModules(operation.ListModulesResponse.Data)
+	for _, name := range enabled {
+		if slices.Contains(desired, name) {
+			continue
+		}
+		if _, err := sdk.DisableModule(ctx, organizationID, stackID, name); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range desired {
+		if slices.Contains(enabled, name) {
+			continue
+		}
+		if _, err := sdk.EnableModule(ctx, organizationID, stackID, name); err != nil {
+			return nil, err
+		}
+	}
+
+	return waitModules(ctx, sdk, organizationID, stackID)
+}
+
+// enabledModules returns the sorted names of the enabled modules.
+func enabledModules(modules []shared.Module) []string {
+	names := make([]string, 0, len(modules))
+	for _, module := range modules {
+		if module.State == shared.ModuleStateEnabled {
+			names = append(names, module.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func modulesValue(names []string) types.Set {
+	elements := make([]attr.Value, 0, len(names))
+	for _, name := range names {
+		elements = append(elements, types.StringValue(name))
+	}
+	return types.SetValueMust(types.StringType, elements)
+}
+
+// setModules fills the modules and module_status attributes from the modules of the stack.
+func (m *StackModel) setModules(modules []shared.Module) {
+	m.Modules = modulesValue(enabledModules(modules))
+	m.setModuleStatus(modules)
+}
+
+// setModuleStatus fills the module_status attribute from the modules of the stack.
+func (m *StackModel) setModuleStatus(modules []shared.Module) {
+	status := make(map[string]attr.Value, len(modules))
+	for _, module := range modules {
+		if module.State == shared.ModuleStateEnabled {
+			status[module.Name] = types.StringValue(string(module.Status))
+		}
+	}
+	m.ModuleStatus = types.MapValueMust(types.StringType, status)
+}
@@ -0,0 +1,306 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/internal/versions"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ resource.Resource               = &StackRollout{}
+	_ resource.ResourceWithConfigure  = &StackRollout{}
+	_ resource.ResourceWithModifyPlan = &StackRollout{}
+)
+
+const (
+	RolloutStatusCompleted = "COMPLETED"
+	RolloutStatusHalted    = "HALTED"
+)
+
+var SchemaStackRollout = schema.Schema{
+	Description: "Upgrades many stacks to a version in ordered batches. Each batch is upgraded and must be READY and reachable before the next one starts. A failing batch halts the rollout; applying again resumes from the first incomplete batch. Stacks managed by another workspace or running a newer version halt the batch before any upgrade, since stacks cannot be downgraded.",
+	Attributes: map[string]schema.Attribute{
+		"id": schema.StringAttribute{
+			Description: "The unique identifier of the rollout.",
+			Computed:    true,
+			PlanModifiers: []planmodifier.String{
+				stringplanmodifier.UseStateForUnknown(),
+			},
+		},
+		"version": schema.StringAttribute{
+			Description: "The version every stack of the rollout is upgraded to.",
+			Required:    true,
+		},
+		"batches": schema.ListAttribute{
+			Description: "The ordered batches of stack IDs to upgrade.",
+			Required:    true,
+			ElementType: types.ListType{
+				ElemType: types.StringType,
+			},
+			Validators: []validator.List{
+				listvalidator.SizeAtLeast(1),
+			},
+		},
+		"completed_batches": schema.Int64Attribute{
+			Description: "The number of batches successfully upgraded.",
+			Computed:    true,
+		},
+		"status": schema.StringAttribute{
+			Description: "The status of the rollout: COMPLETED or HALTED.",
+			Computed:    true,
+		},
+	},
+}
+
+type StackRolloutModel struct {
+	ID               types.String `tfsdk:"id"`
+	Version          types.String `tfsdk:"version"`
+	Batches          types.List   `tfsdk:"batches"`
+	CompletedBatches types.Int64  `tfsdk:"completed_batches"`
+	Status           types.String `tfsdk:"status"`
+}
+
+type StackRollout struct {
+	store *internal.Store
+}
+
+func NewStackRollout() func() resource.Resource {
+	return func() resource.Resource {
+		return &StackRollout{}
+	}
+}
+
+// Schema implements resource.Resource.
+func (s *StackRollout) Schema(ctx context.Context, req resource.SchemaRequest, res *resource.SchemaResponse) {
+	res.Schema = SchemaStackRollout
+}
+
+// Metadata implements resource.Resource.
+func (s *StackRollout) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_stack_rollout"
+}
+
+// Configure implements resource.ResourceWithConfigure.
+func (s *StackRollout) Configure(ctx context.Context, req resource.ConfigureRequest, res *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	store, ok := req.ProviderData.(*internal.Store)
+	if !ok {
+		res.Diagnostics.AddError(
+			ErrProviderDataNotSet.Error(),
+			fmt.Sprintf("Expected *internal.Store, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	s.store = store
+}
+
+// ModifyPlan implements resource.ResourceWithModifyPlan.
+// Stacks whose cloud_stack resource pins another version are reported, and a halted rollout plans
+// an update so that the next apply resumes it.
+func (s *StackRollout) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, res *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+	if s.store != nil {
+		s.planPinnedVersions(ctx, req.Plan, &res.Diagnostics)
+	}
+	if req.State.Raw.IsNull() {
+		return
+	}
+
+	var state StackRolloutModel
+	res.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	if state.Status.ValueString() != RolloutStatusCompleted {
+		res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("completed_batches"), types.Int64Unknown())...)
+		res.Diagnostics.Append(res.Plan.SetAttribute(ctx, path.Root("status"), types.StringUnknown())...)
+	}
+}
+
+// planPinnedVersions warns about the stacks of the rollout whose cloud_stack resource pins another version:
+// the next plan of the resource would try to move the stack back to the pinned version.
+// Only the cloud_stack resources planned before the rollout, such as the ones its batches reference, are known.
+func (s *StackRollout) planPinnedVersions(ctx context.Context, plan tfsdk.Plan, diags *diag.Diagnostics) {
+	var model StackRolloutModel
+	diags.Append(plan.Get(ctx, &model)...)
+	if diags.HasError() || model.Version.IsUnknown() || model.Batches.IsUnknown() {
+		return
+	}
+
+	for i, element := range model.Batches.Elements() {
+		batch, ok := element.(types.List)
+		if !ok || batch.IsUnknown() {
+			continue
+		}
+		for _, value := range batch.Elements() {
+			stackID, ok := value.(types.String)
+			if !ok || stackID.IsUnknown() {
+				continue
+			}
+			pinned, ok := s.store.GetPinnedStackVersion(stackID.ValueString())
+			if !ok || versions.Equal(pinned, model.Version.ValueString()) {
+				continue
+			}
+			diags.AddAttributeWarning(
+				path.Root("batches").AtListIndex(i),
+				"Stack Version Pinned",
+				fmt.Sprintf("Stack '%s' is managed by a cloud_stack resource pinning version '%s', the rollout upgrades it to '%s'. Update the version of the resource, or use version_constraint, so that its next plan does not try to move the stack back.", stackID.ValueString(), pinned, model.Version.ValueString()),
+			)
+		}
+	}
+}
+
+// Create implements resource.Resource.
+func (s *StackRollout) Create(ctx context.Context, req resource.CreateRequest, res *resource.CreateResponse) {
+	var plan StackRolloutModel
+	res.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	plan.ID = types.StringValue(uuid.NewString())
+	s.run(ctx, &plan, 0, &res.Diagnostics)
+
+	res.Diagnostics.Append(res.State.Set(ctx, &plan)...)
+}
+
+// Update implements resource.Resource.
+func (s *StackRollout) Update(ctx context.Context, req resource.UpdateRequest, res *resource.UpdateResponse) {
+	var plan StackRolloutModel
+	var state StackRolloutModel
+	res.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	res.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	// Resume from the first incomplete batch as long as the rollout itself did not change
+	from := 0
+	if plan.Version.Equal(state.Version) && plan.Batches.Equal(state.Batches) {
+		from = int(state.CompletedBatches.ValueInt64())
+	}
+	plan.ID = state.ID
+	s.run(ctx, &plan, from, &res.Diagnostics)
+
+	res.Diagnostics.Append(res.State.Set(ctx, &plan)...)
+}
+
+// Read implements resource.Resource.
+func (s *StackRollout) Read(ctx context.Context, req resource.ReadRequest, res *resource.ReadResponse) {
+	var state StackRolloutModel
+	res.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if res.Diagnostics.HasError() {
+		return
+	}
+
+	res.Diagnostics.Append(res.State.Set(ctx, &state)...)
+}
+
+// Delete implements resource.Resource.
+func (s *StackRollout) Delete(ctx context.Context, req resource.DeleteRequest, res *resource.DeleteResponse) {
+	var state StackRolloutModel
+	res.Diagnostics.Append(req.State.Get(ctx, &state)...)
+}
+
+// run upgrades the batches starting at index from and records the progress in the model.
+func (s *StackRollout) run(ctx context.Context, model *StackRolloutModel, from int, diags *diag.Diagnostics) {
+	var batches [][]string
+	diags.Append(model.Batches.ElementsAs(ctx, &batches, false)...)
+	if diags.HasError() {
+		return
+	}
+
+	model.CompletedBatches = types.Int64Value(int64(from))
+	model.Status = types.StringValue(RolloutStatusHalted)
+
+	organizationId, err := s.store.GetOrganizationID(ctx)
+	if err != nil {
+		diags.AddError(
+			"Failed to get organization ID",
+			fmt.Sprintf("Error retrieving organization ID: %s", err),
+		)
+		return
+	}
+
+	for i := from; i < len(batches); i++ {
+		logging.FromContext(ctx).Debugf("Rolling out %s to batch %d/%d", model.Version.ValueString(), i+1, len(batches))
+		if err := s.upgradeBatch(ctx, organizationId, batches[i], model.Version.ValueString()); err != nil {
+			diags.AddAttributeError(
+				path.Root("batches").AtListIndex(i),
+				"Rollout halted",
+				fmt.Sprintf("Batch %d/%d failed: %s. %d batch(es) completed, apply again to resume the rollout.", i+1, len(batches), err, i),
+			)
+			return
+		}
+		model.CompletedBatches = types.Int64Value(int64(i + 1))
+	}
+
+	model.Status = types.StringValue(RolloutStatusCompleted)
+}
+
+// upgradeBatch upgrades every stack of the batch and waits for all of them to be healthy.
+// Stacks already running the version are left untouched. Nothing is upgraded when a stack of the batch
+// is managed by another workspace or runs a newer version, since stacks cannot be downgraded.
+func (s *StackRollout) upgradeBatch(ctx context.Context, organizationId string, stackIDs []string, version string) error {
+	workspaceID := s.store.GetWorkspaceID()
+	pending := make([]string, 0, len(stackIDs))
+	for _, stackID := range stackIDs {
+		operation, err := s.store.GetSDK().ReadStack(ctx, organizationId, stackID)
+		if err != nil {
+			return fmt.Errorf("stack '%s': %w", stackID, err)
+		}
+
+		stack := operation.CreateStackResponse.Data
+		if owner, ok := otherWorkspace(workspaceID, stack.Metadata); ok {
+			return fmt.Errorf("stack '%s' is managed by the Terraform workspace '%s'", stackID, owner)
+		}
+		if stack.Version == nil {
+			pending = append(pending, stackID)
+			continue
+		}
+		if versions.Equal(*stack.Version, version) {
+			continue
+		}
+		if isDowngrade(*stack.Version, version) {
+			return fmt.Errorf("stack '%s' runs version '%s' and cannot be downgraded", stackID, *stack.Version)
+		}
+		pending = append(pending, stackID)
+	}
+
+	for _, stackID := range pending {
+		if _, err := s.store.GetSDK().UpgradeStack(ctx, organizationId, stackID, version); err != nil {
+			return fmt.Errorf("stack '%s': %w", stackID, err)
+		}
+	}
+
+	var errs []error
+	for _, stackID := range pending {
+		if _, err := waitStackHealthy(ctx, s.store.GetSDK(), organizationId, stackID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,291 @@
+package resources_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/formancehq/go-libs/v3/pointer"
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/internal/resources"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStackRolloutCreate(t *testing.T) {
+	type testCase struct {
+		name              string
+		failingStack      string
+		expectedCompleted int64
+		expectedStatus    string
+	}
+
+	for _, tc := range []testCase{
+		{
+			name:              "every batch succeeds",
+			expectedCompleted: 2,
+			expectedStatus:    resources.RolloutStatusCompleted,
+		},
+		{
+			name:              "second batch fails",
+			failingStack:      "stack-b",
+			expectedCompleted: 1,
+			expectedStatus:    resources.RolloutStatusHalted,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStackRollout()().(resource.ResourceWithConfigure)
+				organizationId := uuid.NewString()
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+				configureRes := resource.ConfigureResponse{}
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				for _, stackID := range []string{"stack-a", "stack-b"} {
+					var upgradeErr error
+					if stackID == tc.failingStack {
+						upgradeErr = errors.New("upgrade refused")
+					}
+					expectStackUpgrade(apiMock, organizationId, stackID, "v2.1.0", upgradeErr)
+				}
+
+				res := resource.CreateResponse{
+					State: tfsdk.State{
+						Schema: resources.SchemaStackRollout,
+					},
+				}
+				r.Create(ctx, resource.CreateRequest{
+					Plan: tfsdk.Plan{
+						Raw:    stackRolloutValue("v2.1.0", [][]string{{"stack-a"}, {"stack-b"}}, nil, nil),
+						Schema: resources.SchemaStackRollout,
+					},
+				}, &res)
+
+				if tc.failingStack != "" {
+					require.Len(t, res.Diagnostics, 1)
+					require.Equal(t, "Rollout halted", res.Diagnostics[0].Summary())
+					require.Contains(t, res.Diagnostics[0].Detail(), "Batch 2/2")
+				} else {
+					require.Empty(t, res.Diagnostics)
+				}
+
+				model := &resources.StackRolloutModel{}
+				res.State.Get(ctx, model)
+				require.NotEmpty(t, model.ID.ValueString())
+				require.Equal(t, tc.expectedCompleted, model.CompletedBatches.ValueInt64())
+				require.Equal(t, tc.expectedStatus, model.Status.ValueString())
+			})
+		})
+	}
+}
+
+func TestStackRolloutResume(t *testing.T) {
+	test(t, func(ctx context.Context) {
+		r := resources.NewStackRollout()().(resource.ResourceWithConfigure)
+		organizationId := uuid.NewString()
+		ctrl := gomock.NewController(t)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		store := internal.NewStore(apiMock, tp)
+
+		tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{
+			ProviderData: store,
+		}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+
+		// Only the second batch is expected to be upgraded
+		expectStackUpgrade(apiMock, organizationId, "stack-b", "v2.1.0", nil)
+
+		batches := [][]string{{"stack-a"}, {"stack-b"}}
+		res := resource.UpdateResponse{
+			State: tfsdk.State{
+				Schema: resources.SchemaStackRollout,
+			},
+		}
+		r.Update(ctx, resource.UpdateRequest{
+			State: tfsdk.State{
+				Raw:    stackRolloutValue("v2.1.0", batches, pointer.For(int64(1)), pointer.For(resources.RolloutStatusHalted)),
+				Schema: resources.SchemaStackRollout,
+			},
+			Plan: tfsdk.Plan{
+				Raw:    stackRolloutValue("v2.1.0", batches, nil, nil),
+				Schema: resources.SchemaStackRollout,
+			},
+		}, &res)
+		require.Empty(t, res.Diagnostics)
+
+		model := &resources.StackRolloutModel{}
+		res.State.Get(ctx, model)
+		require.Equal(t, "rollout", model.ID.ValueString())
+		require.Equal(t, int64(2), model.CompletedBatches.ValueInt64())
+		require.Equal(t, resources.RolloutStatusCompleted, model.Status.ValueString())
+	})
+}
+
+func TestStackRolloutRefusedStacks(t *testing.T) {
+	type testCase struct {
+		name           string
+		stack          shared.Stack
+		expectedDetail string
+	}
+
+	for _, tc := range []testCase{
+		{
+			name: "stack managed by another workspace",
+			stack: shared.Stack{
+				Version:  pointer.For("v2.0.0"),
+				Metadata: map[string]string{resources.MetadataProtectedKey: "other"},
+			},
+			expectedDetail: "managed by the Terraform workspace 'other'",
+		},
+		{
+			name: "stack running a newer version",
+			stack: shared.Stack{
+				Version:  pointer.For("v2.2.0"),
+				Metadata: map[string]string{resources.MetadataProtectedKey: "true"},
+			},
+			expectedDetail: "runs version 'v2.2.0' and cannot be downgraded",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStackRollout()().(resource.ResourceWithConfigure)
+				organizationId := uuid.NewString()
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+				configureRes := resource.ConfigureResponse{}
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				// The first stack of the batch is not upgraded either, since the second one is refused
+				apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, "stack-a").Return(&operations.GetStackResponse{
+					StatusCode: http.StatusOK,
+					CreateStackResponse: &shared.CreateStackResponse{
+						Data: &shared.Stack{
+							ID:       "stack-a",
+							Version:  pointer.For("v2.0.0"),
+							Status:   shared.StackStatusReady,
+							Metadata: map[string]string{resources.MetadataProtectedKey: "true"},
+						},
+					},
+				}, nil)
+				stack := tc.stack
+				stack.ID = "stack-b"
+				stack.Status = shared.StackStatusReady
+				apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, "stack-b").Return(&operations.GetStackResponse{
+					StatusCode: http.StatusOK,
+					CreateStackResponse: &shared.CreateStackResponse{
+						Data: &stack,
+					},
+				}, nil)
+
+				res := resource.CreateResponse{
+					State: tfsdk.State{
+						Schema: resources.SchemaStackRollout,
+					},
+				}
+				r.Create(ctx, resource.CreateRequest{
+					Plan: tfsdk.Plan{
+						Raw:    stackRolloutValue("v2.1.0", [][]string{{"stack-a", "stack-b"}}, nil, nil),
+						Schema: resources.SchemaStackRollout,
+					},
+				}, &res)
+
+				require.Len(t, res.Diagnostics, 1)
+				require.Equal(t, "Rollout halted", res.Diagnostics[0].Summary())
+				require.Contains(t, res.Diagnostics[0].Detail(), tc.expectedDetail)
+
+				model := &resources.StackRolloutModel{}
+				res.State.Get(ctx, model)
+				require.Equal(t, int64(0), model.CompletedBatches.ValueInt64())
+				require.Equal(t, resources.RolloutStatusHalted, model.Status.ValueString())
+			})
+		})
+	}
+}
+
+func TestStackRolloutPinnedVersions(t *testing.T) {
+	type testCase struct {
+		name             string
+		pinned           string
+		expectedWarnings int
+	}
+
+	for _, tc := range []testCase{
+		{
+			name:             "stack pinned to another version",
+			pinned:           "v2.0.0",
+			expectedWarnings: 1,
+		},
+		{
+			name:   "stack pinned to the version of the rollout",
+			pinned: "2.1.0",
+		},
+		{
+			name: "stack not pinned",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStackRollout()().(resource.ResourceWithModifyPlan)
+				ctrl := gomock.NewController(t)
+				store := internal.NewStore(pkg.NewMockCloudSDK(ctrl), pkg.NewMockTokenProviderImpl(ctrl))
+				if tc.pinned != "" {
+					store.PinStackVersion("stack-b", tc.pinned)
+				}
+
+				configureRes := resource.ConfigureResponse{}
+				r.(resource.ResourceWithConfigure).Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				plan := tfsdk.Plan{
+					Raw:    stackRolloutValue("v2.1.0", [][]string{{"stack-a"}, {"stack-b"}}, nil, nil),
+					Schema: resources.SchemaStackRollout,
+				}
+				res := resource.ModifyPlanResponse{
+					Plan: plan,
+				}
+				r.ModifyPlan(ctx, resource.ModifyPlanRequest{
+					State: tfsdk.State{
+						Raw:    tftypes.NewValue(plan.Raw.Type(), nil),
+						Schema: resources.SchemaStackRollout,
+					},
+					Plan: plan,
+				}, &res)
+
+				require.Len(t, res.Diagnostics, tc.expectedWarnings)
+				require.False(t, res.Diagnostics.HasError())
+				if tc.expectedWarnings > 0 {
+					require.Equal(t, "Stack Version Pinned", res.Diagnostics[0].Summary())
+					require.Contains(t, res.Diagnostics[0].Detail(), "'stack-b'")
+				}
+			})
+		})
+	}
+}
@@ -2,7 +2,9 @@ package resources_test
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"regexp"
 	"testing"
 	"time"
 
@@ -11,11 +13,14 @@ import (
 	"github.com/formancehq/terraform-provider-cloud/internal/resources"
 	"github.com/formancehq/terraform-provider-cloud/pkg"
 	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/sdkerrors"
 	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
 	"github.com/google/uuid"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
@@ -124,9 +129,15 @@ func TestStackCreate(t *testing.T) {
 
 				require.Empty(t, configureRes.Diagnostics, "Expected no diagnostics on configure")
 
+				idempotencyKey := uuid.NewString()
 				md := map[string]string{
-					"github.com/formancehq/terraform-provider-cloud/protected": "true",
+					resources.MetadataProtectedKey:          "true",
+					resources.MetadataDeletionProtectionKey: "true",
+					resources.MetadataIdempotencyKey:        idempotencyKey,
 				}
+				apiMock.EXPECT().ListStacks(gomock.Any(), organizationId).Return(&operations.ListStacksResponse{
+					ListStacksResponse: &shared.ListStacksResponse{Data: []shared.Stack{}},
+				}, nil)
 				stackID := uuid.NewString()
 				now := time.Now()
 				apiMock.EXPECT().CreateStack(gomock.Any(), organizationId, &shared.CreateStackRequest{
@@ -165,12 +176,21 @@ func TestStackCreate(t *testing.T) {
 						Raw: tftypes.NewValue(tftypes.Object{
 							AttributeTypes: getSchemaTypes(resources.SchemaStack),
 						}, map[string]tftypes.Value{
-							"id":            tftypes.NewValue(tftypes.String, nil),
-							"name":          tftypes.NewValue(tftypes.String, tc.name),
-							"region_id":     tftypes.NewValue(tftypes.String, tc.regionID),
-							"version":       tftypes.NewValue(tftypes.String, tc.version),
-							"force_destroy": tftypes.NewValue(tftypes.Bool, nil),
-							"uri":           tftypes.NewValue(tftypes.String, "https://example.com"),
+							"id":                  tftypes.NewValue(tftypes.String, nil),
+							"name":                tftypes.NewValue(tftypes.String, tc.name),
+							"region_id":           tftypes.NewValue(tftypes.String, tc.regionID),
+							"version":             tftypes.NewValue(tftypes.String, tc.version),
+							"version_constraint":  tftypes.NewValue(tftypes.String, nil),
+							"auto_upgrade":        tftypes.NewValue(tftypes.Bool, nil),
+							"upgrade_mode":        tftypes.NewValue(tftypes.String, nil),
+							"modules":             tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, nil),
+							"module_status":       tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+							"force_destroy":       tftypes.NewValue(tftypes.Bool, nil),
+							"adopt":               tftypes.NewValue(tftypes.Bool, nil),
+							"deletion_protection": tftypes.NewValue(tftypes.Bool, true),
+							"idempotency_key":     tftypes.NewValue(tftypes.String, idempotencyKey),
+							"metadata_all":        tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+							"uri":                 tftypes.NewValue(tftypes.String, "https://example.com"),
 							"metadata": tftypes.NewValue(tftypes.Map{
 								ElementType: tftypes.String,
 							}, nil),
@@ -190,12 +210,242 @@ func TestStackCreate(t *testing.T) {
 
 				model := &resources.StackModel{}
 				res.State.Get(ctx, model)
+				require.True(t, model.DeletionProtection.ValueBool())
+				require.True(t, model.Metadata.IsNull() || len(model.Metadata.Elements()) == 0)
+				require.Equal(t, idempotencyKey, model.IdempotencyKey.ValueString())
 
 			})
 		})
 	}
 }
 
+func TestStackCreateAdoptsOrphans(t *testing.T) {
+	plan := func(name string, idempotencyKey any) tfsdk.Plan {
+		return tfsdk.Plan{
+			Raw: tftypes.NewValue(tftypes.Object{
+				AttributeTypes: getSchemaTypes(resources.SchemaStack),
+			}, map[string]tftypes.Value{
+				"id":                  tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+				"name":                tftypes.NewValue(tftypes.String, name),
+				"region_id":           tftypes.NewValue(tftypes.String, "region"),
+				"version":             tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+				"version_constraint":  tftypes.NewValue(tftypes.String, nil),
+				"auto_upgrade":        tftypes.NewValue(tftypes.Bool, nil),
+				"upgrade_mode":        tftypes.NewValue(tftypes.String, nil),
+				"modules":             tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, nil),
+				"module_status":       tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+				"force_destroy":       tftypes.NewValue(tftypes.Bool, nil),
+				"adopt":               tftypes.NewValue(tftypes.Bool, nil),
+				"deletion_protection": tftypes.NewValue(tftypes.Bool, true),
+				"idempotency_key":     tftypes.NewValue(tftypes.String, idempotencyKey),
+				"metadata_all":        tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+				"uri":                 tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+				"metadata":            tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, tftypes.UnknownValue),
+			}),
+			Schema: resources.SchemaStack,
+		}
+	}
+	stack := func(id, idempotencyKey string, createdAt time.Time) shared.Stack {
+		return shared.Stack{
+			ID:       id,
+			Name:     "prod",
+			RegionID: "region",
+			URI:      "https://" + id + ".example.com",
+			Version:  pointer.For("v2.2.0"),
+			State:    shared.StackStateActive,
+			Metadata: map[string]string{
+				resources.MetadataProtectedKey:          "true",
+				resources.MetadataDeletionProtectionKey: "true",
+				resources.MetadataIdempotencyKey:        idempotencyKey,
+			},
+			CreatedAt: pointer.For(createdAt),
+		}
+	}
+	setup := func(t *testing.T, ctx context.Context) (resource.Resource, *pkg.MockCloudSDK, string) {
+		ctrl := gomock.NewController(t)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		organizationId := uuid.NewString()
+		tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+
+		r := resources.NewStack()().(resource.ResourceWithConfigure)
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{ProviderData: internal.NewStore(apiMock, tp)}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+
+		return r, apiMock, organizationId
+	}
+
+	t.Run("orphan of a previous apply", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, apiMock, organizationId := setup(t, ctx)
+			now := time.Now()
+
+			apiMock.EXPECT().ListStacks(gomock.Any(), organizationId).Return(&operations.ListStacksResponse{
+				ListStacksResponse: &shared.ListStacksResponse{Data: []shared.Stack{
+					stack("duplicate", "key", now),
+					stack("other", "other-key", now.Add(-2*time.Hour)),
+					stack("orphan", "key", now.Add(-time.Hour)),
+				}},
+			}, nil)
+
+			res := resource.CreateResponse{State: tfsdk.State{Schema: resources.SchemaStack}}
+			r.Create(ctx, resource.CreateRequest{Plan: plan("prod", "key")}, &res)
+			require.False(t, res.Diagnostics.HasError(), "%v", res.Diagnostics)
+			require.Equal(t, 2, res.Diagnostics.WarningsCount())
+			require.Contains(t, res.Diagnostics.Warnings()[1].Detail(), "duplicate")
+
+			model := &resources.StackModel{}
+			require.Empty(t, res.State.Get(ctx, model))
+			require.Equal(t, "orphan", model.GetID())
+			require.Equal(t, "v2.2.0", model.Version.ValueString())
+			require.Equal(t, "key", model.IdempotencyKey.ValueString())
+		})
+	})
+
+	t.Run("lost creation response", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, apiMock, organizationId := setup(t, ctx)
+
+			var idempotencyKey string
+			gomock.InOrder(
+				apiMock.EXPECT().ListStacks(gomock.Any(), organizationId).Return(&operations.ListStacksResponse{
+					ListStacksResponse: &shared.ListStacksResponse{Data: []shared.Stack{}},
+				}, nil),
+				apiMock.EXPECT().CreateStack(gomock.Any(), organizationId, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, req *shared.CreateStackRequest) (*operations.CreateStackResponse, error) {
+					idempotencyKey = req.Metadata[resources.MetadataIdempotencyKey]
+					return nil, errors.New("unexpected EOF")
+				}),
+				apiMock.EXPECT().ListStacks(gomock.Any(), organizationId).DoAndReturn(func(context.Context, string) (*operations.ListStacksResponse, error) {
+					return &operations.ListStacksResponse{
+						ListStacksResponse: &shared.ListStacksResponse{Data: []shared.Stack{
+							stack("created", idempotencyKey, time.Now()),
+						}},
+					}, nil
+				}),
+			)
+
+			res := resource.CreateResponse{State: tfsdk.State{Schema: resources.SchemaStack}}
+			r.Create(ctx, resource.CreateRequest{Plan: plan("prod", tftypes.UnknownValue)}, &res)
+			require.False(t, res.Diagnostics.HasError(), "%v", res.Diagnostics)
+			require.NotEmpty(t, idempotencyKey)
+
+			model := &resources.StackModel{}
+			require.Empty(t, res.State.Get(ctx, model))
+			require.Equal(t, "created", model.GetID())
+			require.Equal(t, idempotencyKey, model.IdempotencyKey.ValueString())
+		})
+	})
+
+	t.Run("stacks without name retry with the same key", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, apiMock, organizationId := setup(t, ctx)
+
+			var idempotencyKeys []string
+			apiMock.EXPECT().ListStacks(gomock.Any(), organizationId).Return(&operations.ListStacksResponse{
+				ListStacksResponse: &shared.ListStacksResponse{Data: []shared.Stack{}},
+			}, nil).Times(4)
+			apiMock.EXPECT().CreateStack(gomock.Any(), organizationId, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, req *shared.CreateStackRequest) (*operations.CreateStackResponse, error) {
+				idempotencyKeys = append(idempotencyKeys, req.Metadata[resources.MetadataIdempotencyKey])
+				return nil, errors.New("unexpected EOF")
+			}).Times(2)
+
+			unnamed := plan("", tftypes.UnknownValue)
+			require.Empty(t, unnamed.SetAttribute(ctx, path.Root("metadata"), map[string]string{"env": "test"}))
+			for range 2 {
+				res := resource.CreateResponse{State: tfsdk.State{Schema: resources.SchemaStack}}
+				r.Create(ctx, resource.CreateRequest{Plan: unnamed}, &res)
+				require.True(t, res.Diagnostics.HasError())
+			}
+			require.Len(t, idempotencyKeys, 2)
+			require.NotEmpty(t, idempotencyKeys[0])
+			require.Equal(t, idempotencyKeys[0], idempotencyKeys[1])
+		})
+	})
+
+	created := func(id string) *operations.CreateStackResponse {
+		return &operations.CreateStackResponse{
+			StatusCode:          http.StatusCreated,
+			CreateStackResponse: &shared.CreateStackResponse{Data: pointer.For(stack(id, "key", time.Now()))},
+		}
+	}
+
+	t.Run("replaced and deleting stacks are not adopted", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, apiMock, organizationId := setup(t, ctx)
+			now := time.Now()
+			deleting := stack("deleting", "key", now.Add(-2*time.Hour))
+			deleting.ExpectedStatus = shared.ExpectedStatusDeleted
+
+			// The replacement is planned with the state of the replaced stack
+			state := stackValue(map[string]tftypes.Value{
+				"id":              tftypes.NewValue(tftypes.String, "replaced"),
+				"idempotency_key": tftypes.NewValue(tftypes.String, "key"),
+			})
+			r.(resource.ResourceWithModifyPlan).ModifyPlan(ctx, resource.ModifyPlanRequest{
+				Plan:  tfsdk.Plan{Raw: tftypes.NewValue(state.Type(), nil), Schema: resources.SchemaStack},
+				State: tfsdk.State{Raw: state, Schema: resources.SchemaStack},
+			}, &resource.ModifyPlanResponse{})
+
+			apiMock.EXPECT().ListStacks(gomock.Any(), organizationId).Return(&operations.ListStacksResponse{
+				ListStacksResponse: &shared.ListStacksResponse{Data: []shared.Stack{
+					deleting,
+					stack("replaced", "key", now.Add(-time.Hour)),
+				}},
+			}, nil)
+			apiMock.EXPECT().CreateStack(gomock.Any(), organizationId, gomock.Any()).Return(created("replacement"), nil)
+
+			res := resource.CreateResponse{State: tfsdk.State{Schema: resources.SchemaStack}}
+			r.Create(ctx, resource.CreateRequest{Plan: plan("prod", "key")}, &res)
+			require.Empty(t, res.Diagnostics)
+
+			model := &resources.StackModel{}
+			require.Empty(t, res.State.Get(ctx, model))
+			require.Equal(t, "replacement", model.GetID())
+		})
+	})
+
+	t.Run("resources with the same name adopt a single stack", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, apiMock, organizationId := setup(t, ctx)
+
+			apiMock.EXPECT().ListStacks(gomock.Any(), organizationId).Return(&operations.ListStacksResponse{
+				ListStacksResponse: &shared.ListStacksResponse{Data: []shared.Stack{
+					stack("orphan", "key", time.Now()),
+				}},
+			}, nil).Times(2)
+			apiMock.EXPECT().CreateStack(gomock.Any(), organizationId, gomock.Any()).Return(created("second"), nil)
+
+			ids := []string{}
+			for range 2 {
+				res := resource.CreateResponse{State: tfsdk.State{Schema: resources.SchemaStack}}
+				r.Create(ctx, resource.CreateRequest{Plan: plan("prod", "key")}, &res)
+				require.False(t, res.Diagnostics.HasError(), "%v", res.Diagnostics)
+
+				model := &resources.StackModel{}
+				require.Empty(t, res.State.Get(ctx, model))
+				ids = append(ids, model.GetID())
+			}
+			require.Equal(t, []string{"orphan", "second"}, ids)
+		})
+	})
+
+	t.Run("failed creation", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, apiMock, organizationId := setup(t, ctx)
+
+			apiMock.EXPECT().ListStacks(gomock.Any(), organizationId).Return(&operations.ListStacksResponse{
+				ListStacksResponse: &shared.ListStacksResponse{Data: []shared.Stack{}},
+			}, nil).Times(2)
+			apiMock.EXPECT().CreateStack(gomock.Any(), organizationId, gomock.Any()).Return(nil, errors.New("unexpected EOF"))
+
+			res := resource.CreateResponse{State: tfsdk.State{Schema: resources.SchemaStack}}
+			r.Create(ctx, resource.CreateRequest{Plan: plan("prod", tftypes.UnknownValue)}, &res)
+			require.True(t, res.Diagnostics.HasError())
+		})
+	})
+}
+
 func TestStackValidateConfig(t *testing.T) {
 	type testCase struct {
 		organizationID *string
@@ -212,6 +462,12 @@ func TestStackValidateConfig(t *testing.T) {
 		t.Run(t.Name(), func(t *testing.T) {
 			test(t, func(ctx context.Context) {
 				r := resources.NewStack()().(resource.ResourceWithValidateConfig)
+				ctrl := gomock.NewController(t)
+				configureRes := resource.ConfigureResponse{}
+				r.(resource.ResourceWithConfigure).Configure(ctx, resource.ConfigureRequest{
+					ProviderData: internal.NewStore(pkg.NewMockCloudSDK(ctrl), pkg.NewMockTokenProviderImpl(ctrl)),
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
 
 				res := resource.ValidateConfigResponse{
 					Diagnostics: []diag.Diagnostic{},
@@ -222,12 +478,21 @@ func TestStackValidateConfig(t *testing.T) {
 						Raw: tftypes.NewValue(tftypes.Object{
 							AttributeTypes: getSchemaTypes(resources.SchemaStack),
 						}, map[string]tftypes.Value{
-							"name":          tftypes.NewValue(tftypes.String, nil),
-							"region_id":     tftypes.NewValue(tftypes.String, tc.regionID),
-							"version":       tftypes.NewValue(tftypes.String, nil),
-							"id":            tftypes.NewValue(tftypes.String, nil),
-							"force_destroy": tftypes.NewValue(tftypes.Bool, nil),
-							"uri":           tftypes.NewValue(tftypes.String, nil),
+							"name":                tftypes.NewValue(tftypes.String, nil),
+							"region_id":           tftypes.NewValue(tftypes.String, tc.regionID),
+							"version":             tftypes.NewValue(tftypes.String, nil),
+							"version_constraint":  tftypes.NewValue(tftypes.String, nil),
+							"auto_upgrade":        tftypes.NewValue(tftypes.Bool, nil),
+							"upgrade_mode":        tftypes.NewValue(tftypes.String, nil),
+							"modules":             tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, nil),
+							"module_status":       tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+							"id":                  tftypes.NewValue(tftypes.String, nil),
+							"force_destroy":       tftypes.NewValue(tftypes.Bool, nil),
+							"adopt":               tftypes.NewValue(tftypes.Bool, nil),
+							"deletion_protection": tftypes.NewValue(tftypes.Bool, nil),
+							"idempotency_key":     tftypes.NewValue(tftypes.String, nil),
+							"metadata_all":        tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+							"uri":                 tftypes.NewValue(tftypes.String, nil),
 							"metadata": tftypes.NewValue(tftypes.Map{
 								ElementType: tftypes.String,
 							}, nil),
@@ -247,3 +512,1227 @@ func TestStackValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestStackDelete(t *testing.T) {
+	type testCase struct {
+		deletionProtection bool
+	}
+
+	for _, tc := range []testCase{
+		{
+			deletionProtection: true,
+		},
+		{
+			deletionProtection: false,
+		},
+	} {
+		t.Run(t.Name(), func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStack()().(resource.ResourceWithConfigure)
+				organizationId := uuid.NewString()
+				stackID := uuid.NewString()
+				configureRes := resource.ConfigureResponse{
+					Diagnostics: []diag.Diagnostic{},
+				}
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics, "Expected no diagnostics on configure")
+
+				if !tc.deletionProtection {
+					expectManagedStack(apiMock, organizationId, stackID)
+					apiMock.EXPECT().DeleteStack(gomock.Any(), organizationId, stackID, false).Return(&operations.DeleteStackResponse{
+						StatusCode:  http.StatusNoContent,
+						RawResponse: &http.Response{StatusCode: http.StatusNoContent},
+					}, nil)
+				}
+
+				res := resource.DeleteResponse{
+					Diagnostics: []diag.Diagnostic{},
+				}
+				r.Delete(ctx, resource.DeleteRequest{
+					State: tfsdk.State{
+						Raw: tftypes.NewValue(tftypes.Object{
+							AttributeTypes: getSchemaTypes(resources.SchemaStack),
+						}, map[string]tftypes.Value{
+							"id":                  tftypes.NewValue(tftypes.String, stackID),
+							"name":                tftypes.NewValue(tftypes.String, "test"),
+							"region_id":           tftypes.NewValue(tftypes.String, "staging"),
+							"version":             tftypes.NewValue(tftypes.String, nil),
+							"version_constraint":  tftypes.NewValue(tftypes.String, nil),
+							"auto_upgrade":        tftypes.NewValue(tftypes.Bool, nil),
+							"upgrade_mode":        tftypes.NewValue(tftypes.String, nil),
+							"modules":             tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, nil),
+							"module_status":       tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+							"force_destroy":       tftypes.NewValue(tftypes.Bool, nil),
+							"adopt":               tftypes.NewValue(tftypes.Bool, nil),
+							"deletion_protection": tftypes.NewValue(tftypes.Bool, tc.deletionProtection),
+							"idempotency_key":     tftypes.NewValue(tftypes.String, nil),
+							"metadata_all":        tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+							"uri":                 tftypes.NewValue(tftypes.String, "https://example.com"),
+							"metadata": tftypes.NewValue(tftypes.Map{
+								ElementType: tftypes.String,
+							}, nil),
+						}),
+						Schema: resources.SchemaStack,
+					},
+				}, &res)
+
+				if tc.deletionProtection {
+					require.Len(t, res.Diagnostics, 1, "Expected one diagnostic")
+					require.Equal(t, "Stack is protected against deletion", res.Diagnostics[0].Summary())
+				} else {
+					require.Empty(t, res.Diagnostics, "Expected no diagnostics")
+				}
+			})
+		})
+	}
+}
+
+func TestStackModifyPlan(t *testing.T) {
+	type testCase struct {
+		name            string
+		constraint      string
+		version         string
+		autoUpgrade     bool
+		currentVersion  string
+		expectedVersion string
+		expectedError   string
+		expectVersions  bool
+	}
+
+	for _, tc := range []testCase{
+		{
+			name:            "create resolves the newest matching version",
+			constraint:      "~> v2.2",
+			expectedVersion: "v2.2.4",
+			expectVersions:  true,
+		},
+		{
+			name:            "update keeps the current version without auto_upgrade",
+			constraint:      "~> v2.2",
+			currentVersion:  "v2.2.0",
+			expectedVersion: "v2.2.0",
+		},
+		{
+			name:            "update upgrades with auto_upgrade",
+			constraint:      "~> v2.2",
+			autoUpgrade:     true,
+			currentVersion:  "v2.2.0",
+			expectedVersion: "v2.2.4",
+			expectVersions:  true,
+		},
+		{
+			name:           "deprecated versions are skipped",
+			constraint:     "~> v2.3.0",
+			autoUpgrade:    true,
+			currentVersion: "v2.2.0",
+			expectedError:  "No Matching Version",
+			expectVersions: true,
+		},
+		{
+			name:            "constraint change upgrades out of range version",
+			constraint:      "~> v3.0",
+			currentVersion:  "v2.2.0",
+			expectedVersion: "v3.0.1",
+			expectVersions:  true,
+		},
+		{
+			name:           "downgrades are refused",
+			constraint:     "~> v2.1.0",
+			currentVersion: "v2.2.0",
+			expectedError:  "Stack Downgrade Not Supported",
+			expectVersions: true,
+		},
+		{
+			name:            "explicit upgrades are planned",
+			version:         "v3.0.1",
+			currentVersion:  "v2.2.0",
+			expectedVersion: "v3.0.1",
+		},
+		{
+			name:           "explicit downgrades are refused",
+			version:        "v2.1.0",
+			currentVersion: "v2.2.0",
+			expectedError:  "Stack Downgrade Not Supported",
+		},
+		{
+			name:            "explicit versions resolved by the API are planned",
+			version:         "default",
+			currentVersion:  "v2.2.0",
+			expectedVersion: "default",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStack()().(resource.ResourceWithModifyPlan)
+				organizationId := uuid.NewString()
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+				store.SetStackMetadata("stack-id", map[string]string{resources.MetadataProtectedKey: "true"})
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+				configureRes := resource.ConfigureResponse{}
+				r.(resource.ResourceWithConfigure).Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				if tc.expectVersions {
+					apiMock.EXPECT().GetRegionVersions(gomock.Any(), organizationId, "staging").Return(&operations.GetRegionVersionsResponse{
+						StatusCode: http.StatusOK,
+						GetRegionVersionsResponse: &shared.GetRegionVersionsResponse{
+							Data: []shared.Version{
+								{Name: "v2.1.0"},
+								{Name: "v2.2.0"},
+								{Name: "v2.2.4"},
+								{Name: "v2.3.0", Deprecated: pointer.For(true)},
+								{Name: "v3.0.1"},
+							},
+						},
+					}, nil)
+				}
+
+				constraint := tftypes.NewValue(tftypes.String, nil)
+				if tc.constraint != "" {
+					constraint = tftypes.NewValue(tftypes.String, tc.constraint)
+				}
+				values := map[string]tftypes.Value{
+					"version_constraint": constraint,
+					"auto_upgrade":       tftypes.NewValue(tftypes.Bool, tc.autoUpgrade),
+					"upgrade_mode":       tftypes.NewValue(tftypes.String, nil),
+					"modules":            tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, nil),
+					"module_status":      tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+					"version":            tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+				}
+				state := tftypes.NewValue(tftypes.Object{
+					AttributeTypes: getSchemaTypes(resources.SchemaStack),
+				}, nil)
+				if tc.currentVersion != "" {
+					values["id"] = tftypes.NewValue(tftypes.String, "stack-id")
+					values["version"] = tftypes.NewValue(tftypes.String, tc.currentVersion)
+					state = stackValue(map[string]tftypes.Value{
+						"id":                 tftypes.NewValue(tftypes.String, "stack-id"),
+						"version":            tftypes.NewValue(tftypes.String, tc.currentVersion),
+						"version_constraint": constraint,
+					})
+				}
+				if tc.version != "" {
+					values["version"] = tftypes.NewValue(tftypes.String, tc.version)
+				}
+
+				plan := tfsdk.Plan{
+					Raw:    stackValue(values),
+					Schema: resources.SchemaStack,
+				}
+				res := resource.ModifyPlanResponse{
+					Plan: plan,
+				}
+				r.ModifyPlan(ctx, resource.ModifyPlanRequest{
+					Config: tfsdk.Config{
+						Raw:    stackValue(values),
+						Schema: resources.SchemaStack,
+					},
+					Plan: plan,
+					State: tfsdk.State{
+						Raw:    state,
+						Schema: resources.SchemaStack,
+					},
+				}, &res)
+
+				// Explicit versions of existing stacks are reported to the rollouts
+				pinned, ok := store.GetPinnedStackVersion("stack-id")
+				require.Equal(t, tc.constraint == "" && tc.currentVersion != "", ok)
+				if ok {
+					require.Equal(t, tc.version, pinned)
+				}
+
+				if tc.expectedError != "" {
+					require.Len(t, res.Diagnostics, 1)
+					require.Equal(t, tc.expectedError, res.Diagnostics[0].Summary())
+					return
+				}
+				require.Empty(t, res.Diagnostics)
+
+				model := &resources.StackModel{}
+				res.Plan.Get(ctx, model)
+				require.Equal(t, tc.expectedVersion, model.Version.ValueString())
+			})
+		})
+	}
+}
+
+func TestStackUpdateStepwise(t *testing.T) {
+	type testCase struct {
+		name            string
+		failingHop      string
+		expectedVersion string
+	}
+
+	for _, tc := range []testCase{
+		{
+			name:            "every hop succeeds",
+			expectedVersion: "v2.2.0",
+		},
+		{
+			name:            "last hop fails",
+			failingHop:      "v2.2.0",
+			expectedVersion: "v2.1.4",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStack()().(resource.ResourceWithConfigure)
+				organizationId := uuid.NewString()
+				stackID := uuid.NewString()
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+				configureRes := resource.ConfigureResponse{}
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				expectManagedStack(apiMock, organizationId, stackID)
+				apiMock.EXPECT().GetRegionVersions(gomock.Any(), organizationId, "staging").Return(&operations.GetRegionVersionsResponse{
+					StatusCode: http.StatusOK,
+					GetRegionVersionsResponse: &shared.GetRegionVersionsResponse{
+						Data: []shared.Version{
+							{Name: "v2.0.1"},
+							{Name: "v2.1.0"},
+							{Name: "v2.1.4"},
+							{Name: "v2.2.0"},
+						},
+					},
+				}, nil)
+				for _, hop := range []string{"v2.1.4", "v2.2.0"} {
+					if hop == tc.failingHop {
+						apiMock.EXPECT().UpgradeStack(gomock.Any(), organizationId, stackID, hop).Return(nil, errors.New("upgrade refused"))
+						break
+					}
+					apiMock.EXPECT().UpgradeStack(gomock.Any(), organizationId, stackID, hop).Return(&operations.UpgradeStackResponse{
+						StatusCode: http.StatusAccepted,
+					}, nil)
+					apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, stackID).Return(&operations.GetStackResponse{
+						StatusCode: http.StatusOK,
+						CreateStackResponse: &shared.CreateStackResponse{
+							Data: &shared.Stack{
+								ID:      stackID,
+								Version: pointer.For(hop),
+								Status:  shared.StackStatusReady,
+							},
+						},
+					}, nil)
+				}
+
+				res := resource.UpdateResponse{
+					State: tfsdk.State{
+						Schema: resources.SchemaStack,
+					},
+				}
+				r.Update(ctx, resource.UpdateRequest{
+					State: tfsdk.State{
+						Raw: stackValue(map[string]tftypes.Value{
+							"id":           tftypes.NewValue(tftypes.String, stackID),
+							"version":      tftypes.NewValue(tftypes.String, "v2.0.1"),
+							"upgrade_mode": tftypes.NewValue(tftypes.String, resources.UpgradeModeStepwise),
+						}),
+						Schema: resources.SchemaStack,
+					},
+					Plan: tfsdk.Plan{
+						Raw: stackValue(map[string]tftypes.Value{
+							"id":           tftypes.NewValue(tftypes.String, stackID),
+							"version":      tftypes.NewValue(tftypes.String, "v2.2.0"),
+							"upgrade_mode": tftypes.NewValue(tftypes.String, resources.UpgradeModeStepwise),
+						}),
+						Schema: resources.SchemaStack,
+					},
+				}, &res)
+
+				if tc.failingHop != "" {
+					require.Len(t, res.Diagnostics, 1)
+					require.Equal(t, "Stack upgrade failed", res.Diagnostics[0].Summary())
+					require.Contains(t, res.Diagnostics[0].Detail(), "hop 2/2")
+				} else {
+					require.Empty(t, res.Diagnostics)
+				}
+
+				model := &resources.StackModel{}
+				res.State.Get(ctx, model)
+				require.Equal(t, tc.expectedVersion, model.Version.ValueString())
+			})
+		})
+	}
+}
+
+func TestStackUpdateVersion(t *testing.T) {
+	type testCase struct {
+		name           string
+		currentVersion string
+		version        string
+		expectUpgrade  bool
+		expectedError  string
+	}
+
+	for _, tc := range []testCase{
+		{
+			name:           "upgrade",
+			currentVersion: "v2.1.0",
+			version:        "v2.2.0",
+			expectUpgrade:  true,
+		},
+		{
+			name:           "equal version",
+			currentVersion: "v2.2.0",
+			version:        "2.2.0",
+		},
+		{
+			name:           "explicit downgrade",
+			currentVersion: "v2.2.0",
+			version:        "v2.1.0",
+			expectedError:  "Stack Downgrade Not Supported",
+		},
+		{
+			name:           "version resolved by the API",
+			currentVersion: "v2.2.0",
+			version:        "default",
+			expectUpgrade:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStack()().(resource.ResourceWithConfigure)
+				organizationId := uuid.NewString()
+				stackID := uuid.NewString()
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+				configureRes := resource.ConfigureResponse{}
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				expectManagedStack(apiMock, organizationId, stackID)
+				if tc.expectUpgrade {
+					apiMock.EXPECT().UpgradeStack(gomock.Any(), organizationId, stackID, tc.version).Return(&operations.UpgradeStackResponse{
+						StatusCode: http.StatusAccepted,
+					}, nil)
+				}
+
+				res := resource.UpdateResponse{
+					State: tfsdk.State{
+						Schema: resources.SchemaStack,
+					},
+				}
+				r.(resource.Resource).Update(ctx, resource.UpdateRequest{
+					State: tfsdk.State{
+						Raw: stackValue(map[string]tftypes.Value{
+							"id":      tftypes.NewValue(tftypes.String, stackID),
+							"version": tftypes.NewValue(tftypes.String, tc.currentVersion),
+						}),
+						Schema: resources.SchemaStack,
+					},
+					Plan: tfsdk.Plan{
+						Raw: stackValue(map[string]tftypes.Value{
+							"id":      tftypes.NewValue(tftypes.String, stackID),
+							"version": tftypes.NewValue(tftypes.String, tc.version),
+						}),
+						Schema: resources.SchemaStack,
+					},
+				}, &res)
+
+				if tc.expectedError != "" {
+					require.Len(t, res.Diagnostics, 1)
+					require.Equal(t, tc.expectedError, res.Diagnostics[0].Summary())
+					return
+				}
+				require.Empty(t, res.Diagnostics)
+
+				model := &resources.StackModel{}
+				res.State.Get(ctx, model)
+				require.Equal(t, tc.version, model.Version.ValueString())
+			})
+		})
+	}
+}
+
+func TestStackUpdateModules(t *testing.T) {
+	test(t, func(ctx context.Context) {
+		r := resources.NewStack()().(resource.ResourceWithConfigure)
+		organizationId := uuid.NewString()
+		stackID := uuid.NewString()
+		ctrl := gomock.NewController(t)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		store := internal.NewStore(apiMock, tp)
+
+		tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{
+			ProviderData: store,
+		}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+
+		expectManagedStack(apiMock, organizationId, stackID)
+		apiMock.EXPECT().ListModules(gomock.Any(), organizationId, stackID).Return(&operations.ListModulesResponse{
+			StatusCode: http.StatusOK,
+			ListModulesResponse: &shared.ListModulesResponse{
+				Data: []shared.Module{
+					{Name: "ledger", State: shared.ModuleStateEnabled, Status: shared.ModuleStatusReady},
+					{Name: "webhooks", State: shared.ModuleStateEnabled, Status: shared.ModuleStatusReady},
+				},
+			},
+		}, nil)
+		apiMock.EXPECT().DisableModule(gomock.Any(), organizationId, stackID, "webhooks").Return(&operations.DisableModuleResponse{
+			StatusCode: http.StatusNoContent,
+		}, nil)
+		apiMock.EXPECT().EnableModule(gomock.Any(), organizationId, stackID, "payments").Return(&operations.EnableModuleResponse{
+			StatusCode: http.StatusNoContent,
+		}, nil)
+		apiMock.EXPECT().ListModules(gomock.Any(), organizationId, stackID).Return(&operations.ListModulesResponse{
+			StatusCode: http.StatusOK,
+			ListModulesResponse: &shared.ListModulesResponse{
+				Data: []shared.Module{
+					{Name: "ledger", State: shared.ModuleStateEnabled, Status: shared.ModuleStatusReady},
+					{Name: "payments", State: shared.ModuleStateEnabled, Status: shared.ModuleStatusReady},
+					{Name: "webhooks", State: shared.ModuleStateDisabled, Status: shared.ModuleStatusDeleted},
+				},
+			},
+		}, nil)
+
+		modules := func(names ...string) tftypes.Value {
+			values := make([]tftypes.Value, 0, len(names))
+			for _, name := range names {
+				values = append(values, tftypes.NewValue(tftypes.String, name))
+			}
+			return tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, values)
+		}
+
+		res := resource.UpdateResponse{
+			State: tfsdk.State{
+				Schema: resources.SchemaStack,
+			},
+		}
+		r.Update(ctx, resource.UpdateRequest{
+			State: tfsdk.State{
+				Raw: stackValue(map[string]tftypes.Value{
+					"id":      tftypes.NewValue(tftypes.String, stackID),
+					"modules": modules("ledger", "webhooks"),
+				}),
+				Schema: resources.SchemaStack,
+			},
+			Plan: tfsdk.Plan{
+				Raw: stackValue(map[string]tftypes.Value{
+					"id":            tftypes.NewValue(tftypes.String, stackID),
+					"modules":       modules("ledger", "payments"),
+					"module_status": tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, tftypes.UnknownValue),
+				}),
+				Schema: resources.SchemaStack,
+			},
+		}, &res)
+		require.Empty(t, res.Diagnostics)
+
+		model := &resources.StackModel{}
+		res.State.Get(ctx, model)
+
+		var enabled []string
+		model.Modules.ElementsAs(ctx, &enabled, false)
+		require.ElementsMatch(t, []string{"ledger", "payments"}, enabled)
+
+		status := map[string]string{}
+		model.ModuleStatus.ElementsAs(ctx, &status, false)
+		require.Equal(t, map[string]string{
+			"ledger":   string(shared.ModuleStatusReady),
+			"payments": string(shared.ModuleStatusReady),
+		}, status)
+	})
+}
+
+func TestStackUpdateModulesPartialFailure(t *testing.T) {
+	test(t, func(ctx context.Context) {
+		r := resources.NewStack()().(resource.ResourceWithConfigure)
+		organizationId := uuid.NewString()
+		stackID := uuid.NewString()
+		ctrl := gomock.NewController(t)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		store := internal.NewStore(apiMock, tp)
+
+		tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{
+			ProviderData: store,
+		}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+
+		expectManagedStack(apiMock, organizationId, stackID)
+		gomock.InOrder(
+			apiMock.EXPECT().ListModules(gomock.Any(), organizationId, stackID).Return(&operations.ListModulesResponse{
+				StatusCode: http.StatusOK,
+				ListModulesResponse: &shared.ListModulesResponse{
+					Data: []shared.Module{
+						{Name: "ledger", State: shared.ModuleStateEnabled, Status: shared.ModuleStatusReady},
+					},
+				},
+			}, nil),
+			apiMock.EXPECT().EnableModule(gomock.Any(), organizationId, stackID, "payments").Return(&operations.EnableModuleResponse{
+				StatusCode: http.StatusNoContent,
+			}, nil),
+			apiMock.EXPECT().EnableModule(gomock.Any(), organizationId, stackID, "webhooks").Return(nil, errors.New("unexpected EOF")),
+			apiMock.EXPECT().ListModules(gomock.Any(), organizationId, stackID).Return(&operations.ListModulesResponse{
+				StatusCode: http.StatusOK,
+				ListModulesResponse: &shared.ListModulesResponse{
+					Data: []shared.Module{
+						{Name: "ledger", State: shared.ModuleStateEnabled, Status: shared.ModuleStatusReady},
+						{Name: "payments", State: shared.ModuleStateEnabled, Status: shared.ModuleStatusProgressing},
+					},
+				},
+			}, nil),
+		)
+
+		modules := func(names ...string) tftypes.Value {
+			values := make([]tftypes.Value, 0, len(names))
+			for _, name := range names {
+				values = append(values, tftypes.NewValue(tftypes.String, name))
+			}
+			return tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, values)
+		}
+
+		res := resource.UpdateResponse{
+			State: tfsdk.State{
+				Schema: resources.SchemaStack,
+			},
+		}
+		r.Update(ctx, resource.UpdateRequest{
+			State: tfsdk.State{
+				Raw: stackValue(map[string]tftypes.Value{
+					"id":      tftypes.NewValue(tftypes.String, stackID),
+					"modules": modules("ledger"),
+				}),
+				Schema: resources.SchemaStack,
+			},
+			Plan: tfsdk.Plan{
+				Raw: stackValue(map[string]tftypes.Value{
+					"id":            tftypes.NewValue(tftypes.String, stackID),
+					"modules":       modules("ledger", "payments", "webhooks"),
+					"module_status": tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, tftypes.UnknownValue),
+				}),
+				Schema: resources.SchemaStack,
+			},
+		}, &res)
+		require.True(t, res.Diagnostics.HasError())
+		require.Equal(t, "Failed to apply stack modules", res.Diagnostics.Errors()[0].Summary())
+
+		model := &resources.StackModel{}
+		require.Empty(t, res.State.Get(ctx, model))
+
+		// Terraform requires the configured modules to be kept, the next refresh reports the missing ones
+		var configured []string
+		require.Empty(t, model.Modules.ElementsAs(ctx, &configured, false))
+		require.ElementsMatch(t, []string{"ledger", "payments", "webhooks"}, configured)
+
+		status := map[string]string{}
+		require.Empty(t, model.ModuleStatus.ElementsAs(ctx, &status, false))
+		require.Equal(t, map[string]string{
+			"ledger":   string(shared.ModuleStatusReady),
+			"payments": string(shared.ModuleStatusProgressing),
+		}, status)
+	})
+}
+
+func TestStackReadNotFound(t *testing.T) {
+	test(t, func(ctx context.Context) {
+		r := resources.NewStack()().(resource.ResourceWithConfigure)
+		organizationId := uuid.NewString()
+		stackID := uuid.NewString()
+		ctrl := gomock.NewController(t)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		store := internal.NewStore(apiMock, tp)
+
+		tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{
+			ProviderData: store,
+		}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+
+		// The SDK returns a nil operation alongside the error
+		apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, stackID).Return(nil, pkg.NewAPIError(&sdkerrors.SDKError{
+			Message:    "API error occurred",
+			StatusCode: http.StatusNotFound,
+			Body:       `{"errorCode":"NOT_FOUND","errorMessage":"stack not found"}`,
+		}))
+
+		state := stackValue(map[string]tftypes.Value{
+			"id": tftypes.NewValue(tftypes.String, stackID),
+		})
+		res := resource.ReadResponse{
+			State: tfsdk.State{
+				Raw:    state,
+				Schema: resources.SchemaStack,
+			},
+		}
+		r.Read(ctx, resource.ReadRequest{
+			State: tfsdk.State{
+				Raw:    state,
+				Schema: resources.SchemaStack,
+			},
+		}, &res)
+		require.Empty(t, res.Diagnostics)
+		require.True(t, res.State.Raw.IsNull())
+	})
+}
+
+func TestStackOwnership(t *testing.T) {
+	type testCase struct {
+		name          string
+		workspaceID   string
+		marker        *string
+		adopt         bool
+		expectedError string
+		expectUpdate  bool
+	}
+
+	for _, tc := range []testCase{
+		{
+			name:          "unmarked stacks must be adopted",
+			expectedError: "Stack not managed by Terraform",
+		},
+		{
+			name:         "adoption marks the stack",
+			workspaceID:  "platform/production",
+			adopt:        true,
+			expectUpdate: true,
+		},
+		{
+			name:          "stacks of other workspaces are refused",
+			workspaceID:   "platform/production",
+			marker:        pointer.For("payments/production"),
+			adopt:         true,
+			expectedError: "Stack managed by another workspace",
+		},
+		{
+			name:         "stacks marked without workspace are claimed",
+			workspaceID:  "platform/production",
+			marker:       pointer.For("true"),
+			expectUpdate: true,
+		},
+		{
+			name:        "stacks of the workspace are managed",
+			workspaceID: "platform/production",
+			marker:      pointer.For("platform/production"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStack()().(resource.ResourceWithConfigure)
+				organizationId := uuid.NewString()
+				stackID := uuid.NewString()
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+				store.SetWorkspaceID(tc.workspaceID)
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+				configureRes := resource.ConfigureResponse{}
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				metadata := map[string]string{
+					"team":                                  "platform",
+					resources.MetadataDeletionProtectionKey: "false",
+				}
+				if tc.marker != nil {
+					metadata[resources.MetadataProtectedKey] = *tc.marker
+				}
+				apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, stackID).Return(&operations.GetStackResponse{
+					StatusCode: http.StatusOK,
+					CreateStackResponse: &shared.CreateStackResponse{
+						Data: &shared.Stack{ID: stackID, Name: "test", Metadata: metadata},
+					},
+				}, nil).Times(2)
+				if tc.expectUpdate {
+					apiMock.EXPECT().UpdateStack(gomock.Any(), organizationId, stackID, &shared.StackData{
+						Name: "test",
+						Metadata: map[string]string{
+							"team":                                  "platform",
+							resources.MetadataProtectedKey:          tc.workspaceID,
+							resources.MetadataDeletionProtectionKey: "false",
+						},
+					}).Return(&operations.UpdateStackResponse{
+						StatusCode: http.StatusOK,
+						CreateStackResponse: &shared.CreateStackResponse{
+							Data: &shared.Stack{ID: stackID, Name: "test", Metadata: metadata},
+						},
+					}, nil)
+				}
+				if tc.expectedError == "" {
+					apiMock.EXPECT().DeleteStack(gomock.Any(), organizationId, stackID, false).Return(&operations.DeleteStackResponse{
+						StatusCode: http.StatusNoContent,
+					}, nil)
+				}
+
+				value := stackValue(map[string]tftypes.Value{
+					"id":                  tftypes.NewValue(tftypes.String, stackID),
+					"name":                tftypes.NewValue(tftypes.String, "test"),
+					"adopt":               tftypes.NewValue(tftypes.Bool, tc.adopt),
+					"deletion_protection": tftypes.NewValue(tftypes.Bool, false),
+					"metadata": tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{
+						"team": tftypes.NewValue(tftypes.String, "platform"),
+					}),
+				})
+
+				updateRes := resource.UpdateResponse{
+					State: tfsdk.State{Schema: resources.SchemaStack},
+				}
+				r.Update(ctx, resource.UpdateRequest{
+					State: tfsdk.State{Raw: value, Schema: resources.SchemaStack},
+					Plan:  tfsdk.Plan{Raw: value, Schema: resources.SchemaStack},
+				}, &updateRes)
+
+				deleteRes := resource.DeleteResponse{}
+				r.Delete(ctx, resource.DeleteRequest{
+					State: tfsdk.State{Raw: value, Schema: resources.SchemaStack},
+				}, &deleteRes)
+
+				if tc.expectedError != "" {
+					require.Len(t, updateRes.Diagnostics, 1)
+					require.Equal(t, tc.expectedError, updateRes.Diagnostics[0].Summary())
+					require.Len(t, deleteRes.Diagnostics, 1)
+					require.Equal(t, tc.expectedError, deleteRes.Diagnostics[0].Summary())
+					return
+				}
+				require.Empty(t, updateRes.Diagnostics)
+				require.Empty(t, deleteRes.Diagnostics)
+			})
+		})
+	}
+}
+
+func TestStackPlanOwnership(t *testing.T) {
+	type testCase struct {
+		name          string
+		marker        *string
+		adopt         any
+		refresh       bool
+		expectedError string
+	}
+
+	for _, tc := range []testCase{
+		{
+			name:          "imported stacks must be adopted",
+			refresh:       true,
+			expectedError: "Stack not managed by Terraform",
+		},
+		{
+			name:    "adopted stacks are planned",
+			adopt:   true,
+			refresh: true,
+		},
+		{
+			name:          "stacks of other workspaces are refused without refresh",
+			marker:        pointer.For("payments/production"),
+			adopt:         true,
+			expectedError: "Stack managed by another workspace",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, func(ctx context.Context) {
+				r := resources.NewStack()().(resource.ResourceWithConfigure)
+				organizationId := uuid.NewString()
+				stackID := uuid.NewString()
+				ctrl := gomock.NewController(t)
+				tp := pkg.NewMockTokenProviderImpl(ctrl)
+				apiMock := pkg.NewMockCloudSDK(ctrl)
+				store := internal.NewStore(apiMock, tp)
+				store.SetWorkspaceID("platform/production")
+
+				tp.EXPECT().OrganizationId(gomock.Any()).Return(organizationId, nil).AnyTimes()
+				configureRes := resource.ConfigureResponse{}
+				r.Configure(ctx, resource.ConfigureRequest{
+					ProviderData: store,
+				}, &configureRes)
+				require.Empty(t, configureRes.Diagnostics)
+
+				metadata := map[string]string{resources.MetadataDeletionProtectionKey: "true"}
+				if tc.marker != nil {
+					metadata[resources.MetadataProtectedKey] = *tc.marker
+				}
+				// The stack is read once, by the refresh or by the plan
+				apiMock.EXPECT().ReadStack(gomock.Any(), organizationId, stackID).Return(&operations.GetStackResponse{
+					StatusCode: http.StatusOK,
+					CreateStackResponse: &shared.CreateStackResponse{
+						Data: &shared.Stack{ID: stackID, Name: "test", RegionID: "staging", Metadata: metadata},
+					},
+				}, nil)
+
+				state := stackValue(map[string]tftypes.Value{
+					"id": tftypes.NewValue(tftypes.String, stackID),
+				})
+				if tc.refresh {
+					res := resource.ReadResponse{State: tfsdk.State{Raw: state, Schema: resources.SchemaStack}}
+					r.Read(ctx, resource.ReadRequest{State: tfsdk.State{Raw: state, Schema: resources.SchemaStack}}, &res)
+					require.Empty(t, res.Diagnostics)
+				}
+
+				config := stackValue(map[string]tftypes.Value{
+					"id":    tftypes.NewValue(tftypes.String, stackID),
+					"adopt": tftypes.NewValue(tftypes.Bool, tc.adopt),
+				})
+				plan := tfsdk.Plan{Raw: config, Schema: resources.SchemaStack}
+				res := resource.ModifyPlanResponse{Plan: plan}
+				r.(resource.ResourceWithModifyPlan).ModifyPlan(ctx, resource.ModifyPlanRequest{
+					Config: tfsdk.Config{Raw: config, Schema: resources.SchemaStack},
+					Plan:   plan,
+					State:  tfsdk.State{Raw: state, Schema: resources.SchemaStack},
+				}, &res)
+
+				if tc.expectedError == "" {
+					require.Empty(t, res.Diagnostics)
+					return
+				}
+				require.Len(t, res.Diagnostics, 1)
+				require.Equal(t, tc.expectedError, res.Diagnostics[0].Summary())
+			})
+		})
+	}
+}
+
+func TestStackImportState(t *testing.T) {
+	test(t, func(ctx context.Context) {
+		r := resources.NewStack()().(resource.ResourceWithImportState)
+
+		res := resource.ImportStateResponse{
+			State: tfsdk.State{
+				Raw:    tftypes.NewValue(tftypes.Object{AttributeTypes: getSchemaTypes(resources.SchemaStack)}, nil),
+				Schema: resources.SchemaStack,
+			},
+		}
+		r.ImportState(ctx, resource.ImportStateRequest{ID: "stack-id"}, &res)
+		require.Empty(t, res.Diagnostics)
+
+		var id string
+		require.Empty(t, res.State.GetAttribute(ctx, path.Root("id"), &id))
+		require.Equal(t, "stack-id", id)
+	})
+}
+
+func TestStackDefaults(t *testing.T) {
+	defaults := internal.StackDefaults{
+		Metadata: map[string]string{"team": "platform", "managed_by": "terraform"},
+		RegionID: "staging",
+		Version:  "v2.2.0",
+	}
+	metadata := func(values map[string]string) tftypes.Value {
+		if values == nil {
+			return tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil)
+		}
+		elements := map[string]tftypes.Value{}
+		for k, v := range values {
+			elements[k] = tftypes.NewValue(tftypes.String, v)
+		}
+		return tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, elements)
+	}
+	setup := func(t *testing.T, ctx context.Context, defaults internal.StackDefaults) (resource.Resource, *pkg.MockCloudSDK) {
+		ctrl := gomock.NewController(t)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		tp.EXPECT().OrganizationId(gomock.Any()).Return("organization-id", nil).AnyTimes()
+		store := internal.NewStore(apiMock, tp)
+		store.SetStackMetadata("stack-id", map[string]string{resources.MetadataProtectedKey: "true"})
+		store.SetStackDefaults(defaults)
+
+		r := resources.NewStack()().(resource.ResourceWithConfigure)
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{ProviderData: store}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+		return r, apiMock
+	}
+	modifyPlan := func(ctx context.Context, r resource.Resource, config, state tftypes.Value) resource.ModifyPlanResponse {
+		plan := tfsdk.Plan{Raw: config, Schema: resources.SchemaStack}
+		res := resource.ModifyPlanResponse{Plan: plan}
+		r.(resource.ResourceWithModifyPlan).ModifyPlan(ctx, resource.ModifyPlanRequest{
+			Config: tfsdk.Config{Raw: config, Schema: resources.SchemaStack},
+			Plan:   plan,
+			State:  tfsdk.State{Raw: state, Schema: resources.SchemaStack},
+		}, &res)
+		return res
+	}
+	noState := tftypes.NewValue(tftypes.Object{AttributeTypes: getSchemaTypes(resources.SchemaStack)}, nil)
+
+	t.Run("creation falls back to the defaults", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, _ := setup(t, ctx, defaults)
+
+			res := modifyPlan(ctx, r, stackValue(map[string]tftypes.Value{
+				"region_id":    tftypes.NewValue(tftypes.String, nil),
+				"version":      tftypes.NewValue(tftypes.String, nil),
+				"metadata":     metadata(map[string]string{"team": "payments"}),
+				"metadata_all": tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, tftypes.UnknownValue),
+			}), noState)
+			require.Empty(t, res.Diagnostics)
+
+			model := &resources.StackModel{}
+			require.Empty(t, res.Plan.Get(ctx, model))
+			require.Equal(t, "staging", model.GetRegionID())
+			require.Equal(t, "v2.2.0", model.Version.ValueString())
+
+			all := map[string]string{}
+			require.Empty(t, model.MetadataAll.ElementsAs(ctx, &all, false))
+			require.Equal(t, map[string]string{"team": "payments", "managed_by": "terraform"}, all)
+		})
+	})
+
+	t.Run("region is required without default", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, _ := setup(t, ctx, internal.StackDefaults{})
+
+			res := modifyPlan(ctx, r, stackValue(map[string]tftypes.Value{
+				"region_id": tftypes.NewValue(tftypes.String, nil),
+			}), noState)
+			require.Len(t, res.Diagnostics, 1)
+			require.Equal(t, "Invalid Region ID", res.Diagnostics[0].Summary())
+		})
+	})
+
+	t.Run("outdated defaults are updated", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, _ := setup(t, ctx, defaults)
+
+			state := stackValue(map[string]tftypes.Value{
+				"id":           tftypes.NewValue(tftypes.String, "stack-id"),
+				"version":      tftypes.NewValue(tftypes.String, "v2.1.0"),
+				"metadata":     metadata(map[string]string{"team": "core", "owner": "alice"}),
+				"metadata_all": metadata(map[string]string{"team": "core", "owner": "alice"}),
+			})
+			config := stackValue(map[string]tftypes.Value{
+				"id":           tftypes.NewValue(tftypes.String, "stack-id"),
+				"version":      tftypes.NewValue(tftypes.String, "v2.1.0"),
+				"metadata":     metadata(map[string]string{"team": "core", "owner": "alice"}),
+				"metadata_all": metadata(map[string]string{"team": "core", "owner": "alice"}),
+			})
+			plan := tfsdk.Plan{Raw: config, Schema: resources.SchemaStack}
+			res := resource.ModifyPlanResponse{Plan: plan}
+			r.(resource.ResourceWithModifyPlan).ModifyPlan(ctx, resource.ModifyPlanRequest{
+				Config: tfsdk.Config{Raw: stackValue(map[string]tftypes.Value{
+					"id":      tftypes.NewValue(tftypes.String, "stack-id"),
+					"version": tftypes.NewValue(tftypes.String, nil),
+				}), Schema: resources.SchemaStack},
+				Plan:  plan,
+				State: tfsdk.State{Raw: state, Schema: resources.SchemaStack},
+			}, &res)
+			require.Empty(t, res.Diagnostics)
+
+			model := &resources.StackModel{}
+			require.Empty(t, res.Plan.Get(ctx, model))
+			require.Equal(t, "v2.1.0", model.Version.ValueString(), "existing stacks must not be upgraded to the default version")
+
+			own := map[string]string{}
+			require.Empty(t, model.Metadata.ElementsAs(ctx, &own, false))
+			require.Equal(t, map[string]string{"owner": "alice"}, own)
+			all := map[string]string{}
+			require.Empty(t, model.MetadataAll.ElementsAs(ctx, &all, false))
+			require.Equal(t, map[string]string{"team": "platform", "managed_by": "terraform", "owner": "alice"}, all)
+		})
+	})
+
+	t.Run("default metadata is hidden from metadata", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, apiMock := setup(t, ctx, defaults)
+			apiMock.EXPECT().ReadStack(gomock.Any(), gomock.Any(), "stack-id").Return(&operations.GetStackResponse{
+				StatusCode: http.StatusOK,
+				CreateStackResponse: &shared.CreateStackResponse{
+					Data: &shared.Stack{
+						ID:       "stack-id",
+						Name:     "test",
+						RegionID: "staging",
+						Metadata: map[string]string{
+							resources.MetadataProtectedKey: "true",
+							"team":                         "platform",
+							"managed_by":                   "terraform",
+							"owner":                        "alice",
+						},
+					},
+				},
+			}, nil)
+
+			res := resource.ReadResponse{State: tfsdk.State{Schema: resources.SchemaStack}}
+			r.Read(ctx, resource.ReadRequest{
+				State: tfsdk.State{Raw: stackValue(map[string]tftypes.Value{
+					"id":       tftypes.NewValue(tftypes.String, "stack-id"),
+					"metadata": metadata(map[string]string{"managed_by": "terraform", "owner": "alice"}),
+				}), Schema: resources.SchemaStack},
+			}, &res)
+			require.Empty(t, res.Diagnostics)
+
+			model := &resources.StackModel{}
+			require.Empty(t, res.State.Get(ctx, model))
+			own := map[string]string{}
+			require.Empty(t, model.Metadata.ElementsAs(ctx, &own, false))
+			require.Equal(t, map[string]string{"managed_by": "terraform", "owner": "alice"}, own, "configured keys must be kept")
+			require.Len(t, model.MetadataAll.Elements(), 3)
+		})
+	})
+}
+
+func TestStackGuardrails(t *testing.T) {
+	guardrails := internal.Guardrails{
+		AllowedRegionIDs:     []string{"staging"},
+		AllowedModules:       []string{"ledger", "payments"},
+		StackNamePattern:     regexp.MustCompile(`^prod-[a-z]+$`),
+		RequiredMetadataKeys: []string{"cost_center"},
+	}
+	setup := func(t *testing.T, ctx context.Context, guardrails internal.Guardrails) (resource.Resource, *pkg.MockCloudSDK, *internal.Store) {
+		ctrl := gomock.NewController(t)
+		apiMock := pkg.NewMockCloudSDK(ctrl)
+		tp := pkg.NewMockTokenProviderImpl(ctrl)
+		tp.EXPECT().OrganizationId(gomock.Any()).Return("organization-id", nil).AnyTimes()
+		store := internal.NewStore(apiMock, tp)
+		store.SetStackMetadata("stack-id", map[string]string{resources.MetadataProtectedKey: "true"})
+		store.SetGuardrails(guardrails)
+
+		r := resources.NewStack()().(resource.ResourceWithConfigure)
+		configureRes := resource.ConfigureResponse{}
+		r.Configure(ctx, resource.ConfigureRequest{ProviderData: store}, &configureRes)
+		require.Empty(t, configureRes.Diagnostics)
+		return r, apiMock, store
+	}
+	validateConfig := func(ctx context.Context, r resource.Resource, config tftypes.Value) resource.ValidateConfigResponse {
+		res := resource.ValidateConfigResponse{}
+		r.(resource.ResourceWithValidateConfig).ValidateConfig(ctx, resource.ValidateConfigRequest{
+			Config: tfsdk.Config{Raw: config, Schema: resources.SchemaStack},
+		}, &res)
+		return res
+	}
+	modifyPlan := func(ctx context.Context, r resource.Resource, config, state tftypes.Value) resource.ModifyPlanResponse {
+		plan := tfsdk.Plan{Raw: config, Schema: resources.SchemaStack}
+		res := resource.ModifyPlanResponse{Plan: plan}
+		r.(resource.ResourceWithModifyPlan).ModifyPlan(ctx, resource.ModifyPlanRequest{
+			Config: tfsdk.Config{Raw: config, Schema: resources.SchemaStack},
+			Plan:   plan,
+			State:  tfsdk.State{Raw: state, Schema: resources.SchemaStack},
+		}, &res)
+		return res
+	}
+	metadata := func(values map[string]string) tftypes.Value {
+		elements := map[string]tftypes.Value{}
+		for k, v := range values {
+			elements[k] = tftypes.NewValue(tftypes.String, v)
+		}
+		return tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, elements)
+	}
+	noState := tftypes.NewValue(tftypes.Object{AttributeTypes: getSchemaTypes(resources.SchemaStack)}, nil)
+
+	t.Run("violations are reported when validating", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, _, _ := setup(t, ctx, guardrails)
+
+			res := validateConfig(ctx, r, stackValue(map[string]tftypes.Value{
+				"name":      tftypes.NewValue(tftypes.String, "test"),
+				"region_id": tftypes.NewValue(tftypes.String, "eu-west"),
+				"modules": tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, []tftypes.Value{
+					tftypes.NewValue(tftypes.String, "ledger"),
+					tftypes.NewValue(tftypes.String, "webhooks"),
+				}),
+			}))
+
+			require.Len(t, res.Diagnostics, 4)
+			paths := []path.Path{}
+			for _, d := range res.Diagnostics {
+				require.Equal(t, "Policy Violation", d.Summary())
+				paths = append(paths, d.(diag.DiagnosticWithPath).Path())
+			}
+			require.ElementsMatch(t, []path.Path{
+				path.Root("region_id"),
+				path.Root("name"),
+				path.Root("metadata"),
+				path.Root("modules").AtSetValue(types.StringValue("webhooks")),
+			}, paths)
+		})
+	})
+
+	t.Run("compliant stacks are validated", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, _, _ := setup(t, ctx, guardrails)
+
+			res := validateConfig(ctx, r, stackValue(map[string]tftypes.Value{
+				"name":     tftypes.NewValue(tftypes.String, "prod-ledger"),
+				"metadata": metadata(map[string]string{"cost_center": "finance"}),
+			}))
+			require.Empty(t, res.Diagnostics)
+		})
+	})
+
+	t.Run("defaults of the provider are validated", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, _, store := setup(t, ctx, guardrails)
+			store.SetStackDefaults(internal.StackDefaults{
+				RegionID: "eu-west",
+				Metadata: map[string]string{"cost_center": "finance"},
+			})
+
+			res := validateConfig(ctx, r, stackValue(map[string]tftypes.Value{
+				"name":      tftypes.NewValue(tftypes.String, "prod-ledger"),
+				"region_id": tftypes.NewValue(tftypes.String, nil),
+			}))
+			require.Len(t, res.Diagnostics, 1)
+			require.Equal(t, path.Root("region_id"), res.Diagnostics[0].(diag.DiagnosticWithPath).Path())
+		})
+	})
+
+	t.Run("stacks are counted against max_stacks", func(t *testing.T) {
+		test(t, func(ctx context.Context) {
+			r, apiMock, _ := setup(t, ctx, internal.Guardrails{MaxStacks: 4})
+
+			apiMock.EXPECT().ListStacks(gomock.Any(), "organization-id").Return(&operations.ListStacksResponse{
+				ListStacksResponse: &shared.ListStacksResponse{Data: []shared.Stack{
+					{ID: "existing", Name: "existing", State: shared.StackStateActive},
+					{ID: "deleted", Name: "deleted", State: shared.StackStateDeleted},
+					{ID: "orphan", Name: "orphan", State: shared.StackStateActive, Metadata: map[string]string{
+						resources.MetadataProtectedKey:   "true",
+						resources.MetadataIdempotencyKey: "orphan-key",
+					}},
+				}},
+			}, nil).AnyTimes()
+			planStack := func(name string, idempotencyKey any) resource.ModifyPlanResponse {
+				return modifyPlan(ctx, r, stackValue(map[string]tftypes.Value{
+					"name":            tftypes.NewValue(tftypes.String, name),
+					"idempotency_key": tftypes.NewValue(tftypes.String, idempotencyKey),
+				}), noState)
+			}
+
+			// The stack adopting the orphan is already counted
+			require.Empty(t, planStack("orphan", "orphan-key").Diagnostics)
+			// Stacks named like an existing stack are counted
+			require.Empty(t, planStack("existing", nil).Diagnostics)
+			require.Empty(t, planStack("other", nil).Diagnostics)
+
+			// Stacks with the same name are counted twice
+			res := planStack("other", nil)
+			require.Len(t, res.Diagnostics, 1)
+			require.Equal(t, "Policy Violation", res.Diagnostics[0].Summary())
+			require.Contains(t, res.Diagnostics[0].Detail(), "brings the organization to 5 stacks, 2 existing and 3 planned")
+		})
+	})
+}
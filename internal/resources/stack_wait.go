@@ -0,0 +1,87 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+)
+
+var (
+	stackPollInterval = 10 * time.Second
+	stackReadyTimeout = 30 * time.Minute
+)
+
+// waitStackReady polls the stack until it reports the READY status.
+func waitStackReady(ctx context.Context, sdk pkg.CloudSDK, organizationID, stackID string) (*shared.Stack, error) {
+	return waitStack(ctx, sdk, organizationID, stackID, false)
+}
+
+// waitStackHealthy polls the stack until it reports the READY status and is reachable.
+func waitStackHealthy(ctx context.Context, sdk pkg.CloudSDK, organizationID, stackID string) (*shared.Stack, error) {
+	return waitStack(ctx, sdk, organizationID, stackID, true)
+}
+
+func waitStack(ctx context.Context, sdk pkg.CloudSDK, organizationID, stackID string, reachable bool) (*shared.Stack, error) {
+	ctx, cancel := context.WithTimeout(ctx, stackReadyTimeout)
+	defer cancel()
+
+	for {
+		operation, err := sdk.ReadStack(ctx, organizationID, stackID)
+		if err != nil {
+			return nil, err
+		}
+
+		stack := operation.CreateStackResponse.Data
+		switch stack.Status {
+		case shared.StackStatusReady:
+			if !reachable || stack.Reachable {
+				return stack, nil
+			}
+		case shared.StackStatusDisabled, shared.StackStatusDeleted:
+			return nil, fmt.Errorf("stack '%s' is %s", stackID, stack.Status)
+		}
+
+		select {
+		case <-ctx.Done():
+			if stack.Status == shared.StackStatusReady {
+				return nil, fmt.Errorf("stack '%s' is not reachable: %w", stackID, ctx.Err())
+			}
+			return nil, fmt.Errorf("stack '%s' is still %s: %w", stackID, stack.Status, ctx.Err())
+		case <-time.After(stackPollInterval):
+		}
+	}
+}
+
+// waitModules polls the modules of the stack until every enabled module reports the READY status.
+func waitModules(ctx context.Context, sdk pkg.CloudSDK, organizationID, stackID string) ([]shared.Module, error) {
+	ctx, cancel := context.WithTimeout(ctx, stackReadyTimeout)
+	defer cancel()
+
+	for {
+		operation, err := sdk.ListModules(internal.ContextWithoutCache(ctx), organizationID, stackID)
+		if err != nil {
+			return nil, err
+		}
+
+		pending := ""
+		for _, module := range operation.ListModulesResponse.Data {
+			if module.State == shared.ModuleStateEnabled && module.Status != shared.ModuleStatusReady {
+				pending = module.Name
+				break
+			}
+		}
+		if pending == "" {
+			return operation.ListModulesResponse.Data, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("module '%s' of stack '%s' is not ready: %w", pending, stackID, ctx.Err())
+		case <-time.After(stackPollInterval):
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package internal
+
+// Scopes are the scopes needed by a resource or a data source.
+// Read scopes are needed to refresh it, write scopes to apply changes.
+type Scopes struct {
+	Read  []string
+	Write []string
+}
+
+var (
+	stackReadScopes = []string{
+		"organization:ListStacks",
+		"organization:ReadStack",
+		"organization:ListStackModules",
+		"organization:ReadRegion",
+	}
+	stackWriteScopes = []string{
+		"organization:CreateStack",
+		"organization:UpdateStack",
+		"organization:DeleteStack",
+		"organization:UpgradeStack",
+		"organization:EnableStackModule",
+		"organization:DisableStackModule",
+	}
+)
+
+// RequiredScopes lists the scopes needed by each resource and data source, by type name.
+var RequiredScopes = map[string]Scopes{
+	"cloud_stack": {
+		Read:  stackReadScopes,
+		Write: stackWriteScopes,
+	},
+	"cloud_stack_module": {
+		Read: []string{
+			"organization:ReadStack",
+			"organization:ListStackModules",
+			"organization:ReadRegion",
+		},
+		Write: []string{
+			"organization:EnableStackModule",
+			"organization:DisableStackModule",
+		},
+	},
+	"cloud_stack_member": {
+		Read: []string{
+			"organization:ReadStackUser",
+		},
+		Write: []string{
+			"organization:UpdateStackUser",
+			"organization:DeleteStackUser",
+		},
+	},
+	"cloud_stack_rollout": {
+		Read: []string{
+			"organization:ReadStack",
+		},
+		Write: []string{
+			"organization:UpgradeStack",
+		},
+	},
+	"cloud_stack_clone": {
+		Read: append([]string{
+			"organization:ReadStackUser",
+		}, stackReadScopes...),
+		Write: append([]string{
+			"organization:UpdateStackUser",
+			"organization:DeleteStackUser",
+		}, stackWriteScopes...),
+	},
+	"cloud_organization_member": {
+		Read: []string{
+			"organization:ReadUser",
+		},
+		Write: []string{
+			"organization:CreateUser",
+			"organization:UpdateUser",
+		},
+	},
+	"cloud_current_organization": {
+		Read: []string{
+			"organization:Read",
+		},
+	},
+	"cloud_regions": {
+		Read: []string{
+			"organization:ListRegions",
+			"organization:ReadRegion",
+		},
+	},
+	"cloud_region_versions": {
+		Read: []string{
+			"organization:ListRegions",
+			"organization:ReadRegion",
+		},
+	},
+	"cloud_stacks": {
+		Read: []string{
+			"organization:ListStacks",
+			"organization:ReadStack",
+		},
+	},
+	"cloud_stack_upgrade_path": {
+		Read: []string{
+			"organization:ReadStack",
+			"organization:ReadRegion",
+		},
+	},
+}
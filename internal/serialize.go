@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// StackBusyPollInterval is the interval between two reads of a busy stack.
+	StackBusyPollInterval = 10 * time.Second
+	// StackBusyTimeout bounds the time a mutation waits for its stack to be READY, across all its retries.
+	StackBusyTimeout = 30 * time.Minute
+	// StackBusyMaxRetries bounds the retries of a mutation rejected as its stack is busy, in case the stack
+	// keeps being updated by others.
+	StackBusyMaxRetries = 10
+)
+
+// serializedSDK runs the mutations of a stack one at a time. Mutations rejected with a conflict or a
+// validation error while the stack is not READY, as it is being updated by another provider or by the
+// control plane, are retried once the stack is READY.
+type serializedSDK struct {
+	pkg.CloudSDK
+
+	mu     sync.Mutex
+	stacks map[string]chan struct{}
+}
+
+func newSerializedSDK(sdk pkg.CloudSDK) *serializedSDK {
+	return &serializedSDK{
+		CloudSDK: sdk,
+		stacks:   map[string]chan struct{}{},
+	}
+}
+
+// lock waits for the other mutations of the stack to complete.
+func (s *serializedSDK) lock(ctx context.Context, stackID string) (func(), error) {
+	s.mu.Lock()
+	stack, ok := s.stacks[stackID]
+	if !ok {
+		stack = make(chan struct{}, 1)
+		s.stacks[stackID] = stack
+	}
+	s.mu.Unlock()
+
+	select {
+	case stack <- struct{}{}:
+		return func() { <-stack }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func serialize[T any](ctx context.Context, s *serializedSDK, operation, organizationID, stackID string, fn func() (T, error)) (T, error) {
+	var zero T
+
+	start := time.Now()
+	unlock, err := s.lock(ctx, stackID)
+	if err != nil {
+		return zero, err
+	}
+	defer unlock()
+	if wait := time.Since(start); wait > time.Second {
+		logging.FromContext(ctx).Debugf("%s waited %s for other operations on stack %s", operation, wait, stackID)
+	}
+
+	// The lock of the stack is held while waiting, the deadline bounds the wait of the other mutations too
+	waitCtx, cancel := context.WithTimeout(ctx, StackBusyTimeout)
+	defer cancel()
+
+	for retry := 0; ; retry++ {
+		res, err := fn()
+		if !pkg.MayBeStackBusy(err) {
+			return res, err
+		}
+		// Only the mutations of stacks which are not READY are retried, the other rejections are final
+		if ready, readErr := s.isStackReady(ctx, organizationID, stackID); readErr != nil || ready {
+			return res, err
+		}
+		if retry >= StackBusyMaxRetries {
+			return zero, fmt.Errorf("%s: stack %s is still busy after %d retries: %w", operation, stackID, retry, err)
+		}
+
+		logging.FromContext(ctx).Infof("%s rejected as stack %s is busy, waiting for it to be READY: %s", operation, stackID, err)
+		trace.SpanFromContext(ctx).AddEvent("stack_busy", trace.WithAttributes(
+			attribute.String("operation", operation),
+			attribute.String("stack_id", stackID),
+		))
+		if err := s.waitStackReady(waitCtx, organizationID, stackID); err != nil {
+			return zero, fmt.Errorf("%s: stack %s is busy: %w", operation, stackID, err)
+		}
+	}
+}
+
+func (s *serializedSDK) isStackReady(ctx context.Context, organizationID, stackID string) (bool, error) {
+	operation, err := s.ReadStack(ctx, organizationID, stackID)
+	if err != nil {
+		return false, err
+	}
+	return operation.CreateStackResponse.GetData().GetStatus() == shared.StackStatusReady, nil
+}
+
+func (s *serializedSDK) waitStackReady(ctx context.Context, organizationID, stackID string) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(StackBusyPollInterval):
+		}
+
+		ready, err := s.isStackReady(ctx, organizationID, stackID)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+	}
+}
+
+func (s *serializedSDK) UpdateStack(ctx context.Context, organizationID, stackID string, body *shared.StackData) (*operations.UpdateStackResponse, error) {
+	return serialize(ctx, s, "UpdateStack", organizationID, stackID, func() (*operations.UpdateStackResponse, error) {
+		return s.CloudSDK.UpdateStack(ctx, organizationID, stackID, body)
+	})
+}
+
+func (s *serializedSDK) UpgradeStack(ctx context.Context, organizationID, stackID, version string) (*operations.UpgradeStackResponse, error) {
+	return serialize(ctx, s, "UpgradeStack", organizationID, stackID, func() (*operations.UpgradeStackResponse, error) {
+		return s.CloudSDK.UpgradeStack(ctx, organizationID, stackID, version)
+	})
+}
+
+func (s *serializedSDK) EnableModule(ctx context.Context, organizationID, stackID, moduleName string) (*operations.EnableModuleResponse, error) {
+	return serialize(ctx, s, "EnableModule", organizationID, stackID, func() (*operations.EnableModuleResponse, error) {
+		return s.CloudSDK.EnableModule(ctx, organizationID, stackID, moduleName)
+	})
+}
+
+func (s *serializedSDK) DisableModule(ctx context.Context, organizationID, stackID, moduleName string) (*operations.DisableModuleResponse, error) {
+	return serialize(ctx, s, "DisableModule", organizationID, stackID, func() (*operations.DisableModuleResponse, error) {
+		return s.CloudSDK.DisableModule(ctx, organizationID, stackID, moduleName)
+	})
+}
+
+func (s *serializedSDK) UpsertStackUserAccess(ctx context.Context, organizationID, stackID, userID string, body *shared.UpdateStackUserRequest) (*operations.UpsertStackUserAccessResponse, error) {
+	return serialize(ctx, s, "UpsertStackUserAccess", organizationID, stackID, func() (*operations.UpsertStackUserAccessResponse, error) {
+		return s.CloudSDK.UpsertStackUserAccess(ctx, organizationID, stackID, userID, body)
+	})
+}
+
+func (s *serializedSDK) DeleteStackUserAccess(ctx context.Context, organizationID, stackID, userID string) (*operations.DeleteStackUserAccessResponse, error) {
+	return serialize(ctx, s, "DeleteStackUserAccess", organizationID, stackID, func() (*operations.DeleteStackUserAccessResponse, error) {
+		return s.CloudSDK.DeleteStackUserAccess(ctx, organizationID, stackID, userID)
+	})
+}
+
+var _ pkg.CloudSDK = &serializedSDK{}
@@ -0,0 +1,137 @@
+package internal_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/sdkerrors"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStoreSerializesStackMutations(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	sdk := pkg.NewMockCloudSDK(ctrl)
+	store := internal.NewStore(sdk, pkg.NewMockTokenProviderImpl(ctrl))
+	ctx := logging.TestingContext()
+
+	var inFlight, maxInFlight atomic.Int32
+	sdk.EXPECT().EnableModule(gomock.Any(), "org", "stack", gomock.Any()).DoAndReturn(func(context.Context, string, string, string) (*operations.EnableModuleResponse, error) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		if current > maxInFlight.Load() {
+			maxInFlight.Store(current)
+		}
+		time.Sleep(5 * time.Millisecond)
+		return &operations.EnableModuleResponse{}, nil
+	}).Times(5)
+
+	wg := sync.WaitGroup{}
+	for _, module := range []string{"ledger", "payments", "wallets", "webhooks", "orchestration"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.GetSDK().EnableModule(ctx, "org", "stack", module)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), maxInFlight.Load())
+}
+
+func TestStoreRetriesMutationsOfBusyStacks(t *testing.T) {
+	internal.StackBusyPollInterval = time.Millisecond
+	t.Cleanup(func() {
+		internal.StackBusyPollInterval = 10 * time.Second
+	})
+
+	ctrl := gomock.NewController(t)
+	sdk := pkg.NewMockCloudSDK(ctrl)
+	store := internal.NewStore(sdk, pkg.NewMockTokenProviderImpl(ctrl))
+	ctx := logging.TestingContext()
+
+	busy := sdkerrors.NewSDKError("API error occurred", http.StatusConflict, `{"errorCode":"CONFLICT","errorMessage":"stack is not ready"}`, &http.Response{Header: http.Header{}})
+	stack := func(status shared.StackStatus) *operations.GetStackResponse {
+		return &operations.GetStackResponse{
+			CreateStackResponse: &shared.CreateStackResponse{Data: &shared.Stack{Status: status}},
+		}
+	}
+
+	gomock.InOrder(
+		sdk.EXPECT().DisableModule(gomock.Any(), "org", "stack", "ledger").Return(nil, pkg.NewAPIError(busy)),
+		sdk.EXPECT().ReadStack(gomock.Any(), "org", "stack").Return(stack(shared.StackStatusProgressing), nil),
+		sdk.EXPECT().ReadStack(gomock.Any(), "org", "stack").Return(stack(shared.StackStatusReady), nil),
+		sdk.EXPECT().DisableModule(gomock.Any(), "org", "stack", "ledger").Return(&operations.DisableModuleResponse{}, nil),
+	)
+
+	_, err := store.GetSDK().DisableModule(ctx, "org", "stack", "ledger")
+	require.NoError(t, err)
+}
+
+func TestStoreDoesNotRetryConflictsOfReadyStacks(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	sdk := pkg.NewMockCloudSDK(ctrl)
+	store := internal.NewStore(sdk, pkg.NewMockTokenProviderImpl(ctrl))
+	ctx := logging.TestingContext()
+
+	conflict := sdkerrors.NewSDKError("API error occurred", http.StatusConflict, `{"errorCode":"CONFLICT","errorMessage":"user already exists"}`, &http.Response{Header: http.Header{}})
+	gomock.InOrder(
+		sdk.EXPECT().UpsertStackUserAccess(gomock.Any(), "org", "stack", "user", gomock.Any()).Return(nil, pkg.NewAPIError(conflict)),
+		sdk.EXPECT().ReadStack(gomock.Any(), "org", "stack").Return(&operations.GetStackResponse{
+			CreateStackResponse: &shared.CreateStackResponse{Data: &shared.Stack{Status: shared.StackStatusReady}},
+		}, nil),
+	)
+
+	_, err := store.GetSDK().UpsertStackUserAccess(ctx, "org", "stack", "user", &shared.UpdateStackUserRequest{})
+	require.ErrorIs(t, err, pkg.ErrConflict)
+}
+
+func TestStoreBoundsRetriesOfBusyStacks(t *testing.T) {
+	internal.StackBusyPollInterval = time.Millisecond
+	internal.StackBusyMaxRetries = 3
+	t.Cleanup(func() {
+		internal.StackBusyPollInterval = 10 * time.Second
+		internal.StackBusyMaxRetries = 10
+	})
+
+	ctrl := gomock.NewController(t)
+	sdk := pkg.NewMockCloudSDK(ctrl)
+	store := internal.NewStore(sdk, pkg.NewMockTokenProviderImpl(ctrl))
+	ctx := logging.TestingContext()
+
+	// The stack is updated again by others each time it gets READY
+	busy := sdkerrors.NewSDKError("API error occurred", http.StatusConflict, `{"errorCode":"CONFLICT","errorMessage":"stack is not ready"}`, &http.Response{Header: http.Header{}})
+	var reads atomic.Int32
+	sdk.EXPECT().EnableModule(gomock.Any(), "org", "stack", "ledger").Return(nil, pkg.NewAPIError(busy)).Times(4)
+	sdk.EXPECT().ReadStack(gomock.Any(), "org", "stack").DoAndReturn(func(context.Context, string, string) (*operations.GetStackResponse, error) {
+		status := shared.StackStatusProgressing
+		if reads.Add(1)%2 == 0 {
+			status = shared.StackStatusReady
+		}
+		return &operations.GetStackResponse{
+			CreateStackResponse: &shared.CreateStackResponse{Data: &shared.Stack{Status: status}},
+		}, nil
+	}).Times(7)
+
+	_, err := store.GetSDK().EnableModule(ctx, "org", "stack", "ledger")
+	require.ErrorContains(t, err, "still busy after 3 retries")
+
+	// The lock of the stack is released
+	sdk.EXPECT().EnableModule(gomock.Any(), "org", "stack", "payments").Return(&operations.EnableModuleResponse{}, nil)
+	_, err = store.GetSDK().EnableModule(ctx, "org", "stack", "payments")
+	require.NoError(t, err)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+type DefaultsModel struct {
+	DefaultMetadata types.Map    `tfsdk:"default_metadata"`
+	RegionID        types.String `tfsdk:"region_id"`
+	Version         types.String `tfsdk:"version"`
+}
+
+var DefaultsSchema = schema.SingleNestedAttribute{
+	Description: "Defaults applied to every cloud_stack managed by the provider.",
+	Optional:    true,
+	Attributes: map[string]schema.Attribute{
+		"default_metadata": schema.MapAttribute{
+			Description: "Metadata merged into the metadata of every stack, the metadata of the stack taking precedence.",
+			Optional:    true,
+			ElementType: types.StringType,
+			Validators: []validator.Map{
+				mapvalidator.KeysAre(stringvalidator.LengthAtLeast(1)),
+			},
+		},
+		"region_id": schema.StringAttribute{
+			Description: "The region of the stacks which set no region_id.",
+			Optional:    true,
+			Validators: []validator.String{
+				stringvalidator.LengthAtLeast(1),
+			},
+		},
+		"version": schema.StringAttribute{
+			Description: "The version of the stacks created without version nor version_constraint. Existing stacks keep their version when it changes.",
+			Optional:    true,
+			Validators: []validator.String{
+				stringvalidator.LengthAtLeast(1),
+			},
+		},
+	},
+}
+
+// stackDefaults returns the stack defaults of the configuration.
+func stackDefaults(ctx context.Context, object types.Object) (internal.StackDefaults, diag.Diagnostics) {
+	if object.IsNull() || object.IsUnknown() {
+		return internal.StackDefaults{}, nil
+	}
+
+	var model DefaultsModel
+	diags := object.As(ctx, &model, basetypes.ObjectAsOptions{})
+	if diags.HasError() {
+		return internal.StackDefaults{}, diags
+	}
+
+	defaults := internal.StackDefaults{
+		RegionID: model.RegionID.ValueString(),
+		Version:  model.Version.ValueString(),
+	}
+	if !model.DefaultMetadata.IsNull() && !model.DefaultMetadata.IsUnknown() {
+		defaults.Metadata = map[string]string{}
+		diags.Append(model.DefaultMetadata.ElementsAs(ctx, &defaults.Metadata, false)...)
+	}
+	return defaults, diags
+}
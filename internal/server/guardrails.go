@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+type GuardrailsModel struct {
+	AllowedRegionIDs     types.Set    `tfsdk:"allowed_region_ids"`
+	AllowedModules       types.Set    `tfsdk:"allowed_modules"`
+	StackNamePattern     types.String `tfsdk:"stack_name_pattern"`
+	RequiredMetadataKeys types.Set    `tfsdk:"required_metadata_keys"`
+	MaxStacks            types.Int64  `tfsdk:"max_stacks"`
+}
+
+var GuardrailsSchema = schema.SingleNestedAttribute{
+	Description: "Organization-wide rules checked against the configuration of cloud_stack, cloud_stack_clone and cloud_stack_module resources, max_stacks being checked when planning the creation of stacks. Clones are checked on the region, modules and metadata of their source stack. Plans including resources which break a rule fail with a policy violation.",
+	Optional:    true,
+	Attributes: map[string]schema.Attribute{
+		"allowed_region_ids": schema.SetAttribute{
+			Description: "The regions stacks can be created in. Any region if unset.",
+			Optional:    true,
+			ElementType: types.StringType,
+			Validators: []validator.Set{
+				setvalidator.SizeAtLeast(1),
+			},
+		},
+		"allowed_modules": schema.SetAttribute{
+			Description: "The modules which can be enabled on stacks. Any module if unset.",
+			Optional:    true,
+			ElementType: types.StringType,
+			Validators: []validator.Set{
+				setvalidator.SizeAtLeast(1),
+			},
+		},
+		"stack_name_pattern": schema.StringAttribute{
+			Description: "A regular expression the names of the stacks must match, such as `^(dev|staging|prod)-[a-z0-9-]+$`.",
+			Optional:    true,
+			Validators: []validator.String{
+				stringvalidator.LengthAtLeast(1),
+			},
+		},
+		"required_metadata_keys": schema.SetAttribute{
+			Description: "The keys every stack must set in its metadata, the default_metadata of the provider included.",
+			Optional:    true,
+			ElementType: types.StringType,
+		},
+		"max_stacks": schema.Int64Attribute{
+			Description: "The maximum number of stacks of the organization, counting the stacks not managed by Terraform.",
+			Optional:    true,
+			Validators: []validator.Int64{
+				int64validator.AtLeast(1),
+			},
+		},
+	},
+}
+
+// guardrails returns the guardrails of the configuration.
+func guardrails(ctx context.Context, object types.Object) (internal.Guardrails, diag.Diagnostics) {
+	if object.IsNull() || object.IsUnknown() {
+		return internal.Guardrails{}, nil
+	}
+
+	var model GuardrailsModel
+	diags := object.As(ctx, &model, basetypes.ObjectAsOptions{})
+	if diags.HasError() {
+		return internal.Guardrails{}, diags
+	}
+
+	config := internal.Guardrails{
+		MaxStacks: int(model.MaxStacks.ValueInt64()),
+	}
+	for _, set := range []struct {
+		value  types.Set
+		target *[]string
+	}{
+		{model.AllowedRegionIDs, &config.AllowedRegionIDs},
+		{model.AllowedModules, &config.AllowedModules},
+		{model.RequiredMetadataKeys, &config.RequiredMetadataKeys},
+	} {
+		if !set.value.IsNull() && !set.value.IsUnknown() {
+			diags.Append(set.value.ElementsAs(ctx, set.target, false)...)
+		}
+	}
+	if pattern := model.StackNamePattern.ValueString(); pattern != "" {
+		var err error
+		config.StackNamePattern, err = regexp.Compile(pattern)
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("guardrails").AtName("stack_name_pattern"),
+				"Invalid stack_name_pattern Configuration",
+				fmt.Sprintf("The pattern is not a valid regular expression: %s", err),
+			)
+		}
+	}
+	return config, diags
+}
@@ -3,12 +3,12 @@ package server
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/formancehq/go-libs/v3/logging"
 	"github.com/formancehq/go-libs/v3/otlp"
 	"github.com/formancehq/go-libs/v3/service"
 	"github.com/formancehq/terraform-provider-cloud/pkg"
-	membershipclient "github.com/formancehq/terraform-provider-cloud/pkg/membership_client"
 	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/retry"
 	speakeasyretry "github.com/formancehq/terraform-provider-cloud/pkg/speakeasy_retry"
 	"github.com/spf13/pflag"
@@ -20,12 +20,31 @@ const (
 	FormanceCloudClientSecretKey = "formance-cloud-client-secret"
 	FormanceCloudClientIdKey     = "formance-cloud-client-id"
 	FormanceCloudEndpointKey     = "formance-cloud-api-endpoint"
+
+	FormanceCloudPrivateKeyKey          = "formance-cloud-private-key"
+	FormanceCloudPrivateKeyIdKey        = "formance-cloud-private-key-id"
+	FormanceCloudPrivateKeyAlgorithmKey = "formance-cloud-private-key-algorithm"
+	FormanceCloudClientAssertionKey     = "formance-cloud-client-assertion"
+	FormanceCloudClientAssertionFileKey = "formance-cloud-client-assertion-file"
+
+	FormanceCloudTokenCacheKey              = "formance-cloud-token-cache"
+	FormanceCloudTokenCacheEncryptionKeyKey = "formance-cloud-token-cache-encryption-key"
+
+	FormanceCloudWorkspaceIdKey = "formance-cloud-workspace-id"
 )
 
 func AddFlags(flagset *pflag.FlagSet) {
 	flagset.String(FormanceCloudClientSecretKey, "", "User Client Secret for Formance Cloud")
 	flagset.String(FormanceCloudClientIdKey, "", "User ID for Formance Cloud")
 	flagset.String(FormanceCloudEndpointKey, "https://app.formance.cloud/api", "Endpoint for Formance Cloud")
+	flagset.String(FormanceCloudPrivateKeyKey, "", "PEM encoded private key used to sign client assertions instead of using a client secret")
+	flagset.String(FormanceCloudPrivateKeyIdKey, "", "Key ID of the private key")
+	flagset.String(FormanceCloudPrivateKeyAlgorithmKey, "", "Algorithm used to sign client assertions, defaults to "+pkg.DefaultClientAssertionAlgorithm)
+	flagset.String(FormanceCloudClientAssertionKey, "", "Pre-issued client assertion used instead of a client secret")
+	flagset.String(FormanceCloudClientAssertionFileKey, "", "Path of a file containing a pre-issued client assertion")
+	flagset.Bool(FormanceCloudTokenCacheKey, false, "Cache access tokens under ~/.formance to reuse them across provider invocations")
+	flagset.String(FormanceCloudTokenCacheEncryptionKeyKey, "", "Key used to encrypt the token cache, tokens are stored in clear if empty")
+	flagset.String(FormanceCloudWorkspaceIdKey, "", "ID of the Terraform workspace owning the stacks managed by the provider")
 	speakeasyretry.AddFlags(flagset)
 }
 
@@ -42,6 +61,7 @@ func NewProvider(
 	transport http.RoundTripper,
 	sdkFactory pkg.CloudFactory,
 	tokenFactory pkg.TokenProviderFactory,
+	opts ...ProviderOption,
 ) ProviderFactory {
 	return New(
 		tracer,
@@ -52,14 +72,57 @@ func NewProvider(
 		transport,
 		sdkFactory,
 		tokenFactory,
+		opts...,
 	)
 }
 
+// newCachedTokenProviderFactory falls back to uncached token providers if the cache cannot be created,
+// the cache is an optimization and must not prevent the provider from running.
+func newCachedTokenProviderFactory(logger logging.Logger, encryptionKey string) pkg.TokenProviderFactory {
+	dir, err := pkg.DefaultTokenCacheDir()
+	if err != nil {
+		logger.Errorf("Unable to locate the token cache, disabling it: %s", err)
+		return pkg.NewTokenProvider
+	}
+
+	cache, err := pkg.NewFileTokenCache(dir, encryptionKey)
+	if err != nil {
+		logger.Errorf("Unable to create the token cache, disabling it: %s", err)
+		return pkg.NewTokenProvider
+	}
+
+	logger.Debugf("Caching tokens in %s", dir)
+	return pkg.NewCachedTokenProviderFactory(cache)
+}
+
+// newRetryConfig converts the retry flags, the provider retries in its transport rather than in the SDK.
+func newRetryConfig(config *retry.Config) *pkg.RetryConfig {
+	if config == nil || config.Backoff == nil {
+		return nil
+	}
+	return &pkg.RetryConfig{
+		InitialInterval: time.Duration(config.Backoff.InitialInterval) * time.Millisecond,
+		MaxInterval:     time.Duration(config.Backoff.MaxInterval) * time.Millisecond,
+		MaxElapsedTime:  time.Duration(config.Backoff.MaxElapsedTime) * time.Millisecond,
+		Exponent:        config.Backoff.Exponent,
+		StatusCodes:     pkg.DefaultRetryStatusCodes,
+	}
+}
+
 func NewModule(ctx context.Context, flagset *pflag.FlagSet) fx.Option {
 	clientId, _ := flagset.GetString(FormanceCloudClientIdKey)
 	clientSecret, _ := flagset.GetString(FormanceCloudClientSecretKey)
 	endpoint, _ := flagset.GetString(FormanceCloudEndpointKey)
+	clientAssertion := pkg.ClientAssertion{}
+	clientAssertion.PrivateKey, _ = flagset.GetString(FormanceCloudPrivateKeyKey)
+	clientAssertion.KeyID, _ = flagset.GetString(FormanceCloudPrivateKeyIdKey)
+	clientAssertion.Algorithm, _ = flagset.GetString(FormanceCloudPrivateKeyAlgorithmKey)
+	clientAssertion.Assertion, _ = flagset.GetString(FormanceCloudClientAssertionKey)
+	clientAssertion.AssertionFile, _ = flagset.GetString(FormanceCloudClientAssertionFileKey)
 	debug, _ := flagset.GetBool(service.DebugFlag)
+	tokenCache, _ := flagset.GetBool(FormanceCloudTokenCacheKey)
+	tokenCacheEncryptionKey, _ := flagset.GetString(FormanceCloudTokenCacheEncryptionKeyKey)
+	workspaceID, _ := flagset.GetString(FormanceCloudWorkspaceIdKey)
 	transport := otlp.NewRoundTripper(http.DefaultTransport, debug)
 	return fx.Options(
 		fx.Supply(FormanceCloudClientId(clientId)),
@@ -67,19 +130,35 @@ func NewModule(ctx context.Context, flagset *pflag.FlagSet) fx.Option {
 		fx.Supply(FormanceCloudEndpoint(endpoint)),
 		fx.Supply(fx.Annotate(transport, fx.As(new(http.RoundTripper)))),
 		speakeasyretry.NewModule(flagset),
-		fx.Provide(func() pkg.TokenProviderFactory {
-			return pkg.NewTokenProvider
-		}),
-		fx.Provide(func(retry *retry.Config) pkg.CloudFactory {
-			opts := []membershipclient.SDKOption{}
-			if retry != nil {
-				opts = append(opts, membershipclient.WithRetryConfig(*retry))
+		fx.Provide(func(logger logging.Logger) pkg.TokenProviderFactory {
+			if !tokenCache {
+				return pkg.NewTokenProvider
 			}
-			return pkg.NewCloudSDK(
-				opts...,
+			return newCachedTokenProviderFactory(logger, tokenCacheEncryptionKey)
+		}),
+		fx.Provide(func() pkg.CloudFactory {
+			return pkg.NewCloudSDK()
+		}),
+		fx.Provide(func(
+			tracer trace.TracerProvider,
+			logger logging.Logger,
+			endpoint FormanceCloudEndpoint,
+			clientId FormanceCloudClientId,
+			clientSecret FormanceCloudClientSecret,
+			transport http.RoundTripper,
+			sdkFactory pkg.CloudFactory,
+			tokenFactory pkg.TokenProviderFactory,
+			retry *retry.Config,
+		) ProviderFactory {
+			return NewProvider(tracer, logger, endpoint, clientId, clientSecret, transport, sdkFactory, tokenFactory,
+				WithClientAssertion(clientAssertion),
+				WithRetryConfig(newRetryConfig(retry)),
+				WithWorkspaceID(workspaceID),
+				WithTransportWrapper(func(rt http.RoundTripper) http.RoundTripper {
+					return otlp.NewRoundTripper(rt, debug)
+				}),
 			)
 		}),
-		fx.Provide(NewProvider),
 		fx.Provide(NewAPI),
 		fx.Invoke(func(lc fx.Lifecycle, server *API, shutdowner fx.Shutdowner) {
 			lc.Append(fx.Hook{
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// reservedHeaders are set by the provider and cannot be overridden by the headers attribute.
+var reservedHeaders = []string{"Authorization", "Content-Type", "Content-Length", "Host"}
+
+// networkConfig returns the network configuration of the provider block.
+func networkConfig(ctx context.Context, data *FormanceCloudProviderModel) (pkg.NetworkConfig, diag.Diagnostics) {
+	config := pkg.NetworkConfig{
+		Proxy:              data.HttpProxy.ValueString(),
+		NoProxy:            data.NoProxy.ValueString(),
+		CABundle:           data.CaBundle.ValueString(),
+		ClientCertificate:  data.ClientCertificate.ValueString(),
+		ClientKey:          data.ClientKey.ValueString(),
+		InsecureSkipVerify: data.InsecureSkipVerify.ValueBool(),
+	}
+
+	var diags diag.Diagnostics
+	if !data.Headers.IsNull() {
+		config.Headers = make(map[string]string, len(data.Headers.Elements()))
+		diags.Append(data.Headers.ElementsAs(ctx, &config.Headers, false)...)
+	}
+	return config, diags
+}
+
+// WithTransportWrapper sets the function wrapping the transports created for the network attributes,
+// so that they are instrumented like the default transport.
+func WithTransportWrapper(wrap func(http.RoundTripper) http.RoundTripper) ProviderOption {
+	return func(p *FormanceCloudProvider) {
+		p.wrapTransport = wrap
+	}
+}
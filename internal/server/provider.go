@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/formancehq/go-libs/v3/collectionutils"
 	"github.com/formancehq/go-libs/v3/logging"
@@ -12,23 +13,58 @@ import (
 	"github.com/formancehq/terraform-provider-cloud/internal/datasources"
 	"github.com/formancehq/terraform-provider-cloud/internal/resources"
 	"github.com/formancehq/terraform-provider-cloud/pkg"
+	membershipclient "github.com/formancehq/terraform-provider-cloud/pkg/membership_client"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/retry"
+	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/providervalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	providerType = "cloud"
+	providerType = internal.ProviderTypeName
 )
 
 type FormanceCloudProviderModel struct {
 	ClientId     types.String `tfsdk:"client_id"`
 	ClientSecret types.String `tfsdk:"client_secret"`
 	Endpoint     types.String `tfsdk:"endpoint"`
+
+	PrivateKey          types.String `tfsdk:"private_key"`
+	PrivateKeyId        types.String `tfsdk:"private_key_id"`
+	PrivateKeyAlgorithm types.String `tfsdk:"private_key_algorithm"`
+	ClientAssertion     types.String `tfsdk:"client_assertion"`
+	ClientAssertionFile types.String `tfsdk:"client_assertion_file"`
+
+	CredentialsFile   types.String `tfsdk:"credentials_file"`
+	Profile           types.String `tfsdk:"profile"`
+	CredentialProcess types.String `tfsdk:"credential_process"`
+
+	Scopes types.Set `tfsdk:"scopes"`
+
+	Retry          types.Object `tfsdk:"retry"`
+	RequestTimeout types.String `tfsdk:"request_timeout"`
+	RateLimit      types.Object `tfsdk:"rate_limit"`
+
+	HttpProxy          types.String `tfsdk:"http_proxy"`
+	NoProxy            types.String `tfsdk:"no_proxy"`
+	CaBundle           types.String `tfsdk:"ca_bundle"`
+	ClientCertificate  types.String `tfsdk:"client_certificate"`
+	ClientKey          types.String `tfsdk:"client_key"`
+	InsecureSkipVerify types.Bool   `tfsdk:"insecure_skip_verify"`
+	Headers            types.Map    `tfsdk:"headers"`
+
+	WorkspaceId types.String `tfsdk:"workspace_id"`
+	Defaults    types.Object `tfsdk:"defaults"`
+	Guardrails  types.Object `tfsdk:"guardrails"`
 }
 
 type ProviderModelAdapter struct {
@@ -51,6 +87,21 @@ func (f *ProviderModelAdapter) Endpoint() string {
 	return f.m.Endpoint.ValueString()
 }
 
+// ClientAssertion satisfies pkg.ClientAssertionCreds, it returns nil when the client secret is used.
+func (f *ProviderModelAdapter) ClientAssertion() *pkg.ClientAssertion {
+	assertion := &pkg.ClientAssertion{
+		PrivateKey:    f.m.PrivateKey.ValueString(),
+		KeyID:         f.m.PrivateKeyId.ValueString(),
+		Algorithm:     f.m.PrivateKeyAlgorithm.ValueString(),
+		Assertion:     f.m.ClientAssertion.ValueString(),
+		AssertionFile: f.m.ClientAssertionFile.ValueString(),
+	}
+	if assertion.IsZero() {
+		return nil
+	}
+	return assertion
+}
+
 func (f *ProviderModelAdapter) UserAgent() string {
 	return fmt.Sprintf("terraform-provider-cloud/%s", internal.Version)
 }
@@ -60,6 +111,7 @@ type FormanceCloudProvider struct {
 	logger logging.Logger
 
 	transport            http.RoundTripper
+	wrapTransport        func(http.RoundTripper) http.RoundTripper
 	sdkFactory           pkg.CloudFactory
 	tokenProviderFactory pkg.TokenProviderFactory
 
@@ -67,6 +119,36 @@ type FormanceCloudProvider struct {
 
 	ClientId     string
 	ClientSecret string
+
+	ClientAssertion pkg.ClientAssertion
+
+	RetryConfig *pkg.RetryConfig
+
+	WorkspaceID string
+}
+
+// ProviderOption configures the defaults of the provider, usually set from flags and environment variables.
+type ProviderOption func(*FormanceCloudProvider)
+
+// WithClientAssertion sets the default client assertion, used when the configuration sets no client secret.
+func WithClientAssertion(assertion pkg.ClientAssertion) ProviderOption {
+	return func(p *FormanceCloudProvider) {
+		p.ClientAssertion = assertion
+	}
+}
+
+// WithRetryConfig sets the default retries, a nil config disables them unless the configuration sets a retry block.
+func WithRetryConfig(config *pkg.RetryConfig) ProviderOption {
+	return func(p *FormanceCloudProvider) {
+		p.RetryConfig = config
+	}
+}
+
+// WithWorkspaceID sets the default workspace owning the stacks, used when the configuration sets no workspace_id.
+func WithWorkspaceID(workspaceID string) ProviderOption {
+	return func(p *FormanceCloudProvider) {
+		p.WorkspaceID = workspaceID
+	}
 }
 
 var Schema = schema.Schema{
@@ -85,6 +167,96 @@ var Schema = schema.Schema{
 			Description: "The endpoint URL for the Formance Cloud API. Defaults to the production endpoint. Can also be set via the FORMANCE_CLOUD_API_ENDPOINT environment variable.",
 			Optional:    true,
 		},
+		"private_key": schema.StringAttribute{
+			Description: "The PEM encoded private key used to sign client assertions (private_key_jwt) instead of using a client secret. Can also be set via the FORMANCE_CLOUD_PRIVATE_KEY environment variable.",
+			Optional:    true,
+			Sensitive:   true,
+		},
+		"private_key_id": schema.StringAttribute{
+			Description: "The key ID set in the header of signed client assertions. Can also be set via the FORMANCE_CLOUD_PRIVATE_KEY_ID environment variable.",
+			Optional:    true,
+		},
+		"private_key_algorithm": schema.StringAttribute{
+			Description: fmt.Sprintf("The algorithm used to sign client assertions, one of %s. Defaults to %s. Can also be set via the FORMANCE_CLOUD_PRIVATE_KEY_ALGORITHM environment variable.", strings.Join(pkg.ClientAssertionAlgorithms, ", "), pkg.DefaultClientAssertionAlgorithm),
+			Optional:    true,
+			Validators: []validator.String{
+				stringvalidator.OneOf(pkg.ClientAssertionAlgorithms...),
+			},
+		},
+		"client_assertion": schema.StringAttribute{
+			Description: "A pre-issued client assertion, for example a token of a CI OIDC federation. Can also be set via the FORMANCE_CLOUD_CLIENT_ASSERTION environment variable.",
+			Optional:    true,
+			Sensitive:   true,
+		},
+		"client_assertion_file": schema.StringAttribute{
+			Description: "The path of a file containing a pre-issued client assertion, read on each token request. Can also be set via the FORMANCE_CLOUD_CLIENT_ASSERTION_FILE environment variable.",
+			Optional:    true,
+		},
+		"credentials_file": schema.StringAttribute{
+			Description: "The path of a credentials file with named profiles, following the fctl configuration layout. Defaults to ~/.formance/fctl.config when a profile is set.",
+			Optional:    true,
+		},
+		"profile": schema.StringAttribute{
+			Description: "The profile of the credentials file to use. Defaults to the current profile of the file.",
+			Optional:    true,
+		},
+		"scopes": schema.SetAttribute{
+			Description: "The OAuth scopes requested for the access token. Defaults to every scope used by the provider, set a smaller list for least-privilege credentials, for example read-only ones used to plan.",
+			Optional:    true,
+			ElementType: types.StringType,
+			Validators: []validator.Set{
+				setvalidator.SizeAtLeast(1),
+			},
+		},
+		"credential_process": schema.StringAttribute{
+			Description: "A command printing the credentials as a JSON object with client_id, client_secret and endpoint on its standard output. Takes precedence over the credentials file for the fields it prints, attributes set in the configuration take precedence over both.",
+			Optional:    true,
+		},
+		"retry":      RetrySchema,
+		"rate_limit": RateLimitSchema,
+		"defaults":   DefaultsSchema,
+		"guardrails": GuardrailsSchema,
+		"http_proxy": schema.StringAttribute{
+			Description: "The URL of the proxy used to reach the Formance Cloud API and its token endpoint. Defaults to the HTTPS_PROXY and HTTP_PROXY environment variables.",
+			Optional:    true,
+		},
+		"no_proxy": schema.StringAttribute{
+			Description: "A comma separated list of hosts, domains and CIDRs reached without proxy. Defaults to the NO_PROXY environment variable.",
+			Optional:    true,
+		},
+		"ca_bundle": schema.StringAttribute{
+			Description: "PEM encoded certificates, or the path of a file containing them, trusted in addition to the system ones, for example the CA of a self-hosted control plane.",
+			Optional:    true,
+		},
+		"client_certificate": schema.StringAttribute{
+			Description: "The PEM encoded client certificate, or the path of a file containing it, presented for mutual TLS. Requires client_key.",
+			Optional:    true,
+		},
+		"client_key": schema.StringAttribute{
+			Description: "The PEM encoded private key of the client certificate, or the path of a file containing it. Requires client_certificate.",
+			Optional:    true,
+			Sensitive:   true,
+		},
+		"insecure_skip_verify": schema.BoolAttribute{
+			Description: "Skip the verification of the certificates of the servers. Only meant for development environments.",
+			Optional:    true,
+		},
+		"headers": schema.MapAttribute{
+			Description: "Additional headers sent with every request, for example to go through an API gateway. The " + strings.Join(reservedHeaders, ", ") + " headers cannot be set.",
+			Optional:    true,
+			ElementType: types.StringType,
+			Validators: []validator.Map{
+				mapvalidator.KeysAre(stringvalidator.NoneOfCaseInsensitive(reservedHeaders...)),
+			},
+		},
+		"request_timeout": schema.StringAttribute{
+			Description: "The timeout of each attempt of a request sent to the Formance Cloud API, as a duration such as 30s. Requests are not bounded by default.",
+			Optional:    true,
+		},
+		"workspace_id": schema.StringAttribute{
+			Description: "Identifies the Terraform workspace managing the stacks, such as `platform/production`. Stacks created or adopted by the provider are marked with it, and the provider refuses to update or destroy stacks marked by another workspace. Can also be set via the FORMANCE_CLOUD_WORKSPACE_ID environment variable.",
+			Optional:    true,
+		},
 	},
 }
 
@@ -106,6 +278,22 @@ func (p *FormanceCloudProvider) Configure(ctx context.Context, req provider.Conf
 
 	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
 
+	if hasExternalCredentials(&data) {
+		credentials, err := loadExternalCredentials(ctx, &data)
+		if err != nil {
+			resp.Diagnostics.AddError("Unable to Load Credentials", err.Error())
+			return
+		}
+		data.ClientId = stringDefault(data.ClientId, credentials.ClientID)
+		data.ClientSecret = stringDefault(data.ClientSecret, credentials.ClientSecret)
+		data.Endpoint = stringDefault(data.Endpoint, credentials.Endpoint)
+	}
+
+	// Environment client assertions are not mixed with a client secret set in the configuration
+	if data.ClientSecret.IsNull() {
+		p.defaultClientAssertion(&data)
+	}
+
 	if data.ClientId.ValueString() == "" {
 		if p.ClientId != "" {
 			data.ClientId = types.StringValue(p.ClientId)
@@ -122,15 +310,141 @@ func (p *FormanceCloudProvider) Configure(ctx context.Context, req provider.Conf
 		data.Endpoint = types.StringValue(p.Endpoint)
 	}
 
+	scopes := pkg.ScopeCloud
+	if !data.Scopes.IsNull() {
+		scopes = make([]string, 0, len(data.Scopes.Elements()))
+		resp.Diagnostics.Append(data.Scopes.ElementsAs(ctx, &scopes, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	retries, diags := retryConfig(ctx, data.Retry, p.RetryConfig)
+	resp.Diagnostics.Append(diags...)
+	var requestTimeout time.Duration
+	if data.RequestTimeout.ValueString() != "" {
+		var err error
+		requestTimeout, err = parseDuration(data.RequestTimeout)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("request_timeout"), "Invalid request_timeout Configuration", err.Error())
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	rateLimit, diags := rateLimitConfig(ctx, data.RateLimit)
+	resp.Diagnostics.Append(diags...)
+
+	defaults, diags := stackDefaults(ctx, data.Defaults)
+	resp.Diagnostics.Append(diags...)
+
+	rules, diags := guardrails(ctx, data.Guardrails)
+	resp.Diagnostics.Append(diags...)
+
+	network, diags := networkConfig(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if network.InsecureSkipVerify {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("insecure_skip_verify"),
+			"Insecure Connections",
+			"The certificates of the Formance Cloud API are not verified, this setting must only be used in development environments.",
+		)
+	}
+	transport, err := pkg.NewNetworkTransport(p.transport, p.wrapTransport, network)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid Network Configuration", err.Error())
+		return
+	}
+
+	// The timeout bounds the network round trips only, waiting for the rate limiter does not count against it
+	transport = pkg.NewTimeoutTransport(transport, requestTimeout)
+
 	creds := NewProviderModelAdapter(&data)
-	tp := p.tokenProviderFactory(p.transport, creds, pkg.ScopeCloud)
-	cli := p.sdkFactory(creds.Endpoint(), pkg.NewTransport(p.transport, tp))
+	tp := p.tokenProviderFactory(transport, creds, scopes)
+	// Retries are done by the transport, with the status codes of the configuration, so the SDK must not retry too
+	cli := p.sdkFactory(
+		creds.Endpoint(),
+		pkg.NewRetryTransport(pkg.NewTransport(pkg.NewRateLimitTransport(transport, rateLimit), tp), retries),
+		membershipclient.WithRetryConfig(retry.Config{Strategy: "none"}),
+	)
 
 	store := internal.NewStore(cli, tp)
+	store.SetWorkspaceID(stringDefault(data.WorkspaceId, p.WorkspaceID).ValueString())
+	store.SetStackDefaults(defaults)
+	store.SetGuardrails(rules)
 	resp.ResourceData = store
 	resp.DataSourceData = store
 }
 
+// hasExternalCredentials reports whether the configuration loads credentials from a file or a process.
+func hasExternalCredentials(data *FormanceCloudProviderModel) bool {
+	return !data.CredentialsFile.IsNull() || !data.Profile.IsNull() || !data.CredentialProcess.IsNull()
+}
+
+func loadExternalCredentials(ctx context.Context, data *FormanceCloudProviderModel) (*pkg.ExternalCredentials, error) {
+	credentials := &pkg.ExternalCredentials{}
+	if !data.CredentialsFile.IsNull() || !data.Profile.IsNull() {
+		file := data.CredentialsFile.ValueString()
+		if file == "" {
+			var err error
+			file, err = pkg.DefaultCredentialsFile()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		credentials, err = pkg.LoadProfile(ctx, file, data.Profile.ValueString())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if command := data.CredentialProcess.ValueString(); command != "" {
+		process, err := pkg.RunCredentialProcess(ctx, command)
+		if err != nil {
+			return nil, err
+		}
+		if process.ClientSecret != "" {
+			credentials.ClientSecret = process.ClientSecret
+		}
+		if process.ClientID != "" {
+			credentials.ClientID = process.ClientID
+		}
+		if process.Endpoint != "" {
+			credentials.Endpoint = process.Endpoint
+		}
+	}
+
+	return credentials, nil
+}
+
+func (p *FormanceCloudProvider) defaultClientAssertion(data *FormanceCloudProviderModel) {
+	if !p.hasClientAssertion(data) {
+		data.PrivateKey = stringDefault(data.PrivateKey, p.ClientAssertion.PrivateKey)
+		data.ClientAssertion = stringDefault(data.ClientAssertion, p.ClientAssertion.Assertion)
+		data.ClientAssertionFile = stringDefault(data.ClientAssertionFile, p.ClientAssertion.AssertionFile)
+	}
+	data.PrivateKeyId = stringDefault(data.PrivateKeyId, p.ClientAssertion.KeyID)
+	data.PrivateKeyAlgorithm = stringDefault(data.PrivateKeyAlgorithm, p.ClientAssertion.Algorithm)
+}
+
+// hasClientAssertion reports whether the configuration sets a client assertion.
+func (p *FormanceCloudProvider) hasClientAssertion(data *FormanceCloudProviderModel) bool {
+	return !data.PrivateKey.IsNull() || !data.ClientAssertion.IsNull() || !data.ClientAssertionFile.IsNull()
+}
+
+func stringDefault(value types.String, def string) types.String {
+	if value.ValueString() == "" && def != "" {
+		return types.StringValue(def)
+	}
+	return value
+}
+
 // DataSources satisfies the provider.Provider interface for FormanceCloudProvider.
 func (p *FormanceCloudProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
 	d := []func() datasource.DataSource{
@@ -138,6 +452,7 @@ func (p *FormanceCloudProvider) DataSources(ctx context.Context) []func() dataso
 		datasources.NewRegions(),
 		datasources.NewStacks(),
 		datasources.NewRegionVersions(),
+		datasources.NewStackUpgradePath(),
 	}
 	return collectionutils.Map(d, func(d func() datasource.DataSource) func() datasource.DataSource {
 		return func() datasource.DataSource {
@@ -152,6 +467,8 @@ func (p *FormanceCloudProvider) Resources(ctx context.Context) []func() resource
 		resources.NewStack(),
 		resources.NewStackModule(),
 		resources.NewStackMember(),
+		resources.NewStackRollout(),
+		resources.NewStackClone(),
 		resources.NewOrganizationMember(),
 		resources.NewNoop(),
 	}
@@ -161,7 +478,18 @@ func (p *FormanceCloudProvider) Resources(ctx context.Context) []func() resource
 }
 
 func (p FormanceCloudProvider) ConfigValidators(ctx context.Context) []provider.ConfigValidator {
-	return []provider.ConfigValidator{}
+	return []provider.ConfigValidator{
+		providervalidator.Conflicting(
+			path.MatchRoot("client_secret"),
+			path.MatchRoot("private_key"),
+			path.MatchRoot("client_assertion"),
+			path.MatchRoot("client_assertion_file"),
+		),
+		providervalidator.RequiredTogether(
+			path.MatchRoot("client_certificate"),
+			path.MatchRoot("client_key"),
+		),
+	}
 }
 
 func (p FormanceCloudProvider) ValidateConfig(ctx context.Context, req provider.ValidateConfigRequest, resp *provider.ValidateConfigResponse) {
@@ -169,8 +497,11 @@ func (p FormanceCloudProvider) ValidateConfig(ctx context.Context, req provider.
 
 	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
 
+	// Credentials loaded from a file or a process are only known when configuring the provider
+	external := hasExternalCredentials(&data)
+
 	var clientID string
-	if data.ClientId.IsNull() {
+	if data.ClientId.IsNull() && !external {
 		if p.ClientId != "" {
 			clientID = p.ClientId
 			resp.Diagnostics.AddAttributeWarning(
@@ -200,7 +531,34 @@ func (p FormanceCloudProvider) ValidateConfig(ctx context.Context, req provider.
 		)
 	}
 
-	if data.ClientSecret.IsNull() {
+	if !data.PrivateKey.IsUnknown() && !data.PrivateKeyAlgorithm.IsUnknown() {
+		assertion := &pkg.ClientAssertion{
+			PrivateKey: data.PrivateKey.ValueString(),
+			Algorithm:  data.PrivateKeyAlgorithm.ValueString(),
+		}
+		if err := assertion.Validate(); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("private_key"),
+				"Invalid private_key Configuration",
+				fmt.Sprintf("The private key cannot be used to sign client assertions: %s", err),
+			)
+		}
+	}
+
+	if _, diags := retryConfig(ctx, data.Retry, nil); diags.HasError() {
+		resp.Diagnostics.Append(diags...)
+	}
+	if _, diags := guardrails(ctx, data.Guardrails); diags.HasError() {
+		resp.Diagnostics.Append(diags...)
+	}
+	if !data.RequestTimeout.IsUnknown() && data.RequestTimeout.ValueString() != "" {
+		if _, err := parseDuration(data.RequestTimeout); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("request_timeout"), "Invalid request_timeout Configuration", err.Error())
+		}
+	}
+
+	// Client assertions replace the client secret
+	if data.ClientSecret.IsNull() && !external && !p.hasClientAssertion(&data) && p.ClientAssertion.IsZero() {
 		if p.ClientSecret != "" {
 			resp.Diagnostics.AddAttributeWarning(
 				path.Root("client_secret"),
@@ -214,12 +572,12 @@ func (p FormanceCloudProvider) ValidateConfig(ctx context.Context, req provider.
 				"Missing client_secret Configuration",
 				"While configuring the provider, the API token was not found in "+
 					"the FORMANCE_CLOUD_CLIENT_SECRET environment variable or provider "+
-					"configuration block api_token attribute.",
+					"configuration block api_token attribute, and no client assertion is configured.",
 			)
 		}
 	}
 
-	if data.Endpoint.IsNull() {
+	if data.Endpoint.IsNull() && !external {
 		if p.Endpoint != "" {
 			resp.Diagnostics.AddAttributeWarning(
 				path.Root("endpoint"),
@@ -248,9 +606,10 @@ func New(
 	transport http.RoundTripper,
 	sdkFactory pkg.CloudFactory,
 	tokenProvider pkg.TokenProviderFactory,
+	opts ...ProviderOption,
 ) func() provider.Provider {
 	return func() provider.Provider {
-		return &FormanceCloudProvider{
+		p := &FormanceCloudProvider{
 			tracer:               tracer.Tracer("github.com/formancehq/terraform-provider-cloud"),
 			logger:               logger.WithField("provider", providerType),
 			ClientId:             clientId,
@@ -259,7 +618,14 @@ func New(
 			Endpoint:             endpoint,
 			sdkFactory:           sdkFactory,
 			tokenProviderFactory: tokenProvider,
+			wrapTransport: func(rt http.RoundTripper) http.RoundTripper {
+				return rt
+			},
+		}
+		for _, opt := range opts {
+			opt(p)
 		}
+		return p
 	}
 }
 
@@ -2,10 +2,14 @@ package server_test
 
 import (
 	"fmt"
+	"maps"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/go-libs/v3/pointer"
 	"github.com/formancehq/terraform-provider-cloud/internal/server"
 	"github.com/formancehq/terraform-provider-cloud/pkg"
 	"github.com/formancehq/terraform-provider-cloud/pkg/testprovider"
@@ -65,6 +69,29 @@ func TestProviderConfigure(t *testing.T) {
 						"client_id":     tftypes.NewValue(tftypes.String, tc.ClientId),
 						"client_secret": tftypes.NewValue(tftypes.String, tc.ClientSecret),
 						"endpoint":      tftypes.NewValue(tftypes.String, tc.Endpoint),
+
+						"private_key":           tftypes.NewValue(tftypes.String, nil),
+						"private_key_id":        tftypes.NewValue(tftypes.String, nil),
+						"private_key_algorithm": tftypes.NewValue(tftypes.String, nil),
+						"client_assertion":      tftypes.NewValue(tftypes.String, nil),
+						"client_assertion_file": tftypes.NewValue(tftypes.String, nil),
+						"credentials_file":      tftypes.NewValue(tftypes.String, nil),
+						"profile":               tftypes.NewValue(tftypes.String, nil),
+						"credential_process":    tftypes.NewValue(tftypes.String, nil),
+						"scopes":                tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, nil),
+						"retry":                 tftypes.NewValue(getSchemaTypes(server.Schema)["retry"], nil),
+						"request_timeout":       tftypes.NewValue(tftypes.String, nil),
+						"rate_limit":            tftypes.NewValue(getSchemaTypes(server.Schema)["rate_limit"], nil),
+						"defaults":              tftypes.NewValue(getSchemaTypes(server.Schema)["defaults"], nil),
+						"guardrails":            tftypes.NewValue(getSchemaTypes(server.Schema)["guardrails"], nil),
+						"http_proxy":            tftypes.NewValue(tftypes.String, nil),
+						"no_proxy":              tftypes.NewValue(tftypes.String, nil),
+						"ca_bundle":             tftypes.NewValue(tftypes.String, nil),
+						"client_certificate":    tftypes.NewValue(tftypes.String, nil),
+						"client_key":            tftypes.NewValue(tftypes.String, nil),
+						"insecure_skip_verify":  tftypes.NewValue(tftypes.Bool, nil),
+						"headers":               tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+						"workspace_id":          tftypes.NewValue(tftypes.String, nil),
 					}),
 					Schema: server.Schema,
 				},
@@ -89,3 +116,253 @@ func TestProviderConfigure(t *testing.T) {
 	}
 
 }
+
+func TestProviderConfigureClientAssertion(t *testing.T) {
+	type testCase struct {
+		name              string
+		clientSecret      *string
+		clientAssertion   *string
+		expectedAssertion string
+	}
+
+	for _, tc := range []testCase{
+		{
+			name:              "from the configuration",
+			clientAssertion:   pointer.For("configured"),
+			expectedAssertion: "configured",
+		},
+		{
+			name:              "from the environment",
+			expectedAssertion: "environment",
+		},
+		{
+			name:         "client secret configured",
+			clientSecret: pointer.For("secret"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			tokenFactory, mockTp := testprovider.NewMockTokenProvider(ctrl)
+			p := server.New(noop.NewTracerProvider(), logging.Testing(), "https://app.formance.cloud/api", "client_id", "", http.DefaultTransport, pkg.NewCloudSDK(), tokenFactory,
+				server.WithClientAssertion(pkg.ClientAssertion{Assertion: "environment"}),
+			)()
+
+			res := provider.ConfigureResponse{}
+			p.Configure(logging.TestingContext(), provider.ConfigureRequest{
+				Config: tfsdk.Config{
+					Raw: providerConfig(map[string]tftypes.Value{
+						"client_secret":    tftypes.NewValue(tftypes.String, tc.clientSecret),
+						"client_assertion": tftypes.NewValue(tftypes.String, tc.clientAssertion),
+					}),
+					Schema: server.Schema,
+				},
+			}, &res)
+			require.Empty(t, res.Diagnostics)
+
+			assertion := mockTp.Creds.(pkg.ClientAssertionCreds).ClientAssertion()
+			if tc.expectedAssertion == "" {
+				require.Nil(t, assertion)
+				return
+			}
+			require.Equal(t, tc.expectedAssertion, assertion.Assertion)
+		})
+	}
+}
+
+func providerConfig(overrides map[string]tftypes.Value) tftypes.Value {
+	attributeTypes := getSchemaTypes(server.Schema)
+	values := map[string]tftypes.Value{}
+	for name, attributeType := range attributeTypes {
+		values[name] = tftypes.NewValue(attributeType, nil)
+	}
+	maps.Copy(values, overrides)
+
+	return tftypes.NewValue(tftypes.Object{
+		AttributeTypes: attributeTypes,
+	}, values)
+}
+
+func TestProviderConfigureExternalCredentials(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fctl.config")
+	require.NoError(t, os.WriteFile(file, []byte(`{
+		"currentProfile": "default",
+		"profiles": {
+			"default": {"membershipURI": "https://default.formance.cloud/api", "clientId": "organization_default", "clientSecret": "default"},
+			"staging": {"membershipURI": "https://staging.formance.cloud/api", "clientId": "organization_staging", "clientSecret": "staging"}
+		}
+	}`), 0600))
+
+	type testCase struct {
+		name             string
+		config           map[string]tftypes.Value
+		expectedClientId string
+		expectedSecret   string
+		expectedEndpoint string
+	}
+
+	for _, tc := range []testCase{
+		{
+			name: "current profile",
+			config: map[string]tftypes.Value{
+				"credentials_file": tftypes.NewValue(tftypes.String, file),
+			},
+			expectedClientId: "organization_default",
+			expectedSecret:   "default",
+			expectedEndpoint: "https://default.formance.cloud/api",
+		},
+		{
+			name: "selected profile",
+			config: map[string]tftypes.Value{
+				"credentials_file": tftypes.NewValue(tftypes.String, file),
+				"profile":          tftypes.NewValue(tftypes.String, "staging"),
+			},
+			expectedClientId: "organization_staging",
+			expectedSecret:   "staging",
+			expectedEndpoint: "https://staging.formance.cloud/api",
+		},
+		{
+			name: "credential process over profile and configuration over both",
+			config: map[string]tftypes.Value{
+				"credentials_file":   tftypes.NewValue(tftypes.String, file),
+				"credential_process": tftypes.NewValue(tftypes.String, `echo '{"client_id": "organization_process", "client_secret": "process"}'`),
+				"endpoint":           tftypes.NewValue(tftypes.String, "https://configured.formance.cloud/api"),
+			},
+			expectedClientId: "organization_process",
+			expectedSecret:   "process",
+			expectedEndpoint: "https://configured.formance.cloud/api",
+		},
+		{
+			name: "credential process without client secret keeps the secret of the profile",
+			config: map[string]tftypes.Value{
+				"credentials_file":   tftypes.NewValue(tftypes.String, file),
+				"credential_process": tftypes.NewValue(tftypes.String, `echo '{"client_id": "organization_process"}'`),
+			},
+			expectedClientId: "organization_process",
+			expectedSecret:   "default",
+			expectedEndpoint: "https://default.formance.cloud/api",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			tokenFactory, mockTp := testprovider.NewMockTokenProvider(ctrl)
+			p := server.New(noop.NewTracerProvider(), logging.Testing(), "https://app.formance.cloud/api", "organization_environment", "environment", http.DefaultTransport, pkg.NewCloudSDK(), tokenFactory)()
+
+			res := provider.ConfigureResponse{}
+			p.Configure(logging.TestingContext(), provider.ConfigureRequest{
+				Config: tfsdk.Config{
+					Raw:    providerConfig(tc.config),
+					Schema: server.Schema,
+				},
+			}, &res)
+			require.Empty(t, res.Diagnostics)
+
+			require.Equal(t, tc.expectedClientId, mockTp.ClientId())
+			require.Equal(t, tc.expectedSecret, mockTp.ClientSecret())
+			require.Equal(t, tc.expectedEndpoint, mockTp.Endpoint())
+		})
+	}
+}
+
+func TestProviderValidateConfigRetry(t *testing.T) {
+	retryType := getSchemaTypes(server.Schema)["retry"].(tftypes.Object)
+	retry := func(initialInterval string) tftypes.Value {
+		values := map[string]tftypes.Value{}
+		for name, attributeType := range retryType.AttributeTypes {
+			values[name] = tftypes.NewValue(attributeType, nil)
+		}
+		values["initial_interval"] = tftypes.NewValue(tftypes.String, initialInterval)
+		return tftypes.NewValue(retryType, values)
+	}
+
+	for _, tc := range []struct {
+		name          string
+		config        map[string]tftypes.Value
+		expectedError bool
+	}{
+		{
+			name: "valid",
+			config: map[string]tftypes.Value{
+				"retry":           retry("500ms"),
+				"request_timeout": tftypes.NewValue(tftypes.String, "30s"),
+			},
+		},
+		{
+			name: "invalid retry interval",
+			config: map[string]tftypes.Value{
+				"retry": retry("soon"),
+			},
+			expectedError: true,
+		},
+		{
+			name: "invalid request timeout",
+			config: map[string]tftypes.Value{
+				"request_timeout": tftypes.NewValue(tftypes.String, "-1s"),
+			},
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			tokenFactory, _ := testprovider.NewMockTokenProvider(ctrl)
+			p := server.New(noop.NewTracerProvider(), logging.Testing(), "https://app.formance.cloud/api", "organization_id", "secret", http.DefaultTransport, pkg.NewCloudSDK(), tokenFactory)()
+
+			res := provider.ValidateConfigResponse{}
+			p.(provider.ProviderWithValidateConfig).ValidateConfig(logging.TestingContext(), provider.ValidateConfigRequest{
+				Config: tfsdk.Config{
+					Raw:    providerConfig(tc.config),
+					Schema: server.Schema,
+				},
+			}, &res)
+			require.Equal(t, tc.expectedError, res.Diagnostics.HasError(), res.Diagnostics)
+		})
+	}
+}
+
+func TestProviderValidateConfigGuardrails(t *testing.T) {
+	guardrailsType := getSchemaTypes(server.Schema)["guardrails"].(tftypes.Object)
+	guardrails := func(pattern string) tftypes.Value {
+		values := map[string]tftypes.Value{}
+		for name, attributeType := range guardrailsType.AttributeTypes {
+			values[name] = tftypes.NewValue(attributeType, nil)
+		}
+		values["stack_name_pattern"] = tftypes.NewValue(tftypes.String, pattern)
+		return tftypes.NewValue(guardrailsType, values)
+	}
+
+	for _, tc := range []struct {
+		name          string
+		pattern       string
+		expectedError bool
+	}{
+		{
+			name:    "valid pattern",
+			pattern: "^(dev|prod)-[a-z]+$",
+		},
+		{
+			name:          "invalid pattern",
+			pattern:       "^prod-(",
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			tokenFactory, _ := testprovider.NewMockTokenProvider(ctrl)
+			p := server.New(noop.NewTracerProvider(), logging.Testing(), "https://app.formance.cloud/api", "organization_id", "secret", http.DefaultTransport, pkg.NewCloudSDK(), tokenFactory)()
+
+			res := provider.ValidateConfigResponse{}
+			p.(provider.ProviderWithValidateConfig).ValidateConfig(logging.TestingContext(), provider.ValidateConfigRequest{
+				Config: tfsdk.Config{
+					Raw: providerConfig(map[string]tftypes.Value{
+						"guardrails": guardrails(tc.pattern),
+					}),
+					Schema: server.Schema,
+				},
+			}, &res)
+			require.Equal(t, tc.expectedError, res.Diagnostics.HasError(), res.Diagnostics)
+		})
+	}
+}
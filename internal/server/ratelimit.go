@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/hashicorp/terraform-plugin-framework-validators/float64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+type RateLimitModel struct {
+	RequestsPerSecond     types.Float64 `tfsdk:"requests_per_second"`
+	Burst                 types.Int64   `tfsdk:"burst"`
+	MaxConcurrentRequests types.Int64   `tfsdk:"max_concurrent_requests"`
+}
+
+var RateLimitSchema = schema.SingleNestedAttribute{
+	Description: "Client-side limits of the requests sent to the Formance Cloud API, shared by every resource and data source of the provider. Useful with a high -parallelism, to avoid being rate limited by the API.",
+	Optional:    true,
+	Attributes: map[string]schema.Attribute{
+		"requests_per_second": schema.Float64Attribute{
+			Description: "The maximum rate of requests, each attempt of a retried request counting. Unlimited if unset.",
+			Optional:    true,
+			Validators: []validator.Float64{
+				float64validator.AtLeast(0.1),
+			},
+		},
+		"burst": schema.Int64Attribute{
+			Description: "The number of requests which can be sent at once above the rate. Defaults to requests_per_second rounded up.",
+			Optional:    true,
+			Validators: []validator.Int64{
+				int64validator.AtLeast(1),
+				int64validator.AlsoRequires(path.MatchRelative().AtParent().AtName("requests_per_second")),
+			},
+		},
+		"max_concurrent_requests": schema.Int64Attribute{
+			Description: "The maximum number of requests in flight. Unlimited if unset.",
+			Optional:    true,
+			Validators: []validator.Int64{
+				int64validator.AtLeast(1),
+			},
+		},
+	},
+}
+
+// rateLimitConfig returns the rate limits of the configuration, nil if unset.
+func rateLimitConfig(ctx context.Context, object types.Object) (*pkg.RateLimitConfig, diag.Diagnostics) {
+	if object.IsNull() || object.IsUnknown() {
+		return nil, nil
+	}
+
+	var model RateLimitModel
+	diags := object.As(ctx, &model, basetypes.ObjectAsOptions{})
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	return &pkg.RateLimitConfig{
+		RequestsPerSecond:     model.RequestsPerSecond.ValueFloat64(),
+		Burst:                 int(model.Burst.ValueInt64()),
+		MaxConcurrentRequests: int(model.MaxConcurrentRequests.ValueInt64()),
+	}, diags
+}
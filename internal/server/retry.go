@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	speakeasyretry "github.com/formancehq/terraform-provider-cloud/pkg/speakeasy_retry"
+	"github.com/hashicorp/terraform-plugin-framework-validators/float64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+type RetryModel struct {
+	InitialInterval types.String  `tfsdk:"initial_interval"`
+	MaxInterval     types.String  `tfsdk:"max_interval"`
+	MaxElapsedTime  types.String  `tfsdk:"max_elapsed_time"`
+	Exponent        types.Float64 `tfsdk:"exponent"`
+	StatusCodes     types.Set     `tfsdk:"status_codes"`
+}
+
+var RetrySchema = schema.SingleNestedAttribute{
+	Description: "The retries of the requests sent to the Formance Cloud API. Idempotent requests, such as reads, updates and deletions, are retried on connection errors and on status_codes. The other requests, such as creations, may have been processed by the API and are only retried on 429 responses. Unset attributes default to the retry flags of the provider binary.",
+	Optional:    true,
+	Attributes: map[string]schema.Attribute{
+		"initial_interval": schema.StringAttribute{
+			Description: fmt.Sprintf("The backoff before the first retry, as a duration such as 500ms. Defaults to %s.", time.Duration(speakeasyretry.DefaultRetryInitialInterval)*time.Millisecond),
+			Optional:    true,
+		},
+		"max_interval": schema.StringAttribute{
+			Description: fmt.Sprintf("The maximum backoff between two retries. Defaults to %s.", time.Duration(speakeasyretry.DefaultRetryMaxInterval)*time.Millisecond),
+			Optional:    true,
+		},
+		"max_elapsed_time": schema.StringAttribute{
+			Description: fmt.Sprintf("The time after which a failing request is not retried anymore, set 0s to disable retries. Defaults to %s.", time.Duration(speakeasyretry.DefaultRetryMaxElapsedTime)*time.Millisecond),
+			Optional:    true,
+		},
+		"exponent": schema.Float64Attribute{
+			Description: fmt.Sprintf("The factor applied to the backoff after each retry. Defaults to %v.", speakeasyretry.DefaultRetryExponent),
+			Optional:    true,
+			Validators: []validator.Float64{
+				float64validator.AtLeast(1),
+			},
+		},
+		"status_codes": schema.SetAttribute{
+			Description: fmt.Sprintf("The status codes of the responses to retry. Defaults to %v. Requests which are not idempotent are only retried on 429. The Retry-After header of the responses takes precedence over the backoff.", pkg.DefaultRetryStatusCodes),
+			Optional:    true,
+			ElementType: types.Int64Type,
+			Validators: []validator.Set{
+				setvalidator.ValueInt64sAre(int64validator.Between(400, 599)),
+			},
+		},
+	},
+}
+
+// DefaultRetryConfig is the retry configuration matching the defaults of the retry flags.
+func DefaultRetryConfig() *pkg.RetryConfig {
+	return &pkg.RetryConfig{
+		InitialInterval: time.Duration(speakeasyretry.DefaultRetryInitialInterval) * time.Millisecond,
+		MaxInterval:     time.Duration(speakeasyretry.DefaultRetryMaxInterval) * time.Millisecond,
+		MaxElapsedTime:  time.Duration(speakeasyretry.DefaultRetryMaxElapsedTime) * time.Millisecond,
+		Exponent:        speakeasyretry.DefaultRetryExponent,
+		StatusCodes:     pkg.DefaultRetryStatusCodes,
+	}
+}
+
+// retryConfig merges the retry attributes of the configuration over the defaults of the provider.
+// The defaults are nil when retries are disabled by flags, setting the attributes enables them again.
+func retryConfig(ctx context.Context, object types.Object, defaults *pkg.RetryConfig) (*pkg.RetryConfig, diag.Diagnostics) {
+	if object.IsNull() || object.IsUnknown() {
+		return defaults, nil
+	}
+
+	var (
+		model RetryModel
+		diags diag.Diagnostics
+	)
+	diags.Append(object.As(ctx, &model, basetypes.ObjectAsOptions{})...)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	config := DefaultRetryConfig()
+	if defaults != nil {
+		*config = *defaults
+	}
+
+	for _, duration := range []struct {
+		attribute string
+		value     types.String
+		target    *time.Duration
+	}{
+		{"initial_interval", model.InitialInterval, &config.InitialInterval},
+		{"max_interval", model.MaxInterval, &config.MaxInterval},
+		{"max_elapsed_time", model.MaxElapsedTime, &config.MaxElapsedTime},
+	} {
+		if duration.value.ValueString() == "" {
+			continue
+		}
+		value, err := parseDuration(duration.value)
+		if err != nil {
+			diags.AddAttributeError(path.Root("retry").AtName(duration.attribute), "Invalid Retry Configuration", err.Error())
+			continue
+		}
+		*duration.target = value
+	}
+
+	if !model.Exponent.IsNull() {
+		config.Exponent = model.Exponent.ValueFloat64()
+	}
+
+	if !model.StatusCodes.IsNull() {
+		codes := make([]int64, 0, len(model.StatusCodes.Elements()))
+		diags.Append(model.StatusCodes.ElementsAs(ctx, &codes, false)...)
+		config.StatusCodes = make([]int, 0, len(codes))
+		for _, code := range codes {
+			config.StatusCodes = append(config.StatusCodes, int(code))
+		}
+	}
+
+	if diags.HasError() {
+		return nil, diags
+	}
+	return config, diags
+}
+
+func parseDuration(value types.String) (time.Duration, error) {
+	duration, err := time.ParseDuration(value.ValueString())
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid duration, use a value such as 500ms or 10s", value.ValueString())
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("%q must not be negative", value.ValueString())
+	}
+	return duration, nil
+}
@@ -2,11 +2,37 @@ package internal
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
 
+	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+
 	"github.com/formancehq/terraform-provider-cloud/pkg"
 )
 
+// StackDefaults are the provider-level defaults of the stacks.
+type StackDefaults struct {
+	// Metadata is merged into the metadata of every stack, the metadata of the stack taking precedence
+	Metadata map[string]string
+	RegionID string
+	Version  string
+}
+
+// Guardrails are the provider-level rules enforced on the stacks and their modules.
+// Empty fields enforce nothing.
+type Guardrails struct {
+	AllowedRegionIDs     []string
+	AllowedModules       []string
+	StackNamePattern     *regexp.Regexp
+	RequiredMetadataKeys []string
+	MaxStacks            int
+}
+
 // Store provides a shared storage for provider-wide data
 type Store struct {
 	sync.Mutex
@@ -14,13 +40,46 @@ type Store struct {
 
 	tp  pkg.TokenProviderImpl
 	sdk pkg.CloudSDK
+
+	// Workspace marking the stacks created or adopted by the provider, empty if not configured
+	workspaceID string
+
+	stackDefaults StackDefaults
+	guardrails    Guardrails
+
+	// Stacks in the state of a cloud_stack resource, or created or adopted by one, during the run
+	managedStacks map[string]struct{}
+
+	// Metadata of the stacks refreshed during the run, by stack ID
+	stackMetadata map[string]map[string]string
+
+	// Versions pinned by the version attribute of cloud_stack resources planned during the run, by stack ID
+	pinnedVersions map[string]string
+
+	// Stacks planned for creation during the run, counted against the max_stacks guardrail
+	plannedStacks map[string]int
+
+	// Stacks whose modules are managed by the modules attribute of cloud_stack,
+	// and stacks targeted by standalone cloud_stack_module resources
+	authoritativeModules map[string]struct{}
+	standaloneModules    map[string]struct{}
+
+	// Scopes granted to the token, nil until loaded
+	grantedScopes []string
 }
 
-// NewStore creates a new Store instance
+// NewStore creates a new Store instance. The list endpoints of the SDK are cached for the run,
+// and the mutations of a stack serialized.
 func NewStore(sdkClient pkg.CloudSDK, tp pkg.TokenProviderImpl) *Store {
 	return &Store{
-		sdk: sdkClient,
-		tp:  tp,
+		sdk:                  newSerializedSDK(newCachedSDK(sdkClient)),
+		tp:                   tp,
+		authoritativeModules: map[string]struct{}{},
+		standaloneModules:    map[string]struct{}{},
+		plannedStacks:        map[string]int{},
+		managedStacks:        map[string]struct{}{},
+		stackMetadata:        map[string]map[string]string{},
+		pinnedVersions:       map[string]string{},
 	}
 }
 
@@ -42,3 +101,189 @@ func (s *Store) GetOrganizationID(ctx context.Context) (string, error) {
 	}
 	return s.organizationID, nil
 }
+
+// SetWorkspaceID sets the Terraform workspace owning the stacks managed by the provider.
+func (s *Store) SetWorkspaceID(workspaceID string) {
+	s.Lock()
+	defer s.Unlock()
+	s.workspaceID = workspaceID
+}
+
+// GetWorkspaceID returns the Terraform workspace owning the stacks managed by the provider.
+func (s *Store) GetWorkspaceID() string {
+	s.Lock()
+	defer s.Unlock()
+	return s.workspaceID
+}
+
+// SetStackDefaults sets the defaults of the stacks configured on the provider.
+func (s *Store) SetStackDefaults(defaults StackDefaults) {
+	s.Lock()
+	defer s.Unlock()
+	s.stackDefaults = defaults
+}
+
+// GetStackDefaults returns the defaults of the stacks configured on the provider.
+func (s *Store) GetStackDefaults() StackDefaults {
+	s.Lock()
+	defer s.Unlock()
+	return s.stackDefaults
+}
+
+// SetGuardrails sets the guardrails configured on the provider.
+func (s *Store) SetGuardrails(guardrails Guardrails) {
+	s.Lock()
+	defer s.Unlock()
+	s.guardrails = guardrails
+}
+
+// GetGuardrails returns the guardrails configured on the provider.
+func (s *Store) GetGuardrails() Guardrails {
+	s.Lock()
+	defer s.Unlock()
+	return s.guardrails
+}
+
+// PlanStack records that a stack identified by key is planned for creation.
+// It returns the number of stacks planned for creation during the run, by key.
+func (s *Store) PlanStack(key string) map[string]int {
+	s.Lock()
+	defer s.Unlock()
+	s.plannedStacks[key]++
+	return maps.Clone(s.plannedStacks)
+}
+
+// UnplanStack records that a stack planned for creation was created or adopted.
+func (s *Store) UnplanStack(key string) {
+	s.Lock()
+	defer s.Unlock()
+	if s.plannedStacks[key] > 0 {
+		s.plannedStacks[key]--
+	}
+}
+
+// ClaimStack records that a cloud_stack resource manages the stack.
+// It reports false when the stack is already managed by a resource of the run.
+func (s *Store) ClaimStack(stackID string) bool {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.managedStacks[stackID]; ok {
+		return false
+	}
+	s.managedStacks[stackID] = struct{}{}
+	return true
+}
+
+// IsStackClaimed reports whether a cloud_stack resource of the run manages the stack.
+func (s *Store) IsStackClaimed(stackID string) bool {
+	s.Lock()
+	defer s.Unlock()
+	_, ok := s.managedStacks[stackID]
+	return ok
+}
+
+// SetStackMetadata records the metadata of a stack refreshed during the run.
+func (s *Store) SetStackMetadata(stackID string, metadata map[string]string) {
+	s.Lock()
+	defer s.Unlock()
+	s.stackMetadata[stackID] = maps.Clone(metadata)
+}
+
+// GetStackMetadata returns the metadata of a stack refreshed during the run.
+// It reports false when the stack was not refreshed.
+func (s *Store) GetStackMetadata(stackID string) (map[string]string, bool) {
+	s.Lock()
+	defer s.Unlock()
+	metadata, ok := s.stackMetadata[stackID]
+	return metadata, ok
+}
+
+// PinStackVersion records that a cloud_stack resource pins the version of the stack.
+func (s *Store) PinStackVersion(stackID, version string) {
+	s.Lock()
+	defer s.Unlock()
+	s.pinnedVersions[stackID] = version
+}
+
+// GetPinnedStackVersion returns the version pinned by the cloud_stack resource managing the stack.
+// It reports false when no resource planned during the run pins it.
+func (s *Store) GetPinnedStackVersion(stackID string) (string, bool) {
+	s.Lock()
+	defer s.Unlock()
+	version, ok := s.pinnedVersions[stackID]
+	return version, ok
+}
+
+// ClaimAuthoritativeModules records that the modules of the stack are managed by cloud_stack.
+// It reports whether standalone cloud_stack_module resources target the same stack.
+func (s *Store) ClaimAuthoritativeModules(stackID string) bool {
+	s.Lock()
+	defer s.Unlock()
+	s.authoritativeModules[stackID] = struct{}{}
+	_, ok := s.standaloneModules[stackID]
+	return ok
+}
+
+// ClaimStandaloneModule records that a cloud_stack_module resource targets the stack.
+// It reports whether the modules of the same stack are managed by cloud_stack.
+func (s *Store) ClaimStandaloneModule(stackID string) bool {
+	s.Lock()
+	defer s.Unlock()
+	s.standaloneModules[stackID] = struct{}{}
+	_, ok := s.authoritativeModules[stackID]
+	return ok
+}
+
+// GrantedScopes returns the scopes granted to the access token.
+// It reports false when the token does not advertise its scopes.
+func (s *Store) GrantedScopes(ctx context.Context) ([]string, bool) {
+	s.Lock()
+	defer s.Unlock()
+	if s.grantedScopes == nil {
+		token, err := s.tp.AccessToken(ctx)
+		if err != nil {
+			// Authentication errors are reported by the calls to the API
+			logging.FromContext(ctx).Debugf("Unable to get access token to check scopes: %s", err)
+			return nil, false
+		}
+		scopes, ok := token.Scopes()
+		if !ok {
+			return nil, false
+		}
+		s.grantedScopes = scopes
+	}
+	return s.grantedScopes, true
+}
+
+// CheckScopes reports the scopes needed by the resource or data source which are not granted to the token.
+func (s *Store) CheckScopes(ctx context.Context, typeName string, write bool, diags *diag.Diagnostics) {
+	required, ok := RequiredScopes[typeName]
+	if !ok {
+		return
+	}
+	granted, ok := s.GrantedScopes(ctx)
+	if !ok {
+		return
+	}
+
+	needed := slices.Clone(required.Read)
+	if write {
+		needed = append(needed, required.Write...)
+	}
+	missing := make([]string, 0)
+	for _, scope := range needed {
+		if !slices.Contains(granted, scope) && !slices.Contains(missing, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	diags.AddError(
+		"Missing Scopes",
+		fmt.Sprintf("%s requires the scopes %s, which are not granted to the client. "+
+			"Grant them to the client, and add them to the scopes attribute of the provider if it is set.",
+			typeName, strings.Join(missing, ", ")),
+	)
+}
@@ -0,0 +1,66 @@
+package internal_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"github.com/formancehq/terraform-provider-cloud/internal"
+	"github.com/formancehq/terraform-provider-cloud/pkg"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStoreCheckScopes(t *testing.T) {
+	t.Parallel()
+
+	payload, err := json.Marshal(map[string]any{
+		"scope": "organization:ReadStack organization:ReadStackUser",
+	})
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	tp := pkg.NewMockTokenProviderImpl(ctrl)
+	tp.EXPECT().AccessToken(gomock.Any()).Return(&pkg.TokenInfo{
+		AccessToken: "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig",
+	}, nil).Times(1)
+	store := internal.NewStore(pkg.NewMockCloudSDK(ctrl), tp)
+	ctx := logging.TestingContext()
+
+	// Refreshing only needs the read scopes
+	diags := diag.Diagnostics{}
+	store.CheckScopes(ctx, "cloud_stack_member", false, &diags)
+	require.Empty(t, diags)
+
+	diags = diag.Diagnostics{}
+	store.CheckScopes(ctx, "cloud_stack_member", true, &diags)
+	require.Len(t, diags, 1)
+	require.Equal(t, "Missing Scopes", diags[0].Summary())
+	require.Contains(t, diags[0].Detail(), "cloud_stack_member requires the scopes organization:UpdateStackUser, organization:DeleteStackUser")
+
+	// Stacks are listed to adopt orphans and to count them against the guardrails
+	diags = diag.Diagnostics{}
+	store.CheckScopes(ctx, "cloud_stack", false, &diags)
+	require.Len(t, diags, 1)
+	require.Contains(t, diags[0].Detail(), "organization:ListStacks")
+
+	// Resources without requirements are not checked
+	diags = diag.Diagnostics{}
+	store.CheckScopes(ctx, "cloud_noop", true, &diags)
+	require.Empty(t, diags)
+}
+
+func TestStoreCheckScopesUnknown(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	tp := pkg.NewMockTokenProviderImpl(ctrl)
+	tp.EXPECT().AccessToken(gomock.Any()).Return(&pkg.TokenInfo{AccessToken: "opaque"}, nil)
+	store := internal.NewStore(pkg.NewMockCloudSDK(ctrl), tp)
+
+	diags := diag.Diagnostics{}
+	store.CheckScopes(logging.TestingContext(), "cloud_stack", true, &diags)
+	require.Empty(t, diags)
+}
@@ -0,0 +1,167 @@
+package versions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+const (
+	opEqual          = "="
+	opNotEqual       = "!="
+	opGreater        = ">"
+	opGreaterOrEqual = ">="
+	opLess           = "<"
+	opLessOrEqual    = "<="
+	opPessimistic    = "~>"
+)
+
+var operators = []string{
+	opPessimistic,
+	opGreaterOrEqual,
+	opLessOrEqual,
+	opNotEqual,
+	opGreater,
+	opLess,
+	opEqual,
+}
+
+type clause struct {
+	op      string
+	version string
+	upper   string
+}
+
+func (c clause) check(version string) bool {
+	cmp := semver.Compare(version, c.version)
+	switch c.op {
+	case opEqual:
+		return cmp == 0
+	case opNotEqual:
+		return cmp != 0
+	case opGreater:
+		return cmp > 0
+	case opGreaterOrEqual:
+		return cmp >= 0
+	case opLess:
+		return cmp < 0
+	case opLessOrEqual:
+		return cmp <= 0
+	case opPessimistic:
+		return cmp >= 0 && semver.Compare(version, c.upper) < 0
+	}
+	return false
+}
+
+// Constraint is a set of version requirements using the Terraform syntax, e.g. "~> v2.2" or ">= v2.0, < v3.0".
+type Constraint struct {
+	raw              string
+	clauses          []clause
+	allowPrereleases bool
+}
+
+// ParseConstraint parses a comma separated list of version requirements.
+func ParseConstraint(raw string) (Constraint, error) {
+	constraint := Constraint{
+		raw: raw,
+	}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: empty requirement", raw)
+		}
+
+		op := opEqual
+		for _, candidate := range operators {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				part = strings.TrimSpace(strings.TrimPrefix(part, candidate))
+				break
+			}
+		}
+
+		version := Canonical(part)
+		if version == "" {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: %q is not a valid semantic version", raw, part)
+		}
+
+		c := clause{
+			op:      op,
+			version: version,
+		}
+		if op == opPessimistic {
+			upper, err := pessimisticUpperBound(part)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid version constraint %q: %w", raw, err)
+			}
+			c.upper = upper
+		}
+		if semver.Prerelease(version) != "" {
+			constraint.allowPrereleases = true
+		}
+		constraint.clauses = append(constraint.clauses, c)
+	}
+
+	return constraint, nil
+}
+
+// Check reports whether the version satisfies every requirement of the constraint.
+func (c Constraint) Check(version string) bool {
+	version = Canonical(version)
+	if version == "" {
+		return false
+	}
+	if semver.Prerelease(version) != "" && !c.allowPrereleases {
+		return false
+	}
+	for _, clause := range c.clauses {
+		if !clause.check(version) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// Canonical returns the canonical form of a version, accepting an optional "v" prefix.
+// It returns an empty string if the version is not a valid semantic version.
+func Canonical(version string) string {
+	version = strings.TrimSpace(version)
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return semver.Canonical(version)
+}
+
+// pessimisticUpperBound computes the exclusive upper bound of a "~>" requirement:
+// the component before the last specified one is incremented.
+func pessimisticUpperBound(version string) (string, error) {
+	core := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+	parts := strings.Split(core, ".")
+
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a valid semantic version", version)
+		}
+		numbers[i] = n
+	}
+
+	switch len(numbers) {
+	case 1, 2:
+		return fmt.Sprintf("v%d.0.0", numbers[0]+1), nil
+	case 3:
+		return fmt.Sprintf("v%d.%d.0", numbers[0], numbers[1]+1), nil
+	default:
+		return "", fmt.Errorf("%q is not a valid semantic version", version)
+	}
+}
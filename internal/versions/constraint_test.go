@@ -0,0 +1,168 @@
+package versions_test
+
+import (
+	"testing"
+
+	"github.com/formancehq/go-libs/v3/pointer"
+	"github.com/formancehq/terraform-provider-cloud/internal/versions"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConstraint(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		constraint  string
+		expectedErr bool
+	}{
+		{constraint: "~> v2.2"},
+		{constraint: ">= 2.0, < 3.0"},
+		{constraint: "v2.1.3"},
+		{constraint: "= v2.1.3-rc.1"},
+		{constraint: "", expectedErr: true},
+		{constraint: "~> latest", expectedErr: true},
+		{constraint: ">= v2.0,", expectedErr: true},
+	} {
+		t.Run(tc.constraint, func(t *testing.T) {
+			_, err := versions.ParseConstraint(tc.constraint)
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConstraintCheck(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{constraint: "~> v2.2", version: "v2.2.0", expected: true},
+		{constraint: "~> v2.2", version: "v2.9.1", expected: true},
+		{constraint: "~> v2.2", version: "v3.0.0", expected: false},
+		{constraint: "~> v2.2", version: "v2.1.9", expected: false},
+		{constraint: "~> v2.2.1", version: "v2.2.5", expected: true},
+		{constraint: "~> v2.2.1", version: "v2.3.0", expected: false},
+		{constraint: "~> 2", version: "2.4.0", expected: true},
+		{constraint: ">= v2.0, < v3.0", version: "v2.5.0", expected: true},
+		{constraint: ">= v2.0, != v2.5.0", version: "v2.5.0", expected: false},
+		{constraint: "v2.1.3", version: "v2.1.3", expected: true},
+		{constraint: "~> v2.2", version: "v2.3.0-rc.1", expected: false},
+		{constraint: "~> v2.3.0-rc.1", version: "v2.3.0-rc.2", expected: true},
+		{constraint: "~> v2.2", version: "default", expected: false},
+	} {
+		t.Run(tc.constraint+" "+tc.version, func(t *testing.T) {
+			c, err := versions.ParseConstraint(tc.constraint)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, c.Check(tc.version))
+		})
+	}
+}
+
+func TestLatest(t *testing.T) {
+	t.Parallel()
+	available := []shared.Version{
+		{Name: "v2.1.0"},
+		{Name: "v2.2.0"},
+		{Name: "v2.3.0", Deprecated: pointer.For(true)},
+		{Name: "v2.2.4"},
+		{Name: "v3.0.0"},
+		{Name: "default"},
+	}
+
+	for _, tc := range []struct {
+		constraint string
+		expected   string
+		found      bool
+	}{
+		{constraint: "~> v2.2", expected: "v2.2.4", found: true},
+		{constraint: "~> v2.3.0", found: false},
+		{constraint: ">= v2.0", expected: "v3.0.0", found: true},
+		{constraint: "~> v4.0", found: false},
+	} {
+		t.Run(tc.constraint, func(t *testing.T) {
+			c, err := versions.ParseConstraint(tc.constraint)
+			require.NoError(t, err)
+
+			latest, found := versions.Latest(c, available)
+			require.Equal(t, tc.found, found)
+			require.Equal(t, tc.expected, latest)
+		})
+	}
+}
+
+func TestUpgradePath(t *testing.T) {
+	t.Parallel()
+	available := []shared.Version{
+		{Name: "v1.9.2"},
+		{Name: "v1.9.5"},
+		{Name: "v1.10.1"},
+		{Name: "v1.10.3"},
+		{Name: "v2.0.0-rc.1"},
+		{Name: "v2.0.1"},
+		{Name: "v2.1.0", Deprecated: pointer.For(true)},
+		{Name: "v2.1.4"},
+		{Name: "v2.2.0"},
+		{Name: "v2.2.3"},
+		{Name: "default"},
+	}
+
+	for _, tc := range []struct {
+		name        string
+		from        string
+		to          string
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:     "major jump",
+			from:     "v1.9.2",
+			to:       "v2.2.0",
+			expected: []string{"v1.10.3", "v2.0.1", "v2.1.4", "v2.2.0"},
+		},
+		{
+			name:     "patch upgrade",
+			from:     "v2.2.0",
+			to:       "v2.2.3",
+			expected: []string{"v2.2.3"},
+		},
+		{
+			name:     "next minor",
+			from:     "v2.1.4",
+			to:       "v2.2.3",
+			expected: []string{"v2.2.3"},
+		},
+		{
+			name:     "unknown starting point",
+			from:     "default",
+			to:       "v2.2.3",
+			expected: []string{"v2.2.3"},
+		},
+		{
+			name:        "unavailable target",
+			from:        "v2.1.4",
+			to:          "v2.3.0",
+			expectedErr: true,
+		},
+		{
+			name:        "downgrade",
+			from:        "v2.2.3",
+			to:          "v2.2.0",
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := versions.UpgradePath(tc.from, tc.to, available)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, path)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package versions
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
+	"golang.org/x/mod/semver"
+)
+
+// Latest returns the highest non deprecated version of the region matching the constraint.
+func Latest(constraint Constraint, available []shared.Version) (string, bool) {
+	latest := ""
+	for _, v := range available {
+		if IsDeprecated(v) || !constraint.Check(v.Name) {
+			continue
+		}
+		if latest == "" || semver.Compare(Canonical(v.Name), Canonical(latest)) > 0 {
+			latest = v.Name
+		}
+	}
+	return latest, latest != ""
+}
+
+// IsDeprecated reports whether the region flagged the version as deprecated.
+func IsDeprecated(v shared.Version) bool {
+	return v.Deprecated != nil && *v.Deprecated
+}
+
+// Compare compares two versions, accepting an optional "v" prefix.
+// Invalid versions are considered smaller than valid ones.
+func Compare(a, b string) int {
+	return semver.Compare(Canonical(a), Canonical(b))
+}
+
+// Equal reports whether both names designate the same version, accepting an optional "v" prefix.
+func Equal(a, b string) bool {
+	if a == b {
+		return true
+	}
+	return Canonical(a) != "" && Compare(a, b) == 0
+}
+
+// UpgradePath returns the ordered versions to go through to upgrade from one version to another.
+// Every minor release line between both versions is visited once, using its newest non deprecated
+// patch, and the target version is always the last hop.
+func UpgradePath(from, to string, available []shared.Version) ([]string, error) {
+	target := Canonical(to)
+	if target == "" {
+		return nil, fmt.Errorf("target version %q is not a valid semantic version", to)
+	}
+
+	found := false
+	for _, v := range available {
+		if Canonical(v.Name) == target {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("target version %q is not available in the region", to)
+	}
+
+	current := Canonical(from)
+	if current == "" {
+		// Unknown starting point, nothing can be planned but the target itself
+		return []string{to}, nil
+	}
+	if semver.Compare(current, target) >= 0 {
+		return nil, fmt.Errorf("target version %q is not newer than %q", to, from)
+	}
+
+	newest := map[string]shared.Version{}
+	for _, v := range available {
+		version := Canonical(v.Name)
+		if version == "" || IsDeprecated(v) || semver.Prerelease(version) != "" {
+			continue
+		}
+		if semver.Compare(version, current) <= 0 || semver.Compare(version, target) >= 0 {
+			continue
+		}
+		if semver.MajorMinor(version) == semver.MajorMinor(current) ||
+			semver.MajorMinor(version) == semver.MajorMinor(target) {
+			continue
+		}
+
+		line := semver.MajorMinor(version)
+		if existing, ok := newest[line]; !ok || semver.Compare(version, Canonical(existing.Name)) > 0 {
+			newest[line] = v
+		}
+	}
+
+	steps := make([]string, 0, len(newest)+1)
+	for _, v := range newest {
+		steps = append(steps, v.Name)
+	}
+	slices.SortFunc(steps, Compare)
+
+	return append(steps, to), nil
+}
@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrUnauthorized is the kind of authentication errors, to be matched with errors.Is.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// AuthReason tells which step of the authentication failed.
+type AuthReason string
+
+const (
+	// AuthReasonTokenEndpoint means the token endpoint could not be reached or failed.
+	AuthReasonTokenEndpoint AuthReason = "token_endpoint"
+	// AuthReasonClientCredentials means the token endpoint rejected the client credentials.
+	AuthReasonClientCredentials AuthReason = "client_credentials"
+	// AuthReasonClientAssertion means the client assertion could not be signed or read.
+	AuthReasonClientAssertion AuthReason = "client_assertion"
+)
+
+// AuthError is returned when the provider fails to authenticate against Formance Cloud.
+type AuthError struct {
+	Reason   AuthReason
+	Endpoint string
+
+	err error
+}
+
+func (e *AuthError) Error() string {
+	switch e.Reason {
+	case AuthReasonClientCredentials:
+		return fmt.Sprintf("the token endpoint of %s rejected the client credentials: %s. "+
+			"Check client_id and client_secret, the secret may have been rotated or the client revoked", e.Endpoint, e.err)
+	case AuthReasonClientAssertion:
+		return fmt.Sprintf("unable to build the client assertion: %s. "+
+			"Check private_key and private_key_algorithm, or the pre-issued client assertion", e.err)
+	}
+	return fmt.Sprintf("unable to get an access token from %s: %s. "+
+		"Check the endpoint of the provider and the connectivity to the token endpoint", e.Endpoint, e.err)
+}
+
+// Is implements errors.Is, matching ErrUnauthorized.
+func (e *AuthError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.err
+}
+
+// oauth2 error codes returned when the client itself is at fault.
+var credentialErrorCodes = []string{"invalid_client", "unauthorized_client", "invalid_grant", "invalid_scope"}
+
+// newTokenError classifies an error returned by the token endpoint.
+func newTokenError(endpoint string, err error) error {
+	authErr := &AuthError{
+		Reason:   AuthReasonTokenEndpoint,
+		Endpoint: endpoint,
+		err:      err,
+	}
+
+	retrieveErr := &oauth2.RetrieveError{}
+	if errors.As(err, &retrieveErr) {
+		if slices.Contains(credentialErrorCodes, retrieveErr.ErrorCode) ||
+			(retrieveErr.Response != nil && retrieveErr.Response.StatusCode == http.StatusUnauthorized) {
+			authErr.Reason = AuthReasonClientCredentials
+		}
+	}
+
+	return authErr
+}
@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// ClientAssertionType is the type of client assertions, as defined by RFC 7523.
+const ClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// DefaultClientAssertionAlgorithm is the algorithm used to sign client assertions when none is set.
+const DefaultClientAssertionAlgorithm = "RS256"
+
+// ClientAssertionAlgorithms lists the algorithms supported to sign client assertions.
+var ClientAssertionAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// clientAssertionLifetime is the validity of signed client assertions.
+var clientAssertionLifetime = 5 * time.Minute
+
+// ClientAssertion configures the private_key_jwt client authentication.
+// Assertions are either signed with PrivateKey, or pre-issued, for example by a CI OIDC federation.
+type ClientAssertion struct {
+	// PrivateKey is the PEM encoded key used to sign the assertions.
+	PrivateKey string
+	KeyID      string
+	Algorithm  string
+
+	// Assertion is a pre-issued assertion.
+	Assertion string
+	// AssertionFile is the path of a file containing a pre-issued assertion, read on each token request
+	// since CI systems rotate it.
+	AssertionFile string
+}
+
+// IsZero reports whether no client assertion is configured.
+func (a *ClientAssertion) IsZero() bool {
+	return a == nil || (a.PrivateKey == "" && a.Assertion == "" && a.AssertionFile == "")
+}
+
+// ClientAssertionCreds is implemented by the credentials which may authenticate with a client assertion.
+// Credentials returning nil authenticate with their client secret.
+type ClientAssertionCreds interface {
+	Creds
+	ClientAssertion() *ClientAssertion
+}
+
+// Validate checks that the private key can be used with the algorithm.
+func (a *ClientAssertion) Validate() error {
+	if a.PrivateKey == "" {
+		return nil
+	}
+	_, _, err := a.signingKey()
+	return err
+}
+
+// Token returns the assertion to send to the token endpoint, signing a new one when a private key is set.
+func (a *ClientAssertion) Token(clientID string, audience ...string) (string, error) {
+	switch {
+	case a.PrivateKey != "":
+		return a.sign(clientID, audience)
+	case a.Assertion != "":
+		return a.Assertion, nil
+	case a.AssertionFile != "":
+		data, err := os.ReadFile(a.AssertionFile)
+		if err != nil {
+			return "", fmt.Errorf("unable to read client assertion: %w", err)
+		}
+		assertion := strings.TrimSpace(string(data))
+		if assertion == "" {
+			return "", fmt.Errorf("client assertion file %s is empty", a.AssertionFile)
+		}
+		return assertion, nil
+	}
+	return "", errors.New("no private key or client assertion configured")
+}
+
+func (a *ClientAssertion) sign(clientID string, audience []string) (string, error) {
+	method, key, err := a.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Issuer:    clientID,
+		Subject:   clientID,
+		Audience:  audience,
+		ID:        uuid.NewString(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(clientAssertionLifetime)),
+	})
+	if a.KeyID != "" {
+		token.Header["kid"] = a.KeyID
+	}
+
+	return token.SignedString(key)
+}
+
+func (a *ClientAssertion) signingKey() (jwt.SigningMethod, crypto.PrivateKey, error) {
+	algorithm := a.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultClientAssertionAlgorithm
+	}
+
+	var (
+		key crypto.PrivateKey
+		err error
+	)
+	method := jwt.GetSigningMethod(algorithm)
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		key, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(a.PrivateKey))
+	case *jwt.SigningMethodECDSA:
+		key, err = jwt.ParseECPrivateKeyFromPEM([]byte(a.PrivateKey))
+	case *jwt.SigningMethodEd25519:
+		key, err = jwt.ParseEdPrivateKeyFromPEM([]byte(a.PrivateKey))
+	default:
+		return nil, nil, fmt.Errorf("unsupported client assertion algorithm %s, expected one of %s", algorithm, strings.Join(ClientAssertionAlgorithms, ", "))
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid private key for algorithm %s: %w", algorithm, err)
+	}
+
+	return method, key, nil
+}
@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func rsaKey(t *testing.T) (*rsa.PrivateKey, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key, string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}))
+}
+
+func TestClientAssertionSign(t *testing.T) {
+	t.Parallel()
+
+	rsaPrivateKey, rsaPEM := rsaKey(t)
+
+	ecPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecPrivateKey)
+	require.NoError(t, err)
+	ecPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}))
+
+	for _, tt := range []struct {
+		algorithm string
+		pem       string
+		publicKey any
+	}{
+		{"", rsaPEM, &rsaPrivateKey.PublicKey},
+		{"PS256", rsaPEM, &rsaPrivateKey.PublicKey},
+		{"ES256", ecPEM, &ecPrivateKey.PublicKey},
+	} {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			t.Parallel()
+
+			assertion := &ClientAssertion{
+				PrivateKey: tt.pem,
+				KeyID:      "key-1",
+				Algorithm:  tt.algorithm,
+			}
+			require.NoError(t, assertion.Validate())
+
+			signed, err := assertion.Token("organization_client", "https://issuer")
+			require.NoError(t, err)
+
+			claims := &jwt.RegisteredClaims{}
+			token, err := jwt.ParseWithClaims(signed, claims, func(token *jwt.Token) (any, error) {
+				return tt.publicKey, nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, "key-1", token.Header["kid"])
+			require.Equal(t, "organization_client", claims.Issuer)
+			require.Equal(t, "organization_client", claims.Subject)
+			require.True(t, claims.VerifyAudience("https://issuer", true))
+			require.NotEmpty(t, claims.ID)
+		})
+	}
+
+	require.Error(t, (&ClientAssertion{PrivateKey: ecPEM, Algorithm: "RS256"}).Validate())
+	require.Error(t, (&ClientAssertion{PrivateKey: rsaPEM, Algorithm: "HS256"}).Validate())
+}
+
+func TestClientAssertionFile(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "assertion")
+	require.NoError(t, os.WriteFile(file, []byte("pre-issued\n"), 0600))
+
+	assertion, err := (&ClientAssertion{AssertionFile: file}).Token("organization_client")
+	require.NoError(t, err)
+	require.Equal(t, "pre-issued", assertion)
+
+	_, err = (&ClientAssertion{AssertionFile: filepath.Join(t.TempDir(), "missing")}).Token("organization_client")
+	require.Error(t, err)
+}
+
+type testAssertionCreds struct {
+	testCreds
+	assertion *ClientAssertion
+}
+
+func (c testAssertionCreds) ClientAssertion() *ClientAssertion { return c.assertion }
+
+func TestTokenProviderClientAssertion(t *testing.T) {
+	t.Parallel()
+
+	_, rsaPEM := rsaKey(t)
+	server := newOIDCServer(t, 3600, nil)
+	tp := NewTokenProvider(http.DefaultTransport, testAssertionCreds{
+		testCreds: testCreds{server.URL},
+		assertion: &ClientAssertion{PrivateKey: rsaPEM},
+	}, ScopeCloud)
+
+	_, err := tp.RefreshToken(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, server.forms, 1)
+	form := server.forms[0]
+	require.Equal(t, ClientAssertionType, form.Get("client_assertion_type"))
+	require.NotEmpty(t, form.Get("client_assertion"))
+	require.Equal(t, "client", form.Get("client_id"))
+	require.False(t, form.Has("client_secret"))
+}
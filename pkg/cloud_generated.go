@@ -549,6 +549,45 @@ func (c *MockCloudSDKListRegionsCall) DoAndReturn(f func(context.Context, string
 	return c
 }
 
+// ListStackUsersAccesses mocks base method.
+func (m *MockCloudSDK) ListStackUsersAccesses(ctx context.Context, organizationID, stackID string) (*operations.ListStackUsersAccessesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStackUsersAccesses", ctx, organizationID, stackID)
+	ret0, _ := ret[0].(*operations.ListStackUsersAccessesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStackUsersAccesses indicates an expected call of ListStackUsersAccesses.
+func (mr *MockCloudSDKMockRecorder) ListStackUsersAccesses(ctx, organizationID, stackID any) *MockCloudSDKListStackUsersAccessesCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStackUsersAccesses", reflect.TypeOf((*MockCloudSDK)(nil).ListStackUsersAccesses), ctx, organizationID, stackID)
+	return &MockCloudSDKListStackUsersAccessesCall{Call: call}
+}
+
+// MockCloudSDKListStackUsersAccessesCall wrap *gomock.Call
+type MockCloudSDKListStackUsersAccessesCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockCloudSDKListStackUsersAccessesCall) Return(arg0 *operations.ListStackUsersAccessesResponse, arg1 error) *MockCloudSDKListStackUsersAccessesCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockCloudSDKListStackUsersAccessesCall) Do(f func(context.Context, string, string) (*operations.ListStackUsersAccessesResponse, error)) *MockCloudSDKListStackUsersAccessesCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockCloudSDKListStackUsersAccessesCall) DoAndReturn(f func(context.Context, string, string) (*operations.ListStackUsersAccessesResponse, error)) *MockCloudSDKListStackUsersAccessesCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // ListStacks mocks base method.
 func (m *MockCloudSDK) ListStacks(ctx context.Context, organizationID string) (*operations.ListStacksResponse, error) {
 	m.ctrl.T.Helper()
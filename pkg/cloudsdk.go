@@ -3,6 +3,7 @@ package pkg
 import (
 	"context"
 	"net/http"
+	"slices"
 
 	"github.com/formancehq/go-libs/v3/pointer"
 	membershipclient "github.com/formancehq/terraform-provider-cloud/pkg/membership_client"
@@ -23,6 +24,7 @@ type CloudSDK interface {
 	ReadStackUserAccess(ctx context.Context, organizationID, stackID, userId string) (*operations.ReadStackUserAccessResponse, error)
 	UpsertStackUserAccess(ctx context.Context, organizationID, stackID string, userId string, body *shared.UpdateStackUserRequest) (*operations.UpsertStackUserAccessResponse, error)
 	DeleteStackUserAccess(ctx context.Context, organizationID, stackID string, userId string) (*operations.DeleteStackUserAccessResponse, error)
+	ListStackUsersAccesses(ctx context.Context, organizationID, stackID string) (*operations.ListStackUsersAccessesResponse, error)
 
 	EnableModule(ctx context.Context, organizationID, stackID, moduleName string) (*operations.EnableModuleResponse, error)
 	DisableModule(ctx context.Context, organizationID, stackID, moduleName string) (*operations.DisableModuleResponse, error)
@@ -45,20 +47,25 @@ type CloudSDK interface {
 
 var _ CloudSDK = &sdkImpl{}
 
+// wrap converts the errors of the SDK into typed errors.
+func wrap[T any](res T, err error) (T, error) {
+	return res, NewAPIError(err)
+}
+
 type sdkImpl struct {
 	sdk *membershipclient.FormanceCloud
 }
 
 func (s *sdkImpl) ReadStack(ctx context.Context, organizationID string, stackID string) (*operations.GetStackResponse, error) {
-	return s.sdk.GetStack(ctx, organizationID, stackID)
+	return wrap(s.sdk.GetStack(ctx, organizationID, stackID))
 }
 
 func (s *sdkImpl) CreateStack(ctx context.Context, organizationID string, body *shared.CreateStackRequest) (*operations.CreateStackResponse, error) {
-	return s.sdk.CreateStack(ctx, organizationID, body)
+	return wrap(s.sdk.CreateStack(ctx, organizationID, body))
 }
 
 func (s *sdkImpl) UpdateStack(ctx context.Context, organizationID string, stackID string, body *shared.StackData) (*operations.UpdateStackResponse, error) {
-	return s.sdk.UpdateStack(ctx, organizationID, stackID, body)
+	return wrap(s.sdk.UpdateStack(ctx, organizationID, stackID, body))
 }
 
 func (s *sdkImpl) DeleteStack(ctx context.Context, organizationID, stackID string, force bool) (*operations.DeleteStackResponse, error) {
@@ -66,90 +73,95 @@ func (s *sdkImpl) DeleteStack(ctx context.Context, organizationID, stackID strin
 	if force {
 		forcePtr = pointer.For(true)
 	}
-	return s.sdk.DeleteStack(ctx, organizationID, stackID, forcePtr)
+	return wrap(s.sdk.DeleteStack(ctx, organizationID, stackID, forcePtr))
 }
 
 func (s *sdkImpl) ListStacks(ctx context.Context, organizationID string) (*operations.ListStacksResponse, error) {
-	return s.sdk.ListStacks(ctx, organizationID, nil, nil)
+	return wrap(s.sdk.ListStacks(ctx, organizationID, nil, nil))
 }
 
 func (s *sdkImpl) UpgradeStack(ctx context.Context, organizationID, stackID string, version string) (*operations.UpgradeStackResponse, error) {
-	return s.sdk.UpgradeStack(ctx, organizationID, stackID, &shared.StackVersion{
+	return wrap(s.sdk.UpgradeStack(ctx, organizationID, stackID, &shared.StackVersion{
 		Version: pointer.For(version),
-	})
+	}))
 }
 
 func (s *sdkImpl) ListModules(ctx context.Context, organizationID string, stackID string) (*operations.ListModulesResponse, error) {
-	return s.sdk.ListModules(ctx, organizationID, stackID)
+	return wrap(s.sdk.ListModules(ctx, organizationID, stackID))
 }
 
 func (s *sdkImpl) EnableModule(ctx context.Context, organizationID, stackID, moduleName string) (*operations.EnableModuleResponse, error) {
-	return s.sdk.EnableModule(ctx, organizationID, stackID, moduleName)
+	return wrap(s.sdk.EnableModule(ctx, organizationID, stackID, moduleName))
 }
 
 func (s *sdkImpl) DisableModule(ctx context.Context, organizationID, stackID, moduleName string) (*operations.DisableModuleResponse, error) {
-	return s.sdk.DisableModule(ctx, organizationID, stackID, moduleName)
+	return wrap(s.sdk.DisableModule(ctx, organizationID, stackID, moduleName))
 }
 
 func (s *sdkImpl) UpsertStackUserAccess(ctx context.Context, organizationID, stackID, userId string, body *shared.UpdateStackUserRequest) (*operations.UpsertStackUserAccessResponse, error) {
-	return s.sdk.UpsertStackUserAccess(ctx, organizationID, stackID, userId, body)
+	return wrap(s.sdk.UpsertStackUserAccess(ctx, organizationID, stackID, userId, body))
 }
 
 func (s *sdkImpl) ReadStackUserAccess(ctx context.Context, organizationID, stackID, userId string) (*operations.ReadStackUserAccessResponse, error) {
-	return s.sdk.ReadStackUserAccess(ctx, organizationID, stackID, userId)
+	return wrap(s.sdk.ReadStackUserAccess(ctx, organizationID, stackID, userId))
 }
 
 func (s *sdkImpl) DeleteStackUserAccess(ctx context.Context, organizationID, stackID, userId string) (*operations.DeleteStackUserAccessResponse, error) {
-	return s.sdk.DeleteStackUserAccess(ctx, organizationID, stackID, userId)
+	return wrap(s.sdk.DeleteStackUserAccess(ctx, organizationID, stackID, userId))
+}
+
+func (s *sdkImpl) ListStackUsersAccesses(ctx context.Context, organizationID, stackID string) (*operations.ListStackUsersAccessesResponse, error) {
+	return wrap(s.sdk.ListStackUsersAccesses(ctx, organizationID, stackID))
 }
 
 func (s *sdkImpl) ListRegions(ctx context.Context, organizationID string) (*operations.ListRegionsResponse, error) {
-	return s.sdk.ListRegions(ctx, organizationID)
+	return wrap(s.sdk.ListRegions(ctx, organizationID))
 }
 
 func (s *sdkImpl) GetRegion(ctx context.Context, organizationID, regionID string) (*operations.GetRegionResponse, error) {
-	return s.sdk.GetRegion(ctx, organizationID, regionID)
+	return wrap(s.sdk.GetRegion(ctx, organizationID, regionID))
 }
 
 func (s *sdkImpl) GetRegionVersions(ctx context.Context, organizationID, regionID string) (*operations.GetRegionVersionsResponse, error) {
-	return s.sdk.GetRegionVersions(ctx, organizationID, regionID)
+	return wrap(s.sdk.GetRegionVersions(ctx, organizationID, regionID))
 }
 
 func (s *sdkImpl) ReadOrganization(ctx context.Context, organizationID string) (*operations.ReadOrganizationResponse, error) {
-	return s.sdk.ReadOrganization(ctx, organizationID, nil)
+	return wrap(s.sdk.ReadOrganization(ctx, organizationID, nil))
 }
 
 func (s *sdkImpl) CreateInvitation(ctx context.Context, organizationID, email string) (*operations.CreateInvitationResponse, error) {
-	return s.sdk.CreateInvitation(ctx, organizationID, email)
+	return wrap(s.sdk.CreateInvitation(ctx, organizationID, email))
 }
 
 func (s *sdkImpl) DeleteInvitation(ctx context.Context, organizationID, invitationID string) (*operations.DeleteInvitationResponse, error) {
-	return s.sdk.DeleteInvitation(ctx, organizationID, invitationID)
+	return wrap(s.sdk.DeleteInvitation(ctx, organizationID, invitationID))
 }
 
 func (s *sdkImpl) ListOrganizationInvitations(ctx context.Context, organizationID string) (*operations.ListInvitationsResponse, error) {
 	orgPtr := pointer.For(organizationID)
-	return s.sdk.ListInvitations(ctx, nil, orgPtr)
+	return wrap(s.sdk.ListInvitations(ctx, nil, orgPtr))
 }
 
 func (s *sdkImpl) ReadUserOfOrganization(ctx context.Context, organizationID, userID string) (*operations.ReadUserOfOrganizationResponse, error) {
-	return s.sdk.ReadUserOfOrganization(ctx, organizationID, userID)
+	return wrap(s.sdk.ReadUserOfOrganization(ctx, organizationID, userID))
 }
 
 func (s *sdkImpl) DeleteUserOfOrganization(ctx context.Context, organizationID, userID string) (*operations.DeleteUserFromOrganizationResponse, error) {
-	return s.sdk.DeleteUserFromOrganization(ctx, organizationID, userID)
+	return wrap(s.sdk.DeleteUserFromOrganization(ctx, organizationID, userID))
 }
 
 func (s *sdkImpl) UpsertUserOfOrganization(ctx context.Context, organizationID string, userID string, body *shared.UpdateOrganizationUserRequest) (*operations.UpsertOrganizationUserResponse, error) {
-	return s.sdk.UpsertOrganizationUser(ctx, organizationID, userID, body)
+	return wrap(s.sdk.UpsertOrganizationUser(ctx, organizationID, userID, body))
 }
 
-type CloudFactory func(endpoint string, transport http.RoundTripper) CloudSDK
+// CloudFactory creates the SDK of a provider instance, the options take precedence over the ones of the factory.
+type CloudFactory func(endpoint string, transport http.RoundTripper, opts ...membershipclient.SDKOption) CloudSDK
 
 func NewCloudSDK(opts ...membershipclient.SDKOption) CloudFactory {
-	return func(endpoint string, transport http.RoundTripper) CloudSDK {
+	return func(endpoint string, transport http.RoundTripper, instanceOpts ...membershipclient.SDKOption) CloudSDK {
 		return &sdkImpl{
-			sdk: NewSDK(endpoint, transport),
+			sdk: NewSDK(endpoint, transport, slices.Concat(opts, instanceOpts)...),
 		}
 	}
 }
@@ -160,8 +172,9 @@ func NewSDK(endpoint string, transport http.RoundTripper, opts ...membershipclie
 	}
 
 	return membershipclient.New(
-		append(opts, membershipclient.WithServerURL(endpoint),
+		slices.Concat(opts, []membershipclient.SDKOption{
+			membershipclient.WithServerURL(endpoint),
 			membershipclient.WithClient(client),
-		)...,
+		})...,
 	)
 }
@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// DefaultProfile is the profile used when none is selected.
+const DefaultProfile = "default"
+
+// ExternalCredentials are credentials loaded from a credentials file or a credential process.
+type ExternalCredentials struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Endpoint     string `json:"endpoint"`
+}
+
+// CredentialsFile follows the layout of the fctl configuration, profiles may hold client credentials
+// or a credential process in addition to the fctl fields.
+type CredentialsFile struct {
+	CurrentProfile string                         `json:"currentProfile"`
+	Profiles       map[string]*CredentialsProfile `json:"profiles"`
+}
+
+type CredentialsProfile struct {
+	MembershipURI     string `json:"membershipURI"`
+	ClientID          string `json:"clientId,omitempty"`
+	ClientSecret      string `json:"clientSecret,omitempty"`
+	CredentialProcess string `json:"credentialProcess,omitempty"`
+}
+
+// DefaultCredentialsFile returns the path of the fctl configuration.
+func DefaultCredentialsFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".formance", "fctl.config"), nil
+}
+
+// LoadProfile loads the credentials of a profile of the file. An empty profile selects the current
+// profile of the file, or the default one.
+func LoadProfile(ctx context.Context, file, profile string) (*ExternalCredentials, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials file: %w", err)
+	}
+
+	config := &CredentialsFile{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unable to decode credentials file %s: %w", file, err)
+	}
+
+	if profile == "" {
+		profile = config.CurrentProfile
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+
+	p, ok := config.Profiles[profile]
+	if !ok || p == nil {
+		return nil, fmt.Errorf("profile '%s' not found in %s, available profiles: %s",
+			profile, file, strings.Join(slices.Sorted(maps.Keys(config.Profiles)), ", "))
+	}
+
+	credentials := &ExternalCredentials{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		Endpoint:     p.MembershipURI,
+	}
+	if p.CredentialProcess == "" {
+		return credentials, nil
+	}
+
+	process, err := RunCredentialProcess(ctx, p.CredentialProcess)
+	if err != nil {
+		return nil, fmt.Errorf("profile '%s': %w", profile, err)
+	}
+	if process.ClientID == "" {
+		process.ClientID = credentials.ClientID
+	}
+	if process.ClientSecret == "" {
+		process.ClientSecret = credentials.ClientSecret
+	}
+	if process.Endpoint == "" {
+		process.Endpoint = credentials.Endpoint
+	}
+	return process, nil
+}
+
+// RunCredentialProcess runs the command with the shell and reads the credentials it writes as JSON on stdout.
+func RunCredentialProcess(ctx context.Context, command string) (*ExternalCredentials, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		// stdout is never reported, it may contain secrets
+		return nil, fmt.Errorf("credential process failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	credentials := &ExternalCredentials{}
+	if err := json.Unmarshal(stdout.Bytes(), credentials); err != nil {
+		return nil, errors.New("credential process output is not a JSON object with client_id, client_secret and endpoint")
+	}
+	// The fields missing from the output are left to the other sources of credentials
+	if *credentials == (ExternalCredentials{}) {
+		return nil, errors.New("credential process returned none of client_id, client_secret and endpoint")
+	}
+
+	return credentials, nil
+}
@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const credentialsFile = `{
+	"currentProfile": "staging",
+	"profiles": {
+		"default": {
+			"membershipURI": "https://app.formance.cloud/api",
+			"clientId": "organization_default",
+			"clientSecret": "default-secret"
+		},
+		"staging": {
+			"membershipURI": "https://staging.formance.cloud/api",
+			"clientId": "organization_staging",
+			"credentialProcess": "echo '{\"client_secret\": \"staging-secret\"}'"
+		}
+	}
+}`
+
+func TestLoadProfile(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "fctl.config")
+	require.NoError(t, os.WriteFile(file, []byte(credentialsFile), 0600))
+
+	credentials, err := LoadProfile(context.Background(), file, "default")
+	require.NoError(t, err)
+	require.Equal(t, &ExternalCredentials{
+		ClientID:     "organization_default",
+		ClientSecret: "default-secret",
+		Endpoint:     "https://app.formance.cloud/api",
+	}, credentials)
+
+	// The current profile is used by default, its secret comes from the credential process
+	credentials, err = LoadProfile(context.Background(), file, "")
+	require.NoError(t, err)
+	require.Equal(t, &ExternalCredentials{
+		ClientID:     "organization_staging",
+		ClientSecret: "staging-secret",
+		Endpoint:     "https://staging.formance.cloud/api",
+	}, credentials)
+
+	_, err = LoadProfile(context.Background(), file, "production")
+	require.ErrorContains(t, err, "available profiles: default, staging")
+}
+
+func TestRunCredentialProcess(t *testing.T) {
+	t.Parallel()
+
+	credentials, err := RunCredentialProcess(context.Background(), `echo '{"client_id": "organization_client", "client_secret": "secret", "endpoint": "https://app.formance.cloud/api"}'`)
+	require.NoError(t, err)
+	require.Equal(t, &ExternalCredentials{
+		ClientID:     "organization_client",
+		ClientSecret: "secret",
+		Endpoint:     "https://app.formance.cloud/api",
+	}, credentials)
+
+	_, err = RunCredentialProcess(context.Background(), "echo locked >&2; exit 1")
+	require.ErrorContains(t, err, "locked")
+
+	_, err = RunCredentialProcess(context.Background(), "echo not-json-secret")
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "not-json-secret")
+
+	credentials, err = RunCredentialProcess(context.Background(), `echo '{"client_id": "organization_client"}'`)
+	require.NoError(t, err)
+	require.Equal(t, &ExternalCredentials{ClientID: "organization_client"}, credentials)
+
+	_, err = RunCredentialProcess(context.Background(), `echo '{}'`)
+	require.ErrorContains(t, err, "returned none of")
+}
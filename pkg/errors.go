@@ -5,39 +5,275 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/sdkerrors"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// Error kinds, to be matched with errors.Is.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrForbidden   = errors.New("forbidden")
+	ErrValidation  = errors.New("validation failed")
+	ErrRateLimited = errors.New("rate limited")
+	ErrServerError = errors.New("server error")
+)
+
+// MayBeStackBusy reports whether a mutation was rejected the way the API rejects the mutations of
+// stacks which are not READY, with a conflict or a validation error. The API does not tell these
+// rejections apart from the other conflicts and validation errors, the status of the stack does.
+func MayBeStackBusy(err error) bool {
+	err = NewAPIError(err)
+	return errors.Is(err, ErrConflict) || errors.Is(err, ErrValidation)
+}
+
+// Error is the error payload returned by the API.
 type Error struct {
 	ErrorCode    string `json:"errorCode"`
 	ErrorMessage string `json:"errorMessage"`
+	// Field is the path of the invalid field, reported by some validation errors.
+	Field string `json:"field,omitempty"`
+}
+
+// APIError is a typed error returned by the API.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+
+	// Scope is the scope missing from the token, for forbidden errors.
+	Scope string
+	// Field is the path of the invalid field, for validation errors.
+	Field string
+	// RetryAfter is the delay requested by the API, for rate limited errors.
+	RetryAfter time.Duration
+
+	kind error
+	err  error
 }
 
-func HandleSDKError(ctx context.Context, err error, diag *diag.Diagnostics) {
-	sharedError := &Error{
-		ErrorCode:    "INTERNAL",
-		ErrorMessage: err.Error(),
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return e.Message
 	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Is implements errors.Is, matching the kind of the error.
+func (e *APIError) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
 
-	tmp := &sdkerrors.SDKError{}
-	if errors.As(err, &tmp) {
-		err = errors.New(tmp.Body)
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+var insufficientScope = regexp.MustCompile(`scope="([^"]*)"`)
+
+// NewAPIError converts an error returned by the SDK into an *APIError.
+// Errors which do not come from the API are returned untouched.
+func NewAPIError(err error) error {
+	if err == nil {
+		return nil
+	}
+	apiErr := &APIError{}
+	if errors.As(err, &apiErr) {
+		return err
 	}
 
-	errResponse := &Error{}
-	if e := json.Unmarshal([]byte(err.Error()), errResponse); e == nil {
-		sharedError = errResponse
+	payload := &Error{}
+	sdkErr := &sdkerrors.SDKError{}
+	if !errors.As(err, &sdkErr) {
+		// Errors may carry a raw API payload
+		if e := json.Unmarshal([]byte(err.Error()), payload); e != nil || payload.ErrorCode == "" {
+			return err
+		}
+		apiErr = &APIError{
+			Code:    payload.ErrorCode,
+			Message: payload.ErrorMessage,
+			Field:   payload.Field,
+			err:     err,
+		}
+		apiErr.kind = kindOf(0, apiErr.Code)
+		return apiErr
+	}
+
+	apiErr = &APIError{
+		StatusCode: sdkErr.StatusCode,
+		Message:    sdkErr.Body,
+		err:        err,
+	}
+	if e := json.Unmarshal([]byte(sdkErr.Body), payload); e == nil && payload.ErrorCode != "" {
+		apiErr.Code = payload.ErrorCode
+		apiErr.Message = payload.ErrorMessage
+		apiErr.Field = payload.Field
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = sdkErr.Message
+	}
+	if res := sdkErr.RawResponse; res != nil {
+		apiErr.RequestID = res.Header.Get("X-Request-Id")
+		if matches := insufficientScope.FindStringSubmatch(res.Header.Get("WWW-Authenticate")); matches != nil {
+			apiErr.Scope = matches[1]
+		}
+		apiErr.RetryAfter = ParseRetryAfter(res.Header.Get("Retry-After"))
+	}
+	apiErr.kind = kindOf(apiErr.StatusCode, apiErr.Code)
+
+	return apiErr
+}
+
+// ParseRetryAfter parses a Retry-After header, expressed either in seconds or as an HTTP date.
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+func kindOf(statusCode int, code string) error {
+	switch {
+	case statusCode == http.StatusNotFound, code == "NOT_FOUND":
+		return ErrNotFound
+	case statusCode == http.StatusConflict, code == "CONFLICT":
+		return ErrConflict
+	case statusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case statusCode == http.StatusForbidden, code == "FORBIDDEN":
+		return ErrForbidden
+	case statusCode == http.StatusBadRequest, statusCode == http.StatusUnprocessableEntity, code == "VALIDATION":
+		return ErrValidation
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode >= http.StatusInternalServerError, code == "INTERNAL":
+		return ErrServerError
 	}
-	span := trace.SpanFromContext(ctx)
-	if span.SpanContext().IsValid() {
-		traceparent := fmt.Sprintf("%s-%s", span.SpanContext().TraceID().String(), span.SpanContext().SpanID().String())
-		sharedError.ErrorMessage = fmt.Sprintf("[Traceparent: %s] %s", traceparent, sharedError.ErrorMessage)
+	return nil
+}
+
+type errorOptions struct {
+	attribute path.Path
+	fields    map[string]path.Path
+}
+
+// ErrorOption configures how HandleSDKError reports an error.
+type ErrorOption func(*errorOptions)
+
+// WithAttribute scopes the diagnostic to an attribute.
+func WithAttribute(p path.Path) ErrorOption {
+	return func(o *errorOptions) {
+		o.attribute = p
+	}
+}
+
+// WithField scopes validation errors about an API field to an attribute.
+func WithField(field string, p path.Path) ErrorOption {
+	return func(o *errorOptions) {
+		if o.fields == nil {
+			o.fields = map[string]path.Path{}
+		}
+		o.fields[field] = p
+	}
+}
+
+// HandleSDKError reports an error returned by the SDK as a diagnostic, with remediation hints
+// and the identifiers needed to correlate it with the API logs.
+func HandleSDKError(ctx context.Context, err error, diags *diag.Diagnostics, opts ...ErrorOption) {
+	options := &errorOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	summary := "Unexpected Error"
+	detail := err.Error()
+
+	apiErr := &APIError{}
+	authErr := &AuthError{}
+	if errors.As(err, &authErr) {
+		summary, detail = "Authentication Failed", authErr.Error()
+	} else if errors.As(NewAPIError(err), &apiErr) {
+		summary, detail = describe(apiErr)
+		if apiErr.Field != "" {
+			if p, ok := options.fields[apiErr.Field]; ok {
+				options.attribute = p
+			}
+		}
+	}
+
+	var correlation []string
+	if apiErr.RequestID != "" {
+		correlation = append(correlation, fmt.Sprintf("Request ID: %s", apiErr.RequestID))
+	}
+	if traceparent := Traceparent(ctx); traceparent != "" {
+		correlation = append(correlation, fmt.Sprintf("Traceparent: %s", traceparent))
+	}
+	if len(correlation) > 0 {
+		detail = fmt.Sprintf("%s\n\n%s", detail, strings.Join(correlation, "\n"))
+	}
+
+	if len(options.attribute.Steps()) == 0 {
+		diags.AddError(summary, detail)
+		return
+	}
+	diags.AddAttributeError(options.attribute, summary, detail)
+}
+
+func describe(err *APIError) (string, string) {
+	message := err.Message
+	if err.Code != "" {
+		message = fmt.Sprintf("%s (%s)", err.Message, err.Code)
+	}
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return "Resource Not Found", fmt.Sprintf("%s\n\nThe object may have been deleted outside of Terraform.", message)
+	case errors.Is(err, ErrConflict):
+		return "Conflict", fmt.Sprintf("%s\n\nThe object already exists or was modified concurrently. Refresh the state and apply again.", message)
+	case errors.Is(err, ErrUnauthorized):
+		return "Authentication Failed", fmt.Sprintf("%s\n\nThe API rejected the access token even after a refresh. Check that the client is still active and belongs to the organization.", message)
+	case errors.Is(err, ErrForbidden):
+		if err.Scope != "" {
+			return "Permission Denied", fmt.Sprintf("%s\n\nThe credentials are missing the '%s' scope. Grant it to the client and apply again.", message, err.Scope)
+		}
+		return "Permission Denied", fmt.Sprintf("%s\n\nCheck that the client is allowed to perform this action in the organization.", message)
+	case errors.Is(err, ErrValidation):
+		if err.Field != "" {
+			return "Invalid Configuration", fmt.Sprintf("%s\n\nThe API rejected the value of '%s'.", message, err.Field)
+		}
+		return "Invalid Configuration", message
+	case errors.Is(err, ErrRateLimited):
+		if err.RetryAfter > 0 {
+			return "Rate Limited", fmt.Sprintf("%s\n\nThe API rate limit was exceeded. Retry in %s or lower the parallelism of Terraform.", message, err.RetryAfter)
+		}
+		return "Rate Limited", fmt.Sprintf("%s\n\nThe API rate limit was exceeded. Retry later or lower the parallelism of Terraform.", message)
+	case errors.Is(err, ErrServerError):
+		return "Server Error", fmt.Sprintf("%s\n\nThe API failed to process the request. Retry later and contact support with the request ID if it persists.", message)
+	}
+	return "API Error", message
+}
+
+// Traceparent returns the W3C traceparent of the span of the context, or an empty string if none.
+func Traceparent(ctx context.Context) string {
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return ""
 	}
-	diag.AddError(
-		string(sharedError.ErrorCode),
-		sharedError.ErrorMessage,
-	)
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
 }
@@ -3,33 +3,47 @@ package pkg
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/sdkerrors"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 )
 
+func sdkError(statusCode int, body string, headers map[string]string) *sdkerrors.SDKError {
+	res := &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{},
+	}
+	for k, v := range headers {
+		res.Header.Set(k, v)
+	}
+	return sdkerrors.NewSDKError("API error occurred", statusCode, body, res)
+}
+
 func TestHandleSDKError(t *testing.T) {
 	for _, tt := range []struct {
 		name     string
 		err      error
+		opts     []ErrorOption
 		expected diag.Diagnostic
 	}{
 		{
-			name: "Test case 1",
-			err:  errors.New(""),
-			expected: diag.NewErrorDiagnostic(
-				"INTERNAL",
-				"",
-			),
+			name:     "empty error",
+			err:      errors.New(""),
+			expected: diag.NewErrorDiagnostic("Unexpected Error", ""),
 		},
 		{
 			name: "Error string",
 			err:  errors.New(`{"errorCode":"VALIDATION","errorMessage":"invalid config: polling period invalid: polling period cannot be lower than minimum of 20m0s: validation error: validation error"}`),
 			expected: diag.NewErrorDiagnostic(
-				"VALIDATION",
-				"invalid config: polling period invalid: polling period cannot be lower than minimum of 20m0s: validation error: validation error",
+				"Invalid Configuration",
+				"invalid config: polling period invalid: polling period cannot be lower than minimum of 20m0s: validation error: validation error (VALIDATION)",
 			),
 		},
 		{
@@ -37,19 +51,138 @@ func TestHandleSDKError(t *testing.T) {
 			err: &sdkerrors.SDKError{
 				Body: `{"errorCode":"SOME_ERROR","errorMessage":"An error occurred"}`,
 			},
-			expected: diag.NewErrorDiagnostic("SOME_ERROR", "An error occurred"),
+			expected: diag.NewErrorDiagnostic("API Error", "An error occurred (SOME_ERROR)"),
 		},
 		{
 			name:     "invalid error type",
 			err:      errors.New("some random error"),
-			expected: diag.NewErrorDiagnostic("INTERNAL", "some random error"),
+			expected: diag.NewErrorDiagnostic("Unexpected Error", "some random error"),
+		},
+		{
+			name: "not found with request id",
+			err: sdkError(http.StatusNotFound, `{"errorCode":"NOT_FOUND","errorMessage":"stack not found"}`, map[string]string{
+				"X-Request-Id": "req-1",
+			}),
+			opts: []ErrorOption{WithAttribute(path.Root("stack_id"))},
+			expected: diag.NewAttributeErrorDiagnostic(
+				path.Root("stack_id"),
+				"Resource Not Found",
+				"stack not found (NOT_FOUND)\n\nThe object may have been deleted outside of Terraform.\n\nRequest ID: req-1",
+			),
+		},
+		{
+			name: "forbidden with missing scope",
+			err: sdkError(http.StatusForbidden, `{"errorCode":"FORBIDDEN","errorMessage":"forbidden"}`, map[string]string{
+				"WWW-Authenticate": `Bearer error="insufficient_scope", scope="stack:write"`,
+			}),
+			expected: diag.NewErrorDiagnostic(
+				"Permission Denied",
+				"forbidden (FORBIDDEN)\n\nThe credentials are missing the 'stack:write' scope. Grant it to the client and apply again.",
+			),
+		},
+		{
+			name: "validation on a field",
+			err:  sdkError(http.StatusBadRequest, `{"errorCode":"VALIDATION","errorMessage":"name already used","field":"name"}`, nil),
+			opts: []ErrorOption{WithField("name", path.Root("name"))},
+			expected: diag.NewAttributeErrorDiagnostic(
+				path.Root("name"),
+				"Invalid Configuration",
+				"name already used (VALIDATION)\n\nThe API rejected the value of 'name'.",
+			),
+		},
+		{
+			name: "client credentials rejected",
+			err: fmt.Errorf("error sending request: %w", &AuthError{
+				Reason:   AuthReasonClientCredentials,
+				Endpoint: "https://app.formance.cloud/api",
+				err:      errors.New("invalid_client"),
+			}),
+			expected: diag.NewErrorDiagnostic(
+				"Authentication Failed",
+				"the token endpoint of https://app.formance.cloud/api rejected the client credentials: invalid_client. "+
+					"Check client_id and client_secret, the secret may have been rotated or the client revoked",
+			),
+		},
+		{
+			name: "token rejected by the API",
+			err:  sdkError(http.StatusUnauthorized, `{"errorCode":"UNAUTHORIZED","errorMessage":"invalid token"}`, nil),
+			expected: diag.NewErrorDiagnostic(
+				"Authentication Failed",
+				"invalid token (UNAUTHORIZED)\n\nThe API rejected the access token even after a refresh. Check that the client is still active and belongs to the organization.",
+			),
+		},
+		{
+			name: "rate limited",
+			err:  sdkError(http.StatusTooManyRequests, "", map[string]string{"Retry-After": "5"}),
+			expected: diag.NewErrorDiagnostic(
+				"Rate Limited",
+				"API error occurred\n\nThe API rate limit was exceeded. Retry in 5s or lower the parallelism of Terraform.",
+			),
 		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			diag := make(diag.Diagnostics, 0)
-			HandleSDKError(context.Background(), tt.err, &diag)
+			HandleSDKError(context.Background(), tt.err, &diag, tt.opts...)
 			require.Len(t, diag, 1)
 			require.Equal(t, tt.expected, diag[0])
 		})
 	}
 }
+
+func TestHandleSDKErrorTraceparent(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	diags := make(diag.Diagnostics, 0)
+	HandleSDKError(ctx, errors.New("boom"), &diags)
+	require.Len(t, diags, 1)
+	require.Equal(t, "boom\n\nTraceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", diags[0].Detail())
+}
+
+func TestNewAPIError(t *testing.T) {
+	for _, tt := range []struct {
+		statusCode int
+		kind       error
+	}{
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusBadRequest, ErrValidation},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusBadGateway, ErrServerError},
+	} {
+		t.Run(fmt.Sprint(tt.statusCode), func(t *testing.T) {
+			err := NewAPIError(sdkError(tt.statusCode, "", nil))
+			require.ErrorIs(t, err, tt.kind)
+			require.ErrorIs(t, fmt.Errorf("wrapped: %w", err), tt.kind)
+
+			sdkErr := &sdkerrors.SDKError{}
+			require.ErrorAs(t, err, &sdkErr)
+		})
+	}
+
+	require.Nil(t, NewAPIError(nil))
+	require.False(t, errors.Is(NewAPIError(errors.New("network")), ErrServerError))
+}
+
+func TestMayBeStackBusy(t *testing.T) {
+	require.True(t, MayBeStackBusy(sdkError(http.StatusConflict, `{"errorCode":"CONFLICT","errorMessage":"stack is not ready"}`, nil)))
+	require.True(t, MayBeStackBusy(sdkError(http.StatusBadRequest, `{"errorCode":"VALIDATION","errorMessage":"invalid stack status"}`, nil)))
+	require.False(t, MayBeStackBusy(sdkError(http.StatusNotFound, `{"errorCode":"NOT_FOUND","errorMessage":"stack not found"}`, nil)))
+	require.False(t, MayBeStackBusy(sdkError(http.StatusInternalServerError, `{"errorMessage":"stack is PROGRESSING"}`, nil)))
+	require.False(t, MayBeStackBusy(errors.New("network")))
+	require.False(t, MayBeStackBusy(nil))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	require.Equal(t, 3*time.Second, ParseRetryAfter("3"))
+	require.Zero(t, ParseRetryAfter(""))
+	require.Zero(t, ParseRetryAfter("soon"))
+	require.InDelta(t, float64(time.Minute), float64(ParseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))), float64(2*time.Second))
+}
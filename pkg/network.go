@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// NetworkConfig configures the connections to the API and to the OIDC token endpoint.
+// Certificates and keys are either PEM encoded or the path of a PEM file.
+type NetworkConfig struct {
+	// Proxy is the URL of the proxy used for every request, the HTTP_PROXY and HTTPS_PROXY variables are used if empty.
+	Proxy string
+	// NoProxy lists the hosts reached without proxy, using the syntax of the NO_PROXY variable.
+	NoProxy string
+
+	CABundle           string
+	ClientCertificate  string
+	ClientKey          string
+	InsecureSkipVerify bool
+
+	// Headers are added to every request.
+	Headers map[string]string
+}
+
+// customizesTransport reports whether the configuration needs a dedicated transport.
+func (c NetworkConfig) customizesTransport() bool {
+	return c.Proxy != "" || c.NoProxy != "" || c.CABundle != "" || c.ClientCertificate != "" || c.InsecureSkipVerify
+}
+
+// NewNetworkTransport returns a transport sending the requests according to the configuration,
+// or base when the configuration only sets headers. Base is wrapped by the transport created with wrap,
+// since a proxy or TLS settings cannot be applied to an existing transport.
+func NewNetworkTransport(base http.RoundTripper, wrap func(http.RoundTripper) http.RoundTripper, config NetworkConfig) (http.RoundTripper, error) {
+	transport := base
+	if config.customizesTransport() {
+		httpTransport, err := newHTTPTransport(config)
+		if err != nil {
+			return nil, err
+		}
+		transport = wrap(httpTransport)
+	}
+
+	if len(config.Headers) == 0 {
+		return transport, nil
+	}
+	return newHeadersTransport(transport, config.Headers), nil
+}
+
+func newHTTPTransport(config NetworkConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.Proxy != "" || config.NoProxy != "" {
+		proxy := httpproxy.FromEnvironment()
+		if config.Proxy != "" {
+			if _, err := url.Parse(config.Proxy); err != nil {
+				return nil, fmt.Errorf("invalid proxy URL: %w", err)
+			}
+			proxy.HTTPProxy = config.Proxy
+			proxy.HTTPSProxy = config.Proxy
+		}
+		if config.NoProxy != "" {
+			proxy.NoProxy = config.NoProxy
+		}
+		proxyFunc := proxy.ProxyFunc()
+		transport.Proxy = func(r *http.Request) (*url.URL, error) {
+			return proxyFunc(r.URL)
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// #nosec G402 -- opt-in for development environments, a warning is reported to the user
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CABundle != "" {
+		bundle, err := readPEM(config.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.New("the CA bundle contains no PEM encoded certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCertificate != "" || config.ClientKey != "" {
+		if config.ClientCertificate == "" || config.ClientKey == "" {
+			return nil, errors.New("both the client certificate and the client key are required for mutual TLS")
+		}
+		certificate, err := readPEM(config.ClientCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the client certificate: %w", err)
+		}
+		key, err := readPEM(config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the client key: %w", err)
+		}
+		pair, err := tls.X509KeyPair(certificate, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// readPEM returns the value if it is PEM encoded, or the content of the file it points to.
+func readPEM(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value)
+}
+
+func newHeadersTransport(rt http.RoundTripper, headers map[string]string) RoundTripperFn {
+	return func(r *http.Request) (*http.Response, error) {
+		req := r.Clone(r.Context())
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		return rt.RoundTrip(req)
+	}
+}
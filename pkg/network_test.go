@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func identity(rt http.RoundTripper) http.RoundTripper {
+	return rt
+}
+
+func TestNetworkTransportCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	for _, tt := range []struct {
+		name          string
+		config        NetworkConfig
+		expectedError bool
+	}{
+		{
+			name:          "untrusted certificate",
+			config:        NetworkConfig{Headers: map[string]string{"X-Test": "test"}},
+			expectedError: true,
+		},
+		{
+			name:   "CA bundle",
+			config: NetworkConfig{CABundle: bundle},
+		},
+		{
+			name:   "insecure",
+			config: NetworkConfig{InsecureSkipVerify: true},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := NewNetworkTransport(http.DefaultTransport, identity, tt.config)
+			require.NoError(t, err)
+
+			res, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if tt.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+		})
+	}
+}
+
+func TestNetworkTransportProxyAndHeaders(t *testing.T) {
+	var (
+		host   string
+		header string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		header = r.Header.Get("X-Gateway")
+	}))
+	t.Cleanup(proxy.Close)
+
+	transport, err := NewNetworkTransport(http.DefaultTransport, identity, NetworkConfig{
+		Proxy:   proxy.URL,
+		Headers: map[string]string{"X-Gateway": "formance"},
+	})
+	require.NoError(t, err)
+
+	res, err := (&http.Client{Transport: transport}).Get("http://api.formance.internal/v1")
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, "api.formance.internal", host)
+	require.Equal(t, "formance", header)
+}
+
+func TestNetworkTransportInvalidConfig(t *testing.T) {
+	for _, config := range []NetworkConfig{
+		{CABundle: "-----BEGIN CERTIFICATE-----\ninvalid\n-----END CERTIFICATE-----"},
+		{CABundle: "/does/not/exist.pem"},
+		{ClientCertificate: "/does/not/exist.pem"},
+	} {
+		_, err := NewNetworkTransport(http.DefaultTransport, identity, config)
+		require.Error(t, err)
+	}
+}
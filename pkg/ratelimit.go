@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitConfig limits the requests sent to the API by a provider instance.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the rate of the token bucket, zero disables it.
+	RequestsPerSecond float64
+	// Burst is the size of the token bucket, it defaults to the rate rounded up.
+	Burst int
+	// MaxConcurrentRequests is the maximum number of requests in flight, zero disables the limit.
+	MaxConcurrentRequests int
+}
+
+// minLoggedWait avoids logging waits which are not caused by an exhausted budget.
+const minLoggedWait = 10 * time.Millisecond
+
+// NewRateLimitTransport delays the requests exceeding the rate or the concurrency of the config.
+// The limiter is shared by every request of the transport, and applies to each attempt of retried requests.
+// A nil config disables the limits.
+func NewRateLimitTransport(rt http.RoundTripper, config *RateLimitConfig) http.RoundTripper {
+	if config == nil || (config.RequestsPerSecond <= 0 && config.MaxConcurrentRequests <= 0) {
+		return rt
+	}
+
+	var limiter *rate.Limiter
+	if config.RequestsPerSecond > 0 {
+		burst := config.Burst
+		if burst <= 0 {
+			burst = max(int(config.RequestsPerSecond+0.999), 1)
+		}
+		limiter = rate.NewLimiter(rate.Limit(config.RequestsPerSecond), burst)
+	}
+
+	var slots chan struct{}
+	if config.MaxConcurrentRequests > 0 {
+		slots = make(chan struct{}, config.MaxConcurrentRequests)
+	}
+
+	return RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-r.Context().Done():
+				return nil, r.Context().Err()
+			}
+		}
+		if limiter != nil {
+			if err := limiter.Wait(r.Context()); err != nil {
+				return nil, err
+			}
+		}
+		recordWait(r.Context(), r, time.Since(start))
+
+		return rt.RoundTrip(r)
+	})
+}
+
+func recordWait(ctx context.Context, r *http.Request, wait time.Duration) {
+	if wait < minLoggedWait {
+		return
+	}
+	logging.FromContext(ctx).Debugf("Rate limited %s %s for %s", r.Method, r.URL.Path, wait)
+	trace.SpanFromContext(ctx).AddEvent("rate_limited", trace.WithAttributes(
+		attribute.String("wait", wait.String()),
+	))
+}
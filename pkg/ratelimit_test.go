@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitTransportRate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	transport := NewRateLimitTransport(http.DefaultTransport, &RateLimitConfig{
+		RequestsPerSecond: 20,
+		Burst:             1,
+	})
+
+	start := time.Now()
+	for range 5 {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		res, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+	// The first request uses the burst, the next ones wait 50ms each
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestRateLimitTransportConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			previous := maxInFlight.Load()
+			if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+
+	transport := NewRateLimitTransport(http.DefaultTransport, &RateLimitConfig{
+		MaxConcurrentRequests: 2,
+	})
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			require.NoError(t, err)
+			res, err := transport.RoundTrip(req)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(2), maxInFlight.Load())
+}
+
+func TestRateLimitTransportDisabled(t *testing.T) {
+	require.Equal(t, http.DefaultTransport, NewRateLimitTransport(http.DefaultTransport, nil))
+	require.Equal(t, http.DefaultTransport, NewRateLimitTransport(http.DefaultTransport, &RateLimitConfig{}))
+}
+
+func TestRateLimitTransportRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	// Requests wait 100ms for the limiter, twice the timeout of the round trips, and are not retried
+	transport := NewRetryTransport(NewRateLimitTransport(NewTimeoutTransport(http.DefaultTransport, 50*time.Millisecond), &RateLimitConfig{
+		RequestsPerSecond: 10,
+		Burst:             1,
+	}), nil)
+
+	for range 3 {
+		req, err := http.NewRequest(http.MethodPost, server.URL, http.NoBody)
+		require.NoError(t, err)
+		res, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+}
@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/formancehq/go-libs/v3/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultRetryStatusCodes are the status codes retried when none are configured.
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryConfig configures the retries of the requests sent to the API.
+type RetryConfig struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+	Exponent        float64
+	// StatusCodes are the status codes of the responses to retry.
+	StatusCodes []int
+}
+
+// interval returns the backoff before the given retry, with up to 10% of jitter.
+func (c *RetryConfig) interval(attempt int) time.Duration {
+	interval := float64(c.InitialInterval) * math.Pow(c.Exponent, float64(attempt))
+	interval = min(interval, float64(c.MaxInterval))
+	return time.Duration(interval) + time.Duration(rand.Int64N(int64(interval)/10+1))
+}
+
+var idempotentMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete}
+
+// retryAfter reports whether the attempt must be retried, and the delay requested by the API if any.
+func (c *RetryConfig) retryAfter(r *http.Request, res *http.Response, err error) (time.Duration, bool) {
+	// Authentication errors and canceled runs are final
+	if errors.Is(err, ErrUnauthorized) || r.Context().Err() != nil {
+		return 0, false
+	}
+	// Requests which are not idempotent, such as creations, may have been processed by the API when
+	// the connection fails or the API fails, they are only retried when rejected as rate limited
+	if !slices.Contains(idempotentMethods, r.Method) && (err != nil || res.StatusCode != http.StatusTooManyRequests) {
+		return 0, false
+	}
+	if err != nil {
+		return 0, true
+	}
+	if !slices.Contains(c.StatusCodes, res.StatusCode) {
+		return 0, false
+	}
+	return ParseRetryAfter(res.Header.Get("Retry-After")), true
+}
+
+// NewRetryTransport retries failed requests with an exponential backoff, the Retry-After header
+// of the response taking precedence over the backoff. A nil config disables retries.
+// Idempotent requests are retried on connection errors and on the configured status codes, the other
+// requests only on rate limited responses.
+//
+// Retries are done by the transport rather than the SDK, which retries a fixed list of status codes.
+func NewRetryTransport(rt http.RoundTripper, config *RetryConfig) RoundTripperFn {
+	return func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+		for attempt := 0; ; attempt++ {
+			req := r
+			if attempt > 0 {
+				req = r.Clone(r.Context())
+				if r.Body != nil && r.Body != http.NoBody {
+					body, err := r.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					req.Body = body
+				}
+			}
+
+			res, err := rt.RoundTrip(req)
+			if config == nil {
+				return res, err
+			}
+
+			wait, retry := config.retryAfter(r, res, err)
+			if !retry {
+				return res, err
+			}
+			// The body has been consumed and cannot be sent again
+			if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+				return res, err
+			}
+			if wait <= 0 {
+				wait = config.interval(attempt)
+			}
+			if time.Since(start)+wait > config.MaxElapsedTime {
+				return res, err
+			}
+
+			if res != nil {
+				_, _ = io.Copy(io.Discard, res.Body)
+				_ = res.Body.Close()
+				logging.FromContext(r.Context()).Debugf("Retrying %s %s in %s after status %d", r.Method, r.URL.Path, wait, res.StatusCode)
+			} else {
+				logging.FromContext(r.Context()).Debugf("Retrying %s %s in %s after error: %s", r.Method, r.URL.Path, wait, err)
+			}
+			trace.SpanFromContext(r.Context()).AddEvent("retry", trace.WithAttributes(
+				attribute.Int("attempt", attempt+1),
+				attribute.String("wait", wait.String()),
+			))
+
+			select {
+			case <-r.Context().Done():
+				return nil, r.Context().Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// NewTimeoutTransport bounds each round trip by timeout, a zero timeout disables it.
+// It must wrap the network transport only, so that the time spent waiting for the rate limiter
+// or for a token does not count against the timeout.
+func NewTimeoutTransport(rt http.RoundTripper, timeout time.Duration) http.RoundTripper {
+	if timeout <= 0 {
+		return rt
+	}
+	return RoundTripperFn(func(req *http.Request) (*http.Response, error) {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		res, err := rt.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		// The timeout also bounds the read of the body
+		res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+		return res, nil
+	})
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
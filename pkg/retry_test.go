@@ -0,0 +1,203 @@
+package pkg
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryConfig() *RetryConfig {
+	return &RetryConfig{
+		InitialInterval: time.Millisecond,
+		MaxInterval:     10 * time.Millisecond,
+		MaxElapsedTime:  time.Second,
+		Exponent:        2,
+		StatusCodes:     DefaultRetryStatusCodes,
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	for _, tt := range []struct {
+		name             string
+		config           *RetryConfig
+		method           string
+		responses        []int
+		headers          http.Header
+		expectedStatus   int
+		expectedAttempts int32
+	}{
+		{
+			name:             "retry until success",
+			config:           testRetryConfig(),
+			method:           http.MethodPut,
+			responses:        []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "non idempotent request not retried on server errors",
+			config:           testRetryConfig(),
+			method:           http.MethodPost,
+			responses:        []int{http.StatusServiceUnavailable, http.StatusOK},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "non idempotent request retried when rate limited",
+			config:           testRetryConfig(),
+			method:           http.MethodPost,
+			responses:        []int{http.StatusTooManyRequests, http.StatusOK},
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "status code not retried",
+			config:           testRetryConfig(),
+			method:           http.MethodGet,
+			responses:        []int{http.StatusConflict, http.StatusOK},
+			expectedStatus:   http.StatusConflict,
+			expectedAttempts: 1,
+		},
+		{
+			name: "configured status code",
+			config: func() *RetryConfig {
+				config := testRetryConfig()
+				config.StatusCodes = []int{http.StatusConflict}
+				return config
+			}(),
+			method:           http.MethodGet,
+			responses:        []int{http.StatusConflict, http.StatusOK},
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "retries disabled",
+			method:           http.MethodGet,
+			responses:        []int{http.StatusServiceUnavailable, http.StatusOK},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retry after exceeding the max elapsed time",
+			config:           testRetryConfig(),
+			method:           http.MethodGet,
+			responses:        []int{http.StatusTooManyRequests, http.StatusOK},
+			headers:          http.Header{"Retry-After": []string{"60"}},
+			expectedStatus:   http.StatusTooManyRequests,
+			expectedAttempts: 1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := attempts.Add(1)
+				if r.Method != http.MethodGet {
+					body, err := io.ReadAll(r.Body)
+					require.NoError(t, err)
+					require.Equal(t, "body", string(body))
+				}
+				for k, v := range tt.headers {
+					w.Header()[k] = v
+				}
+				w.WriteHeader(tt.responses[attempt-1])
+			}))
+			t.Cleanup(server.Close)
+
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader("body"))
+			require.NoError(t, err)
+
+			res, err := NewRetryTransport(http.DefaultTransport, tt.config).RoundTrip(req)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, tt.expectedStatus, res.StatusCode)
+			require.Equal(t, tt.expectedAttempts, attempts.Load())
+		})
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	config := testRetryConfig()
+	config.MaxElapsedTime = 5 * time.Second
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	res, err := NewRetryTransport(http.DefaultTransport, config).RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestRetryTransportRequestTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	res, err := NewRetryTransport(NewTimeoutTransport(http.DefaultTransport, 50*time.Millisecond), testRetryConfig()).RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, int32(2), attempts.Load())
+}
+
+func TestRetryTransportConnectionErrors(t *testing.T) {
+	for _, tt := range []struct {
+		method           string
+		expectedAttempts int32
+	}{
+		{method: http.MethodGet, expectedAttempts: 2},
+		{method: http.MethodPost, expectedAttempts: 1},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts atomic.Int32
+			transport := RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+				if attempts.Add(1) == 1 {
+					return nil, io.ErrUnexpectedEOF
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			})
+
+			req, err := http.NewRequest(tt.method, "http://localhost", http.NoBody)
+			require.NoError(t, err)
+
+			res, err := NewRetryTransport(transport, testRetryConfig()).RoundTrip(req)
+			if tt.expectedAttempts == 1 {
+				require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, http.StatusOK, res.StatusCode)
+			}
+			require.Equal(t, tt.expectedAttempts, attempts.Load())
+		})
+	}
+}
@@ -2,6 +2,8 @@ package pkg
 
 import (
 	"net/http"
+
+	"github.com/formancehq/go-libs/v3/logging"
 )
 
 type Creds interface {
@@ -16,13 +18,47 @@ type RoundTripperFn func(r *http.Request) (*http.Response, error)
 func (fn RoundTripperFn) RoundTrip(req *http.Request) (*http.Response, error) {
 	return fn(req)
 }
+
+// NewTransport authenticates the requests with a token of the provider.
+// When the API answers 401, the token is invalidated and the request retried once with a fresh one,
+// so that revoked tokens or rotated secrets do not fail the whole run.
 func NewTransport(rt http.RoundTripper, tp TokenProviderImpl) RoundTripperFn {
 	return func(r *http.Request) (*http.Response, error) {
 		token, err := tp.RefreshToken(r.Context())
 		if err != nil {
 			return nil, err
 		}
-		r.Header.Set("Authorization", "Bearer "+token.AccessToken)
-		return rt.RoundTrip(r)
+		req := r.Clone(r.Context())
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		res, err := rt.RoundTrip(req)
+		if err != nil || res.StatusCode != http.StatusUnauthorized {
+			return res, err
+		}
+
+		// The body has been consumed by the first attempt
+		if r.Body != nil && r.Body != http.NoBody {
+			if r.GetBody == nil {
+				return res, nil
+			}
+			body, err := r.GetBody()
+			if err != nil {
+				return res, nil
+			}
+			req = r.Clone(r.Context())
+			req.Body = body
+		} else {
+			req = r.Clone(r.Context())
+		}
+
+		logging.FromContext(r.Context()).Debugf("Token rejected by %s, retrying with a fresh one", r.URL.Path)
+		tp.InvalidateToken(token.AccessToken)
+		_ = res.Body.Close()
+		token, err = tp.RefreshToken(r.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		return rt.RoundTrip(req)
 	}
 }
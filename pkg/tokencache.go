@@ -0,0 +1,162 @@
+package pkg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// TokenCache stores access tokens across provider invocations.
+type TokenCache interface {
+	// Get returns the token stored under the key, or nil if there is none.
+	Get(key string) (*TokenInfo, error)
+	Set(key string, token *TokenInfo) error
+	Delete(key string) error
+}
+
+// TokenCacheKey returns the key identifying the tokens issued for the credentials, scopes and parameters.
+func TokenCacheKey(creds Creds, scopes []string, opts ...UrlOpts) string {
+	params := url.Values{}
+	for _, opt := range opts {
+		opt(params)
+	}
+	sorted := slices.Clone(scopes)
+	slices.Sort(sorted)
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		creds.Endpoint(),
+		creds.ClientId(),
+		strings.Join(sorted, " "),
+		params.Encode(),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// DefaultTokenCacheDir returns the directory of the token cache, under ~/.formance.
+func DefaultTokenCacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".formance", "token-cache"), nil
+}
+
+var _ TokenCache = &FileTokenCache{}
+
+// FileTokenCache stores each token in its own file, readable by the current user only.
+// Tokens are encrypted with AES-GCM when an encryption key is set.
+type FileTokenCache struct {
+	dir  string
+	aead cipher.AEAD
+}
+
+// NewFileTokenCache creates a cache in dir. An empty encryptionKey stores the tokens in clear.
+func NewFileTokenCache(dir string, encryptionKey string) (*FileTokenCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	cache := &FileTokenCache{
+		dir: dir,
+	}
+	if encryptionKey != "" {
+		key := sha256.Sum256([]byte(encryptionKey))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, err
+		}
+		cache.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cache, nil
+}
+
+func (c *FileTokenCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *FileTokenCache) Get(key string) (*TokenInfo, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if c.aead != nil {
+		nonceSize := c.aead.NonceSize()
+		if len(data) < nonceSize {
+			return nil, fmt.Errorf("token cache entry %s is corrupted", key)
+		}
+		data, err = c.aead.Open(nil, data[:nonceSize], data[nonceSize:], []byte(key))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt token cache entry %s: %w", key, err)
+		}
+	}
+
+	token := &TokenInfo{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, fmt.Errorf("unable to decode token cache entry %s: %w", key, err)
+	}
+	return token, nil
+}
+
+func (c *FileTokenCache) Set(key string, token *TokenInfo) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	if c.aead != nil {
+		nonce := make([]byte, c.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		data = c.aead.Seal(nonce, nonce, data, []byte(key))
+	}
+
+	// Write then rename, so that concurrent invocations never read a partial entry
+	file, err := os.CreateTemp(c.dir, ".token-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if err := file.Chmod(0600); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), c.path(key))
+}
+
+func (c *FileTokenCache) Delete(key string) error {
+	if err := os.Remove(c.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTokenCache(t *testing.T) {
+	t.Parallel()
+
+	for _, encryptionKey := range []string{"", "secret"} {
+		t.Run("encryption key "+encryptionKey, func(t *testing.T) {
+			t.Parallel()
+
+			dir := filepath.Join(t.TempDir(), "token-cache")
+			cache, err := NewFileTokenCache(dir, encryptionKey)
+			require.NoError(t, err)
+
+			token, err := cache.Get("key")
+			require.NoError(t, err)
+			require.Nil(t, token)
+
+			expected := &TokenInfo{
+				AccessToken: "access-token",
+				Expiry:      time.Now().Add(time.Hour).Round(0).UTC(),
+			}
+			require.NoError(t, cache.Set("key", expected))
+
+			stat, err := os.Stat(filepath.Join(dir, "key.json"))
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+			data, err := os.ReadFile(filepath.Join(dir, "key.json"))
+			require.NoError(t, err)
+			if encryptionKey != "" {
+				require.NotContains(t, string(data), "access-token")
+			}
+
+			token, err = cache.Get("key")
+			require.NoError(t, err)
+			require.Equal(t, expected, token)
+
+			require.NoError(t, cache.Delete("key"))
+			token, err = cache.Get("key")
+			require.NoError(t, err)
+			require.Nil(t, token)
+		})
+	}
+}
+
+func TestFileTokenCacheWrongKey(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cache, err := NewFileTokenCache(dir, "secret")
+	require.NoError(t, err)
+	require.NoError(t, cache.Set("key", &TokenInfo{AccessToken: "access-token"}))
+
+	cache, err = NewFileTokenCache(dir, "other")
+	require.NoError(t, err)
+	_, err = cache.Get("key")
+	require.Error(t, err)
+}
+
+func TestTokenProviderSharesCachedToken(t *testing.T) {
+	t.Parallel()
+
+	server := newOIDCServer(t, 3600, nil)
+	cache, err := NewFileTokenCache(t.TempDir(), "secret")
+	require.NoError(t, err)
+	factory := NewCachedTokenProviderFactory(cache)
+
+	first, err := factory(http.DefaultTransport, testCreds{server.URL}, ScopeCloud).RefreshToken(context.Background())
+	require.NoError(t, err)
+
+	// A new provider, as in a new invocation of terraform, reuses the token
+	tp := factory(http.DefaultTransport, testCreds{server.URL}, ScopeCloud)
+	second, err := tp.RefreshToken(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, first.AccessToken, second.AccessToken)
+	require.Equal(t, int32(1), server.tokens.Load())
+	require.Equal(t, int32(1), server.discoveries.Load())
+
+	// Other scopes do not share the token
+	_, err = factory(http.DefaultTransport, testCreds{server.URL}, ScopeStack).RefreshToken(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int32(2), server.tokens.Load())
+
+	// Rejected tokens are removed from the cache
+	tp.InvalidateToken(second.AccessToken)
+	third, err := factory(http.DefaultTransport, testCreds{server.URL}, ScopeCloud).RefreshToken(context.Background())
+	require.NoError(t, err)
+	require.NotEqual(t, second.AccessToken, third.AccessToken)
+}
@@ -2,17 +2,21 @@ package pkg
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/formancehq/go-libs/v3/logging"
 	"github.com/formancehq/terraform-provider-cloud/pkg/otlp"
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/zitadel/oidc/v3/pkg/client/rp"
+	"github.com/zitadel/oidc/v3/pkg/client"
 	"github.com/zitadel/oidc/v3/pkg/oidc"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
+	"golang.org/x/sync/singleflight"
 )
 
 //go:generate mockgen -typed -destination=tokenprovider_generated.go -package=pkg . TokenProviderImpl
@@ -20,16 +24,55 @@ type TokenProviderImpl interface {
 	AccessToken(ctx context.Context) (*TokenInfo, error)
 	RefreshToken(ctx context.Context) (*TokenInfo, error)
 	OrganizationId(ctx context.Context) (string, error)
+	// InvalidateToken drops the cached token if it is the given one, so that the next call fetches a new one.
+	InvalidateToken(accessToken string)
 }
 
 type TokenInfo struct {
-	sync.Mutex
-
 	AccessToken  string    `json:"access_token"`
 	RefreshToken string    `json:"refresh_token"`
 	Expiry       time.Time `json:"expiry"`
 }
 
+// valid reports whether the token can still be used for at least the skew duration.
+// Tokens without expiry never expire.
+func (t *TokenInfo) valid(skew time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(skew).Before(t.Expiry)
+}
+
+// Scopes returns the scopes granted to the token, read from its scope or scp claim.
+// It reports false when the token is not a JWT or has no such claim.
+func (t *TokenInfo) Scopes() ([]string, bool) {
+	var claims jwt.MapClaims
+	if _, err := oidc.ParseToken(t.AccessToken, &claims); err != nil {
+		return nil, false
+	}
+
+	if scopes, ok := claims["scope"].(string); ok {
+		return strings.Fields(scopes), true
+	}
+	switch scopes := claims["scp"].(type) {
+	case string:
+		return strings.Fields(scopes), true
+	case []any:
+		ret := make([]string, 0, len(scopes))
+		for _, scope := range scopes {
+			if scope, ok := scope.(string); ok {
+				ret = append(ret, scope)
+			}
+		}
+		return ret, true
+	}
+	return nil, false
+}
+
+// DefaultTokenExpirySkew is the margin before expiry at which tokens are refreshed,
+// so that a token is never sent when about to expire.
+var DefaultTokenExpirySkew = time.Minute
+
 var (
 	_ TokenProviderImpl = &TokenProvider{}
 )
@@ -39,10 +82,15 @@ type TokenProvider struct {
 
 	creds Creds
 
-	cloud *TokenInfo
-
 	scopes []string
 	opts   []UrlOpts
+	skew   time.Duration
+	cache  TokenCache
+
+	mu        sync.Mutex
+	token     *TokenInfo
+	discovery *oidc.DiscoveryConfiguration
+	group     singleflight.Group
 }
 
 type TokenProviderFactory func(transport http.RoundTripper, creds Creds, scopes []string, opts ...UrlOpts) TokenProviderImpl
@@ -56,14 +104,24 @@ func WithResource(resource string) UrlOpts {
 }
 
 func NewTokenProvider(transport http.RoundTripper, creds Creds, scopes []string, opts ...UrlOpts) TokenProviderImpl {
-	return TokenProvider{
+	return &TokenProvider{
 		client: &http.Client{
 			Transport: transport,
 		},
-		cloud:  &TokenInfo{},
 		creds:  creds,
 		scopes: scopes,
 		opts:   opts,
+		skew:   DefaultTokenExpirySkew,
+	}
+}
+
+// NewCachedTokenProviderFactory returns a factory of token providers sharing their tokens
+// through the cache, so that they are reused across provider invocations while valid.
+func NewCachedTokenProviderFactory(cache TokenCache) TokenProviderFactory {
+	return func(transport http.RoundTripper, creds Creds, scopes []string, opts ...UrlOpts) TokenProviderImpl {
+		tp := NewTokenProvider(transport, creds, scopes, opts...).(*TokenProvider)
+		tp.cache = cache
+		return tp
 	}
 }
 
@@ -98,68 +156,32 @@ var (
 	}
 )
 
-func (p TokenProvider) AccessToken(ctx context.Context) (*TokenInfo, error) {
+// AccessToken returns the cached token, fetching a new one when it is missing or about to expire.
+func (p *TokenProvider) AccessToken(ctx context.Context) (*TokenInfo, error) {
 	ctx, span := otlp.Tracer.Start(ctx, "AccessToken")
 	defer span.End()
-	p.cloud.Lock()
-	defer p.cloud.Unlock()
-
-	if p.cloud.AccessToken != "" {
-		return &TokenInfo{
-			AccessToken:  p.cloud.AccessToken,
-			RefreshToken: p.cloud.RefreshToken,
-			Expiry:       p.cloud.Expiry,
-		}, nil
-	}
 
-	logger := logging.FromContext(ctx).WithField("operation", "accesstoken")
-	logger.Debugf("Getting access token for %s", p.creds.Endpoint())
-	defer logger.Debugf("Getting access token done")
-
-	rp, err := rp.NewRelyingPartyOIDC(ctx,
-		p.creds.Endpoint(),
-		p.creds.ClientId(),
-		p.creds.ClientSecret(),
-		"",
-		p.scopes,
-		rp.WithHTTPClient(p.client),
-	)
-	if err != nil {
-		logger.Errorf("Unable to create OIDC client: %s", err.Error())
-		return nil, err
+	if token := p.cached(); token.valid(p.skew) {
+		return token, nil
 	}
 
-	config := &clientcredentials.Config{
-		Scopes:         rp.OAuthConfig().Scopes,
-		ClientID:       rp.OAuthConfig().ClientID,
-		ClientSecret:   rp.OAuthConfig().ClientSecret,
-		TokenURL:       rp.OAuthConfig().Endpoint.TokenURL,
-		EndpointParams: make(url.Values),
-	}
-
-	for _, opt := range p.opts {
-		opt(config.EndpointParams)
-	}
+	return p.fetch(ctx)
+}
 
-	t, err := (config).Token(ctx)
+// RefreshToken returns a token valid for at least the skew duration, refreshing it ahead of expiry.
+func (p *TokenProvider) RefreshToken(ctx context.Context) (*TokenInfo, error) {
+	ctx, span := otlp.Tracer.Start(ctx, "RefreshToken")
+	defer span.End()
 
-	if err != nil {
-		logger.Errorf("Unable to get token: %s", err.Error())
-		return nil, err
+	if token := p.cached(); token.valid(p.skew) {
+		return token, nil
 	}
 
-	p.cloud.AccessToken = t.AccessToken
-	p.cloud.Expiry = t.Expiry
-	p.cloud.RefreshToken = t.RefreshToken
-
-	return &TokenInfo{
-		AccessToken:  t.AccessToken,
-		RefreshToken: t.RefreshToken,
-		Expiry:       t.Expiry,
-	}, nil
+	logging.FromContext(ctx).Debugf("Refreshing token for %s", p.creds.Endpoint())
+	return p.fetch(ctx)
 }
 
-func (p TokenProvider) OrganizationId(ctx context.Context) (string, error) {
+func (p *TokenProvider) OrganizationId(ctx context.Context) (string, error) {
 	accessToken, err := p.AccessToken(ctx)
 	if err != nil {
 		return "", err
@@ -171,45 +193,176 @@ func (p TokenProvider) OrganizationId(ctx context.Context) (string, error) {
 		return "", err
 	}
 
-	organizationId := claims["organization_id"].(string)
+	organizationId, ok := claims["organization_id"].(string)
+	if !ok || organizationId == "" {
+		return "", fmt.Errorf("the access token has no organization_id claim, check that the client belongs to an organization")
+	}
 	return organizationId, nil
+}
+
+func (p *TokenProvider) InvalidateToken(accessToken string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The token may have already been replaced by a concurrent refresh
+	if p.token != nil && p.token.AccessToken == accessToken {
+		p.token = nil
+	}
 
+	if p.cache != nil {
+		key := p.cacheKey()
+		if token, _ := p.cache.Get(key); token != nil && token.AccessToken == accessToken {
+			_ = p.cache.Delete(key)
+		}
+	}
 }
 
-func (p TokenProvider) RefreshToken(ctx context.Context) (*TokenInfo, error) {
-	ctx, span := otlp.Tracer.Start(ctx, "RefreshToken")
-	defer span.End()
-	logging.FromContext(ctx).Debugf("Getting refresh token for %s", p.creds.Endpoint())
+func (p *TokenProvider) cacheKey() string {
+	return TokenCacheKey(p.creds, p.scopes, p.opts...)
+}
 
-	tokenInfo, err := p.AccessToken(ctx)
+// cached returns a copy of the cached token, or nil.
+func (p *TokenProvider) cached() *TokenInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token == nil {
+		return nil
+	}
+	token := *p.token
+	return &token
+}
+
+// fetch requests a new token. Concurrent calls share the same request.
+func (p *TokenProvider) fetch(ctx context.Context) (*TokenInfo, error) {
+	v, err, _ := p.group.Do("token", func() (any, error) {
+		// Another caller may have refreshed the token while this one was waiting
+		if token := p.cached(); token.valid(p.skew) {
+			return token, nil
+		}
+
+		// Another invocation of the provider may have stored a valid token
+		if token := p.loadCache(ctx); token.valid(p.skew) {
+			p.mu.Lock()
+			p.token = token
+			p.mu.Unlock()
+			return token, nil
+		}
+
+		// The request is shared, it must not be canceled with the context of the first caller
+		token, err := p.requestToken(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		p.token = token
+		p.mu.Unlock()
+
+		p.storeCache(ctx, token)
+
+		return token, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if time.Now().Before(tokenInfo.Expiry) {
-		return &TokenInfo{
-			AccessToken:  tokenInfo.AccessToken,
-			RefreshToken: tokenInfo.RefreshToken,
-			Expiry:       tokenInfo.Expiry,
-		}, nil
+	token := *v.(*TokenInfo)
+	return &token, nil
+}
+
+func (p *TokenProvider) loadCache(ctx context.Context) *TokenInfo {
+	if p.cache == nil {
+		return nil
 	}
 
-	p.cloud.Lock()
-	p.cloud.AccessToken = ""
-	p.cloud.Unlock()
-	tokenInfo, err = p.AccessToken(ctx)
+	token, err := p.cache.Get(p.cacheKey())
 	if err != nil {
-		return nil, err
+		logging.FromContext(ctx).Errorf("Unable to read the token cache, ignoring it: %s", err)
+		return nil
+	}
+	if token != nil {
+		logging.FromContext(ctx).Debugf("Using cached token for %s", p.creds.Endpoint())
 	}
+	return token
+}
 
-	p.cloud.AccessToken = tokenInfo.AccessToken
-	p.cloud.Expiry = tokenInfo.Expiry
-	p.cloud.RefreshToken = tokenInfo.RefreshToken
+func (p *TokenProvider) storeCache(ctx context.Context, token *TokenInfo) {
+	if p.cache == nil {
+		return
+	}
+
+	if err := p.cache.Set(p.cacheKey(), token); err != nil {
+		logging.FromContext(ctx).Errorf("Unable to write the token cache: %s", err)
+	}
+}
+
+func (p *TokenProvider) requestToken(ctx context.Context) (*TokenInfo, error) {
+	logger := logging.FromContext(ctx).WithField("operation", "accesstoken")
+	logger.Debugf("Getting access token for %s", p.creds.Endpoint())
+	defer logger.Debugf("Getting access token done")
+
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		logger.Errorf("Unable to discover OIDC configuration: %s", err.Error())
+		return nil, &AuthError{Reason: AuthReasonTokenEndpoint, Endpoint: p.creds.Endpoint(), err: err}
+	}
+
+	config := &clientcredentials.Config{
+		Scopes:         p.scopes,
+		ClientID:       p.creds.ClientId(),
+		ClientSecret:   p.creds.ClientSecret(),
+		TokenURL:       discovery.TokenEndpoint,
+		EndpointParams: make(url.Values),
+	}
+
+	for _, opt := range p.opts {
+		opt(config.EndpointParams)
+	}
+
+	if creds, ok := p.creds.(ClientAssertionCreds); ok && !creds.ClientAssertion().IsZero() {
+		assertion, err := creds.ClientAssertion().Token(config.ClientID, discovery.Issuer, discovery.TokenEndpoint)
+		if err != nil {
+			logger.Errorf("Unable to get client assertion: %s", err.Error())
+			return nil, &AuthError{Reason: AuthReasonClientAssertion, Endpoint: p.creds.Endpoint(), err: err}
+		}
+		// The assertion replaces the client secret
+		config.ClientSecret = ""
+		config.AuthStyle = oauth2.AuthStyleInParams
+		config.EndpointParams.Set("client_assertion_type", ClientAssertionType)
+		config.EndpointParams.Set("client_assertion", assertion)
+	}
+
+	t, err := config.Token(context.WithValue(ctx, oauth2.HTTPClient, p.client))
+	if err != nil {
+		logger.Errorf("Unable to get token: %s", err.Error())
+		return nil, newTokenError(p.creds.Endpoint(), err)
+	}
 
 	return &TokenInfo{
-		AccessToken:  tokenInfo.AccessToken,
-		RefreshToken: tokenInfo.RefreshToken,
-		Expiry:       tokenInfo.Expiry,
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		Expiry:       t.Expiry,
 	}, nil
+}
+
+// discover returns the OIDC discovery document of the endpoint, fetched once per provider.
+func (p *TokenProvider) discover(ctx context.Context) (*oidc.DiscoveryConfiguration, error) {
+	p.mu.Lock()
+	discovery := p.discovery
+	p.mu.Unlock()
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	discovery, err := client.Discover(ctx, p.creds.Endpoint(), p.client)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.discovery = discovery
+	p.mu.Unlock()
 
+	return discovery, nil
 }
@@ -79,6 +79,42 @@ func (c *MockTokenProviderImplAccessTokenCall) DoAndReturn(f func(context.Contex
 	return c
 }
 
+// InvalidateToken mocks base method.
+func (m *MockTokenProviderImpl) InvalidateToken(accessToken string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "InvalidateToken", accessToken)
+}
+
+// InvalidateToken indicates an expected call of InvalidateToken.
+func (mr *MockTokenProviderImplMockRecorder) InvalidateToken(accessToken any) *MockTokenProviderImplInvalidateTokenCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateToken", reflect.TypeOf((*MockTokenProviderImpl)(nil).InvalidateToken), accessToken)
+	return &MockTokenProviderImplInvalidateTokenCall{Call: call}
+}
+
+// MockTokenProviderImplInvalidateTokenCall wrap *gomock.Call
+type MockTokenProviderImplInvalidateTokenCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockTokenProviderImplInvalidateTokenCall) Return() *MockTokenProviderImplInvalidateTokenCall {
+	c.Call = c.Call.Return()
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockTokenProviderImplInvalidateTokenCall) Do(f func(string)) *MockTokenProviderImplInvalidateTokenCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockTokenProviderImplInvalidateTokenCall) DoAndReturn(f func(string)) *MockTokenProviderImplInvalidateTokenCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // OrganizationId mocks base method.
 func (m *MockTokenProviderImpl) OrganizationId(ctx context.Context) (string, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,239 @@
+package pkg
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCreds struct {
+	endpoint string
+}
+
+func (c testCreds) ClientId() string     { return "client" }
+func (c testCreds) ClientSecret() string { return "secret" }
+func (c testCreds) Endpoint() string     { return c.endpoint }
+func (c testCreds) UserAgent() string    { return "test" }
+
+type oidcServer struct {
+	*httptest.Server
+	discoveries atomic.Int32
+	tokens      atomic.Int32
+	expiresIn   int
+	claims      map[string]any
+	release     chan struct{}
+
+	mu    sync.Mutex
+	forms []url.Values
+}
+
+func newOIDCServer(t *testing.T, expiresIn int, claims map[string]any) *oidcServer {
+	s := &oidcServer{
+		expiresIn: expiresIn,
+		claims:    claims,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		s.discoveries.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":         s.URL,
+			"token_endpoint": s.URL + "/oauth/token",
+		})
+	})
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		n := s.tokens.Add(1)
+		_ = r.ParseForm()
+		s.mu.Lock()
+		s.forms = append(s.forms, r.PostForm)
+		s.mu.Unlock()
+		if s.release != nil {
+			<-s.release
+		}
+		payload, _ := json.Marshal(s.claims)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": fmt.Sprintf("e30.%s.%d", base64.RawURLEncoding.EncodeToString(payload), n),
+			"token_type":   "Bearer",
+			"expires_in":   s.expiresIn,
+		})
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestTokenProviderCachesToken(t *testing.T) {
+	t.Parallel()
+
+	server := newOIDCServer(t, 3600, nil)
+	tp := NewTokenProvider(http.DefaultTransport, testCreds{server.URL}, ScopeCloud)
+
+	first, err := tp.RefreshToken(context.Background())
+	require.NoError(t, err)
+	second, err := tp.RefreshToken(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, first.AccessToken, second.AccessToken)
+	require.Equal(t, int32(1), server.tokens.Load())
+	require.Equal(t, int32(1), server.discoveries.Load())
+}
+
+func TestTokenProviderRefreshesAheadOfExpiry(t *testing.T) {
+	t.Parallel()
+
+	// The token expires within the skew margin, so it must be refreshed on every call
+	server := newOIDCServer(t, int(DefaultTokenExpirySkew/time.Second)/2, nil)
+	tp := NewTokenProvider(http.DefaultTransport, testCreds{server.URL}, ScopeCloud)
+
+	first, err := tp.RefreshToken(context.Background())
+	require.NoError(t, err)
+	second, err := tp.RefreshToken(context.Background())
+	require.NoError(t, err)
+
+	require.NotEqual(t, first.AccessToken, second.AccessToken)
+	require.Equal(t, int32(2), server.tokens.Load())
+	require.Equal(t, int32(1), server.discoveries.Load())
+}
+
+func TestTokenProviderConcurrentRefresh(t *testing.T) {
+	t.Parallel()
+
+	server := newOIDCServer(t, 3600, nil)
+	server.release = make(chan struct{})
+	tp := NewTokenProvider(http.DefaultTransport, testCreds{server.URL}, ScopeCloud)
+
+	wg := sync.WaitGroup{}
+	tokens := make([]*TokenInfo, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := tp.RefreshToken(context.Background())
+			require.NoError(t, err)
+			tokens[i] = token
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return server.tokens.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+	close(server.release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), server.tokens.Load())
+	for _, token := range tokens {
+		require.Equal(t, tokens[0].AccessToken, token.AccessToken)
+	}
+}
+
+func TestTokenProviderOrganizationId(t *testing.T) {
+	t.Parallel()
+
+	server := newOIDCServer(t, 3600, map[string]any{"organization_id": "org"})
+	tp := NewTokenProvider(http.DefaultTransport, testCreds{server.URL}, ScopeCloud)
+
+	organizationId, err := tp.OrganizationId(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "org", organizationId)
+
+	server = newOIDCServer(t, 3600, map[string]any{"sub": "client"})
+	tp = NewTokenProvider(http.DefaultTransport, testCreds{server.URL}, ScopeCloud)
+
+	_, err = tp.OrganizationId(context.Background())
+	require.ErrorContains(t, err, "organization_id")
+}
+
+func TestTokenProviderInvalidCredentials(t *testing.T) {
+	t.Parallel()
+
+	server := newOIDCServer(t, 3600, nil)
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":         server.URL,
+			"token_endpoint": server.URL + "/oauth/token",
+		})
+	})
+	tp := NewTokenProvider(http.DefaultTransport, testCreds{server.URL}, ScopeCloud)
+
+	_, err := tp.RefreshToken(context.Background())
+	require.ErrorIs(t, err, ErrUnauthorized)
+	authErr := &AuthError{}
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, AuthReasonClientCredentials, authErr.Reason)
+
+	tp = NewTokenProvider(http.DefaultTransport, testCreds{"http://127.0.0.1:1"}, ScopeCloud)
+	_, err = tp.RefreshToken(context.Background())
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, AuthReasonTokenEndpoint, authErr.Reason)
+}
+
+func TestTransportRetriesOnUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	server := newOIDCServer(t, 3600, nil)
+	tp := NewTokenProvider(http.DefaultTransport, testCreds{server.URL}, ScopeCloud)
+
+	// The first token is revoked
+	revoked, err := tp.RefreshToken(context.Background())
+	require.NoError(t, err)
+
+	bodies := make([]string, 0)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if r.Header.Get("Authorization") == "Bearer "+revoked.AccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(api.Close)
+
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport, tp)}
+	res, err := client.Post(api.URL, "application/json", strings.NewReader(`{"name":"stack"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, []string{`{"name":"stack"}`, `{"name":"stack"}`}, bodies)
+	require.Equal(t, int32(2), server.tokens.Load())
+}
+
+func TestTokenInfoScopes(t *testing.T) {
+	t.Parallel()
+
+	token := func(claims map[string]any) *TokenInfo {
+		payload, _ := json.Marshal(claims)
+		return &TokenInfo{AccessToken: "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"}
+	}
+
+	scopes, ok := token(map[string]any{"scope": "organization:Read organization:ReadStack"}).Scopes()
+	require.True(t, ok)
+	require.Equal(t, []string{"organization:Read", "organization:ReadStack"}, scopes)
+
+	scopes, ok = token(map[string]any{"scp": []string{"organization:Read"}}).Scopes()
+	require.True(t, ok)
+	require.Equal(t, []string{"organization:Read"}, scopes)
+
+	_, ok = token(map[string]any{"sub": "client"}).Scopes()
+	require.False(t, ok)
+
+	_, ok = (&TokenInfo{AccessToken: "opaque"}).Scopes()
+	require.False(t, ok)
+}
@@ -40,6 +40,7 @@ func TestStackModule(t *testing.T) {
 
 						version = "default"
 						force_destroy = true
+						deletion_protection = false
 					}
 
 					resource "cloud_stack_module" "default_webhooks" {
@@ -41,6 +41,7 @@ func TestStack(t *testing.T) {
 							"env" = "test"
 						}
 						force_destroy = true
+						deletion_protection = false
 					}
 				`, RegionName),
 				Check: resource.ComposeAggregateTestCheckFunc(
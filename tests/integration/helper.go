@@ -4,15 +4,19 @@ import (
 	"net/http"
 
 	"github.com/formancehq/terraform-provider-cloud/pkg"
+	membershipclient "github.com/formancehq/terraform-provider-cloud/pkg/membership_client"
+	"go.uber.org/mock/gomock"
 )
 
-func NewCloudSdkMockT(mock *pkg.MockCloudSDK) func(endpoint string, transport http.RoundTripper) pkg.CloudSDK {
-	return func(endpoint string, transport http.RoundTripper) pkg.CloudSDK {
+func NewCloudSdkMockT(mock *pkg.MockCloudSDK) func(endpoint string, transport http.RoundTripper, opts ...membershipclient.SDKOption) pkg.CloudSDK {
+	return func(endpoint string, transport http.RoundTripper, opts ...membershipclient.SDKOption) pkg.CloudSDK {
 		return mock
 	}
 }
 
 func NewCloudTokenProviderMockT(mock *pkg.MockTokenProviderImpl) func(transport http.RoundTripper, creds pkg.Creds, scopes []string, opts ...pkg.UrlOpts) pkg.TokenProviderImpl {
+	// The token does not advertise its scopes, so that the scopes are not checked
+	mock.EXPECT().AccessToken(gomock.Any()).Return(&pkg.TokenInfo{AccessToken: "token"}, nil).AnyTimes()
 	return func(transport http.RoundTripper, creds pkg.Creds, scopes []string, opts ...pkg.UrlOpts) pkg.TokenProviderImpl {
 		return mock
 	}
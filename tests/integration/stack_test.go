@@ -1,9 +1,11 @@
 package integration_test
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"net/http"
+	"regexp"
+	"sync"
 	"testing"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/formancehq/terraform-provider-cloud/internal/server"
 	"github.com/formancehq/terraform-provider-cloud/pkg"
 	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/operations"
+	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/sdkerrors"
 	"github.com/formancehq/terraform-provider-cloud/pkg/membership_client/pkg/models/shared"
 	"github.com/google/uuid"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
@@ -42,6 +45,7 @@ func TestStack(t *testing.T) {
 							"env" = "test"
 						}
 						force_destroy = true
+						deletion_protection = false
 					}
 					`,
 				},
@@ -53,7 +57,8 @@ func TestStack(t *testing.T) {
 				stackID := uuid.NewString()
 				md := map[string]string{
 					"env": "test",
-					"github.com/formancehq/terraform-provider-cloud/protected": "true",
+					"github.com/formancehq/terraform-provider-cloud/protected":           "true",
+					"github.com/formancehq/terraform-provider-cloud/deletion_protection": "false",
 				}
 				now := time.Now()
 				stackData := &shared.Stack{
@@ -75,6 +80,15 @@ func TestStack(t *testing.T) {
 					Synchronised:             true,
 					Modules:                  []shared.Module{},
 				}
+				// The creation first looks for a stack created by a previous apply whose response was lost
+				cloudSdk.EXPECT().ListStacks(gomock.Any(), organizationID).
+					Return(&operations.ListStacksResponse{
+						StatusCode:  http.StatusOK,
+						RawResponse: &http.Response{StatusCode: http.StatusOK},
+						ListStacksResponse: &shared.ListStacksResponse{
+							Data: []shared.Stack{},
+						},
+					}, nil)
 				cloudSdk.EXPECT().CreateStack(gomock.Any(), organizationID, gomock.Any()).
 					Return(&operations.CreateStackResponse{
 						StatusCode:  http.StatusCreated,
@@ -90,7 +104,10 @@ func TestStack(t *testing.T) {
 						CreateStackResponse: &shared.CreateStackResponse{
 							Data: stackData,
 						},
-					}, nil)
+					}, nil).
+					// Read refreshes the stack, the plans check its ownership unless it was refreshed
+					// by the same provider, and Delete checks it again before deleting
+					MinTimes(2)
 				cloudSdk.EXPECT().DeleteStack(gomock.Any(), organizationID, stackID, true).Return(&operations.DeleteStackResponse{
 					StatusCode:  http.StatusNoContent,
 					RawResponse: &http.Response{StatusCode: http.StatusNoContent},
@@ -152,6 +169,7 @@ func TestStackAlreadyDeleted(t *testing.T) {
 							"env" = "test"
 						}
 						force_destroy = true
+						deletion_protection = false
 					}
 					`,
 				},
@@ -163,7 +181,8 @@ func TestStackAlreadyDeleted(t *testing.T) {
 				stackID := uuid.NewString()
 				md := map[string]string{
 					"env": "test",
-					"github.com/formancehq/terraform-provider-cloud/protected": "true",
+					"github.com/formancehq/terraform-provider-cloud/protected":           "true",
+					"github.com/formancehq/terraform-provider-cloud/deletion_protection": "false",
 				}
 				now := time.Now()
 				stackData := &shared.Stack{
@@ -185,6 +204,15 @@ func TestStackAlreadyDeleted(t *testing.T) {
 					Synchronised:             true,
 					Modules:                  []shared.Module{},
 				}
+				// The creation first looks for a stack created by a previous apply whose response was lost
+				cloudSdk.EXPECT().ListStacks(gomock.Any(), organizationID).
+					Return(&operations.ListStacksResponse{
+						StatusCode:  http.StatusOK,
+						RawResponse: &http.Response{StatusCode: http.StatusOK},
+						ListStacksResponse: &shared.ListStacksResponse{
+							Data: []shared.Stack{},
+						},
+					}, nil)
 				cloudSdk.EXPECT().CreateStack(gomock.Any(), organizationID, gomock.Any()).
 					Return(&operations.CreateStackResponse{
 						StatusCode:  http.StatusCreated,
@@ -200,11 +228,15 @@ func TestStackAlreadyDeleted(t *testing.T) {
 						CreateStackResponse: &shared.CreateStackResponse{
 							Data: stackData,
 						},
-					}, nil)
-				cloudSdk.EXPECT().DeleteStack(gomock.Any(), organizationID, stackID, true).Return(&operations.DeleteStackResponse{
-					StatusCode:  http.StatusNotFound,
-					RawResponse: &http.Response{StatusCode: http.StatusNotFound},
-				}, errors.New("stack not found"))
+					}, nil).
+					// Read refreshes the stack, the plans check its ownership unless it was refreshed
+					// by the same provider, and Delete checks it again before deleting
+					MinTimes(2)
+				cloudSdk.EXPECT().DeleteStack(gomock.Any(), organizationID, stackID, true).Return(nil, pkg.NewAPIError(&sdkerrors.SDKError{
+					Message:    "API error occurred",
+					StatusCode: http.StatusNotFound,
+					Body:       `{"errorCode":"NOT_FOUND","errorMessage":"stack not found"}`,
+				}))
 			},
 		},
 	} {
@@ -241,3 +273,198 @@ func TestStackAlreadyDeleted(t *testing.T) {
 		})
 	}
 }
+
+func TestStackOwnership(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		name string
+		// step returns the steps of the test, owner sets the marker of the stack returned by the API
+		step func(stackID string, owner func(string)) []resource.TestStep
+	}
+
+	const config = `
+	provider "cloud" {
+		workspace_id = "platform/production"
+	}
+	resource "cloud_stack" "test" {
+		name = "test"
+		region_id = "staging"
+		metadata = {
+			"env" = "%s"
+		}
+		force_destroy = true
+		deletion_protection = false
+	}
+	`
+	// The stack is left to the workspace owning it
+	const forget = `
+	provider "cloud" {
+		workspace_id = "platform/production"
+	}
+	removed {
+		from = cloud_stack.test
+		lifecycle {
+			destroy = false
+		}
+	}
+	`
+
+	for _, tc := range []testCase{
+		{
+			name: "import without adopt",
+			step: func(stackID string, owner func(string)) []resource.TestStep {
+				return []resource.TestStep{
+					{
+						PreConfig: func() { owner("") },
+						Config: fmt.Sprintf(`
+						import {
+							to = cloud_stack.test
+							id = "%s"
+						}
+						`, stackID) + fmt.Sprintf(config, "test"),
+						ExpectError: regexp.MustCompile(`Stack not managed by Terraform`),
+					},
+				}
+			},
+		},
+		{
+			name: "update of a stack managed by another workspace",
+			step: func(stackID string, owner func(string)) []resource.TestStep {
+				return []resource.TestStep{
+					{
+						Config: fmt.Sprintf(config, "test"),
+					},
+					{
+						PreConfig:   func() { owner("platform/staging") },
+						Config:      fmt.Sprintf(config, "production"),
+						ExpectError: regexp.MustCompile(`Stack managed by another workspace`),
+					},
+					{
+						Config: forget,
+					},
+				}
+			},
+		},
+		{
+			name: "destroy of a stack managed by another workspace",
+			step: func(stackID string, owner func(string)) []resource.TestStep {
+				return []resource.TestStep{
+					{
+						Config: fmt.Sprintf(config, "test"),
+					},
+					{
+						PreConfig:   func() { owner("platform/staging") },
+						Config:      fmt.Sprintf(config, "test"),
+						Destroy:     true,
+						ExpectError: regexp.MustCompile(`Stack managed by another workspace`),
+					},
+					{
+						Config: forget,
+					},
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			cloudSdk := pkg.NewMockCloudSDK(ctrl)
+			tokenProvider := pkg.NewMockTokenProviderImpl(ctrl)
+			cloudProvider := server.NewProvider(
+				noop.NewTracerProvider(),
+
+				logging.Testing().WithField("test", tc.name),
+				server.FormanceCloudEndpoint("dummy-endpoint"),
+				server.FormanceCloudClientId("organization_client_id"),
+				server.FormanceCloudClientSecret("dummy-client-secret"),
+				transport,
+				NewCloudSdkMockT(cloudSdk),
+				NewCloudTokenProviderMockT(tokenProvider),
+			)
+
+			organizationID := uuid.NewString()
+			tokenProvider.EXPECT().OrganizationId(gomock.Any()).Return(organizationID, nil).AnyTimes()
+
+			stackID := uuid.NewString()
+			var (
+				mu     sync.Mutex
+				marker = "platform/production"
+			)
+			stack := func() *shared.Stack {
+				mu.Lock()
+				defer mu.Unlock()
+				md := map[string]string{
+					"env": "test",
+					"github.com/formancehq/terraform-provider-cloud/deletion_protection": "false",
+				}
+				if marker != "" {
+					md["github.com/formancehq/terraform-provider-cloud/protected"] = marker
+				}
+				now := time.Now()
+				return &shared.Stack{
+					ID:                       stackID,
+					Name:                     "test",
+					OrganizationID:           organizationID,
+					RegionID:                 "staging",
+					Version:                  pointer.For("latest"),
+					URI:                      "https://example.com",
+					Metadata:                 md,
+					Status:                   shared.StackStatusReady,
+					State:                    shared.StackStateActive,
+					ExpectedStatus:           shared.ExpectedStatusReady,
+					LastStateUpdate:          now,
+					LastExpectedStatusUpdate: now,
+					LastStatusUpdate:         now,
+					Reachable:                true,
+					Synchronised:             true,
+					Modules:                  []shared.Module{},
+				}
+			}
+			owner := func(workspaceID string) {
+				mu.Lock()
+				defer mu.Unlock()
+				marker = workspaceID
+			}
+
+			// UpdateStack and DeleteStack are not expected, the provider must refuse to call them
+			cloudSdk.EXPECT().ListStacks(gomock.Any(), organizationID).
+				Return(&operations.ListStacksResponse{
+					StatusCode:  http.StatusOK,
+					RawResponse: &http.Response{StatusCode: http.StatusOK},
+					ListStacksResponse: &shared.ListStacksResponse{
+						Data: []shared.Stack{},
+					},
+				}, nil).AnyTimes()
+			cloudSdk.EXPECT().CreateStack(gomock.Any(), organizationID, gomock.Any()).
+				DoAndReturn(func(context.Context, string, *shared.CreateStackRequest) (*operations.CreateStackResponse, error) {
+					return &operations.CreateStackResponse{
+						StatusCode:  http.StatusCreated,
+						RawResponse: &http.Response{StatusCode: http.StatusCreated},
+						CreateStackResponse: &shared.CreateStackResponse{
+							Data: stack(),
+						},
+					}, nil
+				}).AnyTimes()
+			cloudSdk.EXPECT().ReadStack(gomock.Any(), organizationID, stackID).
+				DoAndReturn(func(context.Context, string, string) (*operations.GetStackResponse, error) {
+					return &operations.GetStackResponse{
+						StatusCode:  http.StatusOK,
+						RawResponse: &http.Response{StatusCode: http.StatusOK},
+						CreateStackResponse: &shared.CreateStackResponse{
+							Data: stack(),
+						},
+					}, nil
+				}).MinTimes(1)
+
+			resource.ParallelTest(t, resource.TestCase{
+				ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+					"cloud": providerserver.NewProtocol6WithError(cloudProvider()),
+				},
+				TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+					// removed blocks forget the stacks managed by another workspace
+					tfversion.SkipBelow(tfversion.Version1_7_0),
+				},
+				Steps: tc.step(stackID, owner),
+			})
+		})
+	}
+}